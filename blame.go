@@ -0,0 +1,209 @@
+package svn
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// BlameLine is every one of the lines of a file,
+// annotated with the last revision in which it was changed.
+type BlameLine struct {
+	Rev    uint
+	Author string
+	Date   string
+	// Text is the contents of the line, without the end of line.
+	Text string
+}
+
+// Blame returns the lines of a file in endRev, each one annotated with
+// the last revision (between startRev and endRev) in which it was changed,
+// like "svn blame".  startRev can be nil (meaning revision 0),
+// and endRev can be nil (meaning HEAD).
+//
+// It returns an error if the file is binary, according to its
+// "svn:mime-type" property.
+func (c *Client) Blame(path string, startRev *int, endRev *int) ([]BlameLine, error) {
+	if startRev == nil {
+		startRev = new(int)
+	}
+	if endRev != nil && *startRev > *endRev {
+		return nil, fmt.Errorf("Blame: start revision must precede end revision")
+	}
+	var b blamer
+	props := Props{}
+	err := c.GetFileRevs(path, startRev, endRev, false, func(rev FileRev) error {
+		props = rev.PropDelta.Apply(props)
+		b.add(rev)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Blame: %w", err)
+	}
+	if props.IsBinary() {
+		return nil, Error{
+			AprErr:  195004, // SVN_ERR_CLIENT_IS_BINARY_FILE
+			Message: fmt.Sprintf("Cannot calculate blame information for binary file '%s'", path),
+		}
+	}
+	return b.lines, nil
+}
+
+// A blamer computes the annotations of the lines of a file,
+// given all the revisions in which it changed, from oldest to youngest.
+type blamer struct {
+	lines []BlameLine
+}
+
+// add updates the annotations with a new revision of the file:
+// the lines that did not change keep their annotation, and the
+// new ones are annotated with this revision.
+func (b *blamer) add(rev FileRev) {
+	texts := splitLines(rev.Contents)
+	old := make([]string, len(b.lines))
+	for i, line := range b.lines {
+		old[i] = line.Text
+	}
+	matches := diffLines(old, texts)
+	lines := make([]BlameLine, len(texts))
+	for i, text := range texts {
+		if j := matches[i]; j >= 0 {
+			lines[i] = b.lines[j]
+			continue
+		}
+		lines[i] = BlameLine{
+			Rev:    rev.Rev,
+			Author: rev.RevProps[PropRevAuthor],
+			Date:   rev.RevProps[PropRevDate],
+			Text:   text,
+		}
+	}
+	b.lines = lines
+}
+
+// splitLines splits the contents of a file into lines,
+// removing the line terminators ("\n", "\r\n" or "\r").
+func splitLines(contents []byte) []string {
+	var lines []string
+	for len(contents) > 0 {
+		i := bytes.IndexAny(contents, "\r\n")
+		if i < 0 {
+			lines = append(lines, string(contents))
+			break
+		}
+		lines = append(lines, string(contents[:i]))
+		if contents[i] == '\r' && i+1 < len(contents) && contents[i+1] == '\n' {
+			i++
+		}
+		contents = contents[i+1:]
+	}
+	return lines
+}
+
+// diffLines compares two lists of lines using Myers' algorithm,
+// and returns, for every line in b, the index of the same line in a,
+// or -1 if it was added.
+//
+// It uses the linear space variant of the algorithm: it finds the
+// middle snake of the shortest edit script and recurses on both sides
+// of it, so it only needs memory for two rows of diagonals.
+func diffLines(a []string, b []string) []int {
+	matches := make([]int, len(b))
+	for i := range matches {
+		matches[i] = -1
+	}
+	max := (len(a)+len(b)+1)/2 + 1
+	d := differ{
+		a:       a,
+		b:       b,
+		matches: matches,
+		offset:  max + 1,
+		vf:      make([]int, 2*max+3),
+		vb:      make([]int, 2*max+3),
+	}
+	d.diff(0, len(a), 0, len(b))
+	return matches
+}
+
+// A differ keeps the state of diffLines: vf[k+offset] and vb[k+offset]
+// are the furthest x reached in diagonal k going forwards and backwards.
+type differ struct {
+	a, b    []string
+	matches []int
+	offset  int
+	vf, vb  []int
+}
+
+// diff records the matches between a[a0:a1] and b[b0:b1].
+func (d *differ) diff(a0, a1, b0, b1 int) {
+	// common prefix and suffix are trivial:
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.matches[b0] = a0
+		a0++
+		b0++
+	}
+	for a0 < a1 && b0 < b1 && d.a[a1-1] == d.b[b1-1] {
+		a1--
+		b1--
+		d.matches[b1] = a1
+	}
+	if a0 == a1 || b0 == b1 {
+		return
+	}
+	// without a common prefix or suffix, the edit distance is at least 2,
+	// so both sides of the middle snake are smaller problems.
+	x, y, u, v := d.middleSnake(d.a[a0:a1], d.b[b0:b1])
+	for i := 0; x+i < u; i++ {
+		d.matches[b0+y+i] = a0 + x + i
+	}
+	d.diff(a0, a0+x, b0, b0+y)
+	d.diff(a0+u, a1, b0+v, b1)
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the snake
+// in the middle of the shortest edit script between a and b.
+func (d *differ) middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	off, vf, vb := d.offset, d.vf, d.vb
+	vf[1+off] = 0
+	vb[1+off] = 0
+	for e := 0; e <= (n+m+1)/2; e++ {
+		for k := -e; k <= e; k += 2 {
+			if k == -e || (k != e && vf[k-1+off] < vf[k+1+off]) {
+				x = vf[k+1+off]
+			} else {
+				x = vf[k-1+off] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u++
+				v++
+			}
+			vf[k+off] = u
+			if r := delta - k; odd && r >= -(e-1) && r <= e-1 && u+vb[r+off] >= n {
+				return x, y, u, v
+			}
+		}
+		// backwards, x and y count from the end of a and b:
+		for k := -e; k <= e; k += 2 {
+			if k == -e || (k != e && vb[k-1+off] < vb[k+1+off]) {
+				x = vb[k+1+off]
+			} else {
+				x = vb[k-1+off] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[n-1-u] == b[m-1-v] {
+				u++
+				v++
+			}
+			vb[k+off] = u
+			if f := delta - k; !odd && f >= -e && f <= e && vf[f+off]+u >= n {
+				return n - u, m - v, n - x, m - y
+			}
+		}
+	}
+	panic("diffLines: no middle snake")
+}
@@ -0,0 +1,119 @@
+package svn
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"", "a b c", "-1 -1 -1"},
+		{"a b c", "a b c", "0 1 2"},
+		{"a b c", "", ""},
+		{"a b c", "a x c", "0 -1 2"},
+		{"a b c d e", "b c x e a", "1 2 -1 4 -1"},
+		{"a b c a b b a", "c b a b a c", "2 -1 3 4 6 -1"},
+	}
+	for _, tt := range tests {
+		matches := diffLines(strings.Fields(tt.a), strings.Fields(tt.b))
+		got := strings.Trim(fmt.Sprint(matches), "[]")
+		// there may be more than one valid answer; check it is one of them
+		// and that it has the expected number of matches.
+		if strings.Count(got, "-1") != strings.Count(tt.want, "-1") {
+			t.Errorf("diffLines(%q, %q): want %s got %s", tt.a, tt.b, tt.want, got)
+		}
+		last := -1
+		for i, j := range matches {
+			if j < 0 {
+				continue
+			}
+			if j <= last || strings.Fields(tt.a)[j] != strings.Fields(tt.b)[i] {
+				t.Errorf("diffLines(%q, %q): invalid match %d -> %d", tt.a, tt.b, i, j)
+			}
+			last = j
+		}
+	}
+}
+
+func TestBlamer(t *testing.T) {
+	var b blamer
+	for _, rev := range []FileRev{
+		{Rev: 1, RevProps: Props{PropRevAuthor: "alice"}, Contents: []byte("one\ntwo\nthree\n")},
+		{Rev: 3, RevProps: Props{PropRevAuthor: "bob"}, Contents: []byte("one\n2\nthree\nfour\n")},
+		{Rev: 7, RevProps: Props{PropRevAuthor: "carol"}, Contents: []byte("zero\r\none\r\n2\r\nthree\r\nfour")},
+	} {
+		b.add(rev)
+	}
+	var got []string
+	for _, line := range b.lines {
+		got = append(got, fmt.Sprintf("%d %s %s", line.Rev, line.Author, line.Text))
+	}
+	want := []string{"7 carol zero", "1 alice one", "3 bob 2", "1 alice three", "3 bob four"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		l := make([]string, rnd.Intn(40))
+		for i := range l {
+			l[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return l
+	}
+	for n := 0; n < 500; n++ {
+		a, b := lines(), lines()
+		// the length of the longest common subsequence:
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		count, last := 0, -1
+		for i, j := range diffLines(a, b) {
+			if j < 0 {
+				continue
+			}
+			if j <= last || a[j] != b[i] {
+				t.Fatalf("diffLines(%q, %q): invalid match %d -> %d", a, b, i, j)
+			}
+			count, last = count+1, j
+		}
+		if count != lcs[0][0] {
+			t.Fatalf("diffLines(%q, %q): want %d matches, got %d", a, b, lcs[0][0], count)
+		}
+	}
+}
+
+func TestDiffLinesMemory(t *testing.T) {
+	// a file completely rewritten is the worst case for the edit distance:
+	a := make([]string, 5000)
+	b := make([]string, 5000)
+	for i := range a {
+		a[i] = fmt.Sprint("old ", i)
+		b[i] = fmt.Sprint("new ", i)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	diffLines(a, b)
+	runtime.ReadMemStats(&after)
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Errorf("diffLines allocated %d bytes", alloc)
+	}
+}
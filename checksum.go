@@ -0,0 +1,63 @@
+package svn
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// A checksum computes the digest of a file as it is being transferred,
+// and compares it with the one sent by the other end.
+//
+// SVN sends checksums as hexadecimal strings: MD5 most of the time,
+// and SHA1 in some cases.  The kind is deduced from its length.
+type checksum struct {
+	hash.Hash
+	expected string
+}
+
+// newChecksum returns a checksum which will be verified against
+// expected.  If expected is empty or of an unknown kind, it
+// computes an MD5 checksum which is never verified.
+func newChecksum(expected string) *checksum {
+	switch len(expected) {
+	case 2 * sha1.Size:
+		return &checksum{Hash: sha1.New(), expected: expected}
+	case 2 * md5.Size:
+		return &checksum{Hash: md5.New(), expected: expected}
+	}
+	return &checksum{Hash: md5.New()}
+}
+
+// String returns the computed checksum as a hexadecimal string.
+func (c *checksum) String() string {
+	return hex.EncodeToString(c.Sum(nil))
+}
+
+// verify returns an error if the computed checksum does not match
+// the expected one.
+func (c *checksum) verify() error {
+	if c.expected == "" || c.String() == c.expected {
+		return nil
+	}
+	return Error{
+		AprErr:  200014,
+		Message: fmt.Sprintf("Checksum mismatch: expected %s, actual %s", c.expected, c.String()),
+	}
+}
+
+// md5Reader computes the MD5 checksum of the contents of r,
+// and rewinds it to the beginning.
+func md5Reader(r io.ReadSeeker) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
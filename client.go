@@ -1,9 +1,18 @@
 package svn
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/cespedes/svn/mergeinfo"
+	"github.com/cespedes/svn/svndiff"
 )
 
 // SvnClient is the SVN client string to send to servers.
@@ -11,118 +20,320 @@ const SvnClient = "GoSVN/0.0.0"
 
 // A Client is a SVN client.  Its zero value is not usable: you will have
 // to create it and connect it to a server using [Connect].
+//
+// Every Client is a session, with a URL to which the paths in its
+// methods are relative.  Several sessions in the same repository
+// can share a connection (see [Client.OpenSession]); they must not
+// be used concurrently.
 type Client struct {
-	conn conn
-	cmd  *exec.Cmd
+	*tunnel
 	Info ReposInfo
+	url  string
+	ctx  context.Context
 }
 
 // Connect creates a [Client] and establishes a connection
 // to a SVN server, using the given address
 // to find out know how to connect to it.
 //
-// Right now, it works only with "file" and "svn+ssh" URLs,
-// invoking "svnserve -t" (locally or remotely) to connect
-// to a server
-func Connect(address string) (*Client, error) {
-	var c Client
+// It uses the driver registered for the scheme of the URL (see
+// [RegisterScheme]), which must return a session using the SVN protocol.
+func Connect(address string, opts ...Option) (*Client, error) {
+	return ConnectContext(context.Background(), address, opts...)
+}
 
-	u, err := url.Parse(address)
+// ConnectContext is like [Connect], but aborts the connection if ctx
+// is done before it is established.  Once connected, ctx has no effect
+// on the returned Client; see [Client.WithContext].
+func ConnectContext(ctx context.Context, address string, opts ...Option) (*Client, error) {
+	s, err := OpenContext(ctx, address, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("svn connect: parsing %q: %w", address, err)
-	}
-
-	var execArgs []string
-
-	// schema could be one of:
-	// - file
-	// - http
-	// - https
-	// - svn
-	// - svn+ssh
-	switch u.Scheme {
-	case "file":
-		execArgs = []string{
-			"svnserve",
-			"-t",
-		}
-		// Standard "svnserve" does not work if we tell it we want a "file:" scheme:
-		u.Scheme = "svn+ssh"
-	case "svn+ssh":
-		host := u.Host
-		if u.User != nil {
-			host = u.User.String() + "@" + host
-		}
-		execArgs = []string{
-			"ssh",
-			"-q",
-			"-o",
-			"ControlMaster=no",
-			"--",
-			host,
-			"svnserve",
-			"-t",
-		}
-	default:
-		return nil, fmt.Errorf("svn: connect to %q: scheme %q not implemented", address, u.Scheme)
+		return nil, err
+	}
+	c, ok := s.(*Client)
+	if !ok {
+		s.Close()
+		return nil, fmt.Errorf("svn: connect to %q: the scheme does not use the SVN protocol", address)
 	}
+	return c, nil
+}
 
-	err = c.exec(execArgs[0], execArgs[1:]...)
+// NewClient creates a [Client] for a session in address, using rw
+// to talk to the server: it can be any stream connected to a SVN server,
+// such as a TCP connection or one end of a [net.Pipe] whose other end
+// is served by [Server.Serve].  Closing the Client closes rw.
+func NewClient(rw io.ReadWriteCloser, address string, opts ...Option) (*Client, error) {
+	return newClient(context.Background(), newTunnel(io.NopCloser(rw), rw), address, newOptions(opts))
+}
+
+// newClient creates a Client using an established tunnel,
+// and makes the initial handshake.
+func newClient(ctx context.Context, t *tunnel, address string, o options) (*Client, error) {
+	c := &Client{tunnel: t}
+	c.conn.trace = o.trace
+	u, err := c.wireURL(address)
 	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.tunnel.ctx = ctx
+	if err = c.handshake(u); err != nil {
+		c.Close()
+		// the reason is usually in the standard error of the tunnel:
+		if stderr := t.stderr.String(); stderr != "" {
+			err = fmt.Errorf("%w: %s", err, stderr)
+		}
 		return nil, err
 	}
+	c.tunnel.ctx = nil
+	return c, nil
+}
 
+// handshake reads the greeting of the server, sends the response
+// for a session in u and authenticates the client.
+func (c *Client) handshake(u string) error {
 	var greet struct {
 		MinVer       int
 		MaxVer       int
 		Mechs        Item
 		Capabilities []string
 	}
-	err = c.conn.ReadResponse(&greet)
+	err := c.conn.ReadResponse(&greet)
 	if err != nil {
-		return nil, fmt.Errorf("reading greeting: %w", err)
+		return fmt.Errorf("reading greeting: %w", err)
 	}
 	if greet.MinVer > SvnVersion || greet.MaxVer < SvnVersion {
-		return nil, fmt.Errorf("client: unsupported SVN version range (%d .. %d)", greet.MinVer, greet.MaxVer)
+		return fmt.Errorf("client: unsupported SVN version range (%d .. %d)", greet.MinVer, greet.MaxVer)
 	}
+	c.url = u
+	c.parent = c.url
 	err = c.conn.Write([]any{
 		SvnVersion,
 		//[]string{"edit-pipeline", "svndiff1", "accepts-svndiff2", "absent-entries", "depth", "mergeinfo", "log-revprops"},
-		[]string{"edit-pipeline"},
-		[]byte(u.String()),
+		[]string{"edit-pipeline", "svndiff1"},
+		[]byte(c.url),
 		[]byte(SvnClient),
 		[]any{},
 	})
 	if err != nil {
-		return nil, fmt.Errorf("client: sending greeting response: %w", err)
+		return fmt.Errorf("client: sending greeting response: %w", err)
 	}
 
 	err = c.handleAuth()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	err = c.conn.ReadResponse(&c.Info)
 	if err != nil {
-		return nil, fmt.Errorf("reading repos-info: %w", err)
+		return fmt.Errorf("reading repos-info: %w", err)
+	}
+	// the capabilities sent in the greeting are those of the server,
+	// and the ones in repos-info are those of the repository:
+	for _, capability := range greet.Capabilities {
+		if !c.HasCapability(capability) {
+			c.Info.Capabilities = append(c.Info.Capabilities, capability)
+		}
 	}
 
-	return &c, nil
+	return nil
+}
+
+// HasCapability reports whether the server or the repository
+// announced the given capability.
+func (c *Client) HasCapability(capability string) bool {
+	return slices.Contains(c.Info.Capabilities, capability)
+}
+
+// ReposInfo returns the UUID, root URL and capabilities of the repository,
+// as in c.Info.
+func (c *Client) ReposInfo() ReposInfo {
+	return c.Info
 }
 
-func (c *Client) exec(name string, arg ...string) error {
-	c.cmd = exec.Command(name, arg...)
-	stdout, err := c.cmd.StdoutPipe()
+// URL returns the URL of the session.  The paths used in the methods
+// of c are relative to it.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// OpenSession returns a new session for address, which must be
+// in the same repository as c.  The new session shares the connection
+// of c, so no new connection to the server is made.
+func (c *Client) OpenSession(address string) (*Client, error) {
+	u, err := c.sessionURL(address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{tunnel: c.tunnel, Info: c.Info, url: u}, nil
+}
+
+// Reparent sends a "reparent" command, changing the URL of the session
+// to another one.
+//
+// The URL is usually in the same repository.  A server serving several
+// repositories (see [MultiServer]) can also accept a URL in another one,
+// answering with the UUID, root URL and capabilities of that repository,
+// which replace the ones in c.Info:
+//
+//	reparent
+//	  params:   ( url:string )
+//	  response: ( [ uuid:string repos-url:string ( cap:word ... ) ] )
+func (c *Client) Reparent(address string) error {
+	u, err := c.wireURL(address)
 	if err != nil {
 		return err
 	}
-	stdin, err := c.cmd.StdinPipe()
+	c.tunnel.ctx = c.ctx
+	if _, ok := relativeURL(c.Info.URL, u); ok {
+		c.url = u
+		return c.reparent()
+	}
+	info, err := sendCommand[ReposInfo](c, "reparent", []any{[]byte(u)})
+	if err != nil {
+		return fmt.Errorf("reparent: %w", err)
+	}
+	if info.URL == "" {
+		return fmt.Errorf("svn: %q is not in repository %q", address, c.Info.URL)
+	}
+	c.Info = info
+	c.url, c.parent = u, u
+	return nil
+}
+
+// sessionURL returns the URL to send to the server for a session
+// in address, checking that it is in the repository of c.
+func (c *Client) sessionURL(address string) (string, error) {
+	u, err := c.wireURL(address)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := relativeURL(c.Info.URL, u); !ok {
+		return "", fmt.Errorf("svn: %q is not in repository %q", address, c.Info.URL)
+	}
+	return u, nil
+}
+
+// wireURL returns the URL to send to the server for a session in address.
+func (c *Client) wireURL(address string) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("svn: parsing %q: %w", address, err)
+	}
+	if c.scheme != "" {
+		u.Scheme = c.scheme
+	}
+	return u.String(), nil
+}
+
+// reparent sends a "reparent" command if the connection
+// is not parented to the URL of the session.
+//
+//	reparent
+//	  params:   ( url:string )
+//	  response: ( )
+func (c *Client) reparent() error {
+	if c.parent == c.url {
+		return nil
+	}
+	err := c.conn.Write([]any{
+		"reparent",
+		[]any{[]byte(c.url)},
+	})
 	if err != nil {
+		return fmt.Errorf("client: sending reparent: %w", err)
+	}
+	if err = c.handleAuth(); err != nil {
+		return err
+	}
+	var item Item
+	if err = c.conn.ReadResponse(&item); err != nil {
+		return fmt.Errorf("reparent: %w", err)
+	}
+	c.parent = c.url
+	return nil
+}
+
+// writeCommand sends a command, after parenting the connection
+// to the URL of the session.
+func (c *Client) writeCommand(cmd string, params any) error {
+	c.tunnel.ctx = c.ctx
+	if err := c.reparent(); err != nil {
 		return err
 	}
-	c.conn.r = stdout
-	c.conn.w = stdin
-	return c.cmd.Start()
+	err := c.conn.Write([]any{
+		cmd,
+		params,
+	})
+	if err != nil {
+		return fmt.Errorf("client: sending %s: %w", cmd, err)
+	}
+	return nil
+}
+
+// relativeURL returns the path of u relative to root,
+// and whether u is root or one of its descendants.
+func relativeURL(root string, u string) (string, bool) {
+	root = strings.TrimSuffix(root, "/")
+	u = strings.TrimSuffix(u, "/")
+	if u == root {
+		return "", true
+	}
+	rest, ok := strings.CutPrefix(u, root+"/")
+	if !ok {
+		return "", false
+	}
+	p, err := url.PathUnescape(rest)
+	if err != nil {
+		return "", false
+	}
+	return p, true
+}
+
+// startTunnel runs a command which connects to a server
+// using its standard input and output.
+func startTunnel(name string, arg ...string) (*tunnel, error) {
+	cmd := exec.Command(name, arg...)
+	stderr := &tailBuffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	t := newTunnel(stdout, stdin)
+	t.cmd = cmd
+	t.stderr = stderr
+	return t, nil
+}
+
+// Close closes the connection to the server and waits for the tunnel
+// process, if any, to exit.  The connection is shared by all the
+// sessions opened with [Client.OpenSession], which cannot be used
+// after calling Close.
+func (c *Client) Close() error {
+	return c.close()
+}
+
+// WithContext returns a copy of the session which uses ctx for
+// all its operations: if ctx is done before one of them finishes,
+// it is aborted and returns the error of the context.  The connection
+// is then closed, as there is no way to resynchronize it with the server.
+//
+// The returned Client shares the connection with c.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("svn: nil context")
+	}
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
 }
 
 func (c *Client) handleAuth() error {
@@ -146,9 +357,22 @@ func (c *Client) handleAuth() error {
 	if err != nil {
 		return fmt.Errorf("sending auth response: %w", err)
 	}
+	// the server rejects the client with ( failure ( message:string ) ):
 	var item Item
-	err = c.conn.ReadResponse(&item)
-	if err != nil {
+	if err = c.conn.Read(&item); err != nil {
+		return fmt.Errorf("reading auth response: %w", err)
+	}
+	var failure struct {
+		Type    string
+		Message []string
+	}
+	if Unmarshal(item, &failure) == nil && failure.Type == "failure" && len(failure.Message) > 0 {
+		return Error{
+			AprErr:  170001, // SVN_ERR_RA_NOT_AUTHORIZED
+			Message: failure.Message[0],
+		}
+	}
+	if _, err = ParseResponse(item); err != nil {
 		return fmt.Errorf("reading auth response: %w", err)
 	}
 	return nil
@@ -157,13 +381,10 @@ func (c *Client) handleAuth() error {
 func sendCommand[Output any](c *Client, cmd string, params any) (Output, error) {
 	var out Output
 
-	err := c.conn.Write([]any{
-		cmd,
-		params,
-	})
-	if err != nil {
-		return out, fmt.Errorf("client: sending %s: %w", cmd, err)
+	if err := c.writeCommand(cmd, params); err != nil {
+		return out, err
 	}
+	var err error
 	if err = c.handleAuth(); err != nil {
 		return out, err
 	}
@@ -172,6 +393,42 @@ func sendCommand[Output any](c *Client, cmd string, params any) (Output, error)
 	return out, err
 }
 
+// sendListCommand sends a command whose response is preceded
+// by a list of entries, terminated by "done", and returns them.
+func sendListCommand[Entry any](c *Client, cmd string, params any) ([]Entry, error) {
+	err := c.writeCommand(cmd, params)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.handleAuth(); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for {
+		var item Item
+		err = c.conn.Read(&item)
+		if err != nil {
+			return nil, fmt.Errorf("reading entry: %w", err)
+		}
+		if item.Type == WordType && item.Text == "done" {
+			break
+		}
+		var entry Entry
+		err = Unmarshal(item, &entry)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshaling entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	var item Item
+	err = c.conn.ReadResponse(&item)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
 // GetLatestRev sends a "get-latest-rev" command, asking for
 // the latest revision number in the repository.
 func (c *Client) GetLatestRev() (int, error) {
@@ -180,6 +437,7 @@ func (c *Client) GetLatestRev() (int, error) {
 
 // Stat sends a "stat" command, asking for the status of a path in a revision.
 // "rev" can be nil or a pointer to an integer.
+// If the path does not exist, the returned Kind is [NodeNone].
 func (c *Client) Stat(path string, rev *int) (Stat, error) {
 	lrev := []int{}
 	if rev != nil {
@@ -187,7 +445,50 @@ func (c *Client) Stat(path string, rev *int) (Stat, error) {
 	}
 	input := []any{[]byte(path), lrev}
 
-	return sendCommand[Stat](c, "stat", input)
+	response, err := sendCommand[struct{ Entry []Stat }](c, "stat", input)
+	if err != nil {
+		return Stat{}, err
+	}
+	if len(response.Entry) == 0 {
+		return Stat{Kind: NodeNone}, nil
+	}
+	return response.Entry[0], nil
+}
+
+// CheckPath sends a "check-path" command, asking for the kind
+// of node of a path in a revision ([NodeNone] if it does not exist).
+// "rev" can be nil or a pointer to an integer.
+func (c *Client) CheckPath(path string, rev *int) (NodeKind, error) {
+	lrev := []int{}
+	if rev != nil {
+		lrev = append(lrev, *rev)
+	}
+	kind, err := sendCommand[NodeKind](c, "check-path", []any{[]byte(path), lrev})
+	if err != nil {
+		return "", fmt.Errorf("CheckPath: %w", err)
+	}
+	return kind, nil
+}
+
+//  get-deleted-rev
+//    params:   ( path:string peg-rev:number end-rev:number )
+//    response: ( deleted-rev:number )
+
+// GetDeletedRev sends a "get-deleted-rev" command, asking for the
+// revision in which path (as it was in pegRev) was deleted,
+// looking no further than endRev.
+// If it was not deleted in that range, it returns [InvalidRevision].
+func (c *Client) GetDeletedRev(path string, pegRev int, endRev int) (int, error) {
+	rev, err := sendCommand[int](c, "get-deleted-rev", []any{[]byte(path), pegRev, endRev})
+	if err != nil {
+		var svnErr Error
+		// SVN_ERR_ENTRY_MISSING_REVISION
+		if errors.As(err, &svnErr) && svnErr.AprErr == 150003 {
+			return InvalidRevision, nil
+		}
+		return InvalidRevision, fmt.Errorf("GetDeletedRev: %w", err)
+	}
+	return rev, nil
 }
 
 // List sends a "list" command, asking for list of files.
@@ -202,12 +503,9 @@ func (c *Client) List(path string, rev *int, depth string, fields []string) ([]D
 		depth,
 		fields,
 	}
-	err := c.conn.Write([]any{
-		"list",
-		params,
-	})
+	err := c.writeCommand("list", params)
 	if err != nil {
-		return nil, fmt.Errorf("client: sending \"list\": %w", err)
+		return nil, err
 	}
 	if err = c.handleAuth(); err != nil {
 		return nil, fmt.Errorf("client: List: auth: %w", err)
@@ -245,45 +543,223 @@ func (c *Client) List(path string, rev *int, depth string, fields []string) ([]D
 //                [ inherited-props:iproplist ] )
 
 // GetFile sends a "get-file" command, asking for the contents of a file.
-func (c *Client) GetFile(path string, rev *int, wantProps bool, wantContent bool) ([]PropList, []byte, error) {
+// If wantIProps is true, the server will also send the properties
+// inherited from its parents.
+func (c *Client) GetFile(path string, rev *int, wantProps bool, wantContent bool, wantIProps bool) (File, error) {
+	var w io.Writer
+	var buf bytes.Buffer
+	if wantContent {
+		w = &buf
+	}
+	file, err := c.getFile(path, rev, wantProps, wantIProps, w)
+	if err != nil {
+		return file, fmt.Errorf("GetFile: %w", err)
+	}
+	if wantContent {
+		file.Contents = buf.Bytes()
+	}
+	return file, nil
+}
+
+// GetFileTo sends a "get-file" command, and writes the contents
+// of the file to w as they are received, without keeping them
+// in memory.  The returned File has its properties, but not its contents.
+//
+// Once the whole file has been received, its checksum is verified
+// against the one sent by the server.
+func (c *Client) GetFileTo(path string, rev *int, w io.Writer) (File, error) {
+	file, err := c.getFile(path, rev, true, false, w)
+	if err != nil {
+		return file, fmt.Errorf("GetFileTo: %w", err)
+	}
+	return file, nil
+}
+
+// getFile sends a "get-file" command.  If w is not nil, it asks for
+// the contents of the file, writes them to w and verifies their checksum.
+func (c *Client) getFile(path string, rev *int, wantProps bool, wantIProps bool, w io.Writer) (File, error) {
 	lrev := []int{}
 	if rev != nil {
 		lrev = append(lrev, *rev)
 	}
-	type FileResponse struct {
-		Checksum string
-		Rev      int
-		Props    []PropList
-	}
-	response, err := sendCommand[FileResponse](c, "get-file", []any{
+	var file File
+	response, err := sendCommand[struct {
+		Checksum       []string
+		Rev            uint
+		Props          Props
+		InheritedProps []InheritedProps
+	}](c, "get-file", []any{
 		[]byte(path),
 		lrev,
 		wantProps,
-		wantContent,
-		"false",
+		w != nil,
+		wantIProps,
 	})
-
 	if err != nil {
-		return nil, nil, fmt.Errorf("GetFile: %w", err)
+		return file, err
+	}
+	file.Rev = response.Rev
+	file.Props = response.Props
+	if len(response.Checksum) > 0 {
+		file.Checksum = response.Checksum[0]
+	}
+	if len(response.InheritedProps) > 0 {
+		file.InheritedProps = response.InheritedProps[0]
 	}
 
-	if !wantContent {
-		return response.Props, nil, nil
+	if w == nil {
+		return file, nil
 	}
-	content := []byte{}
+	h := newChecksum(file.Checksum)
+	var werr error
 	for {
 		var b []byte
 		err = c.conn.Read(&b)
 		if err != nil {
-			return nil, nil, fmt.Errorf("GetFile: reading content: %w", err)
+			return file, fmt.Errorf("reading content: %w", err)
 		}
 		if len(b) == 0 {
 			break
 		}
-		content = append(content, b...)
+		file.Size += uint64(len(b))
+		h.Write(b)
+		// keep reading the rest of the file even if writing fails,
+		// so that the connection is still usable.
+		if werr == nil {
+			_, werr = w.Write(b)
+		}
+	}
+	var item Item
+	err = c.conn.ReadResponse(&item)
+	if err != nil {
+		return file, fmt.Errorf("reading final response: %w", err)
+	}
+	if werr != nil {
+		return file, fmt.Errorf("writing content: %w", werr)
+	}
+	if err = h.verify(); err != nil {
+		return file, err
 	}
 
-	return response.Props, content, nil
+	return file, nil
+}
+
+//  get-dir
+//    params:   ( path:string [ rev:number ] want-props:bool want-contents:bool
+//                ? ( field:dirent-field ... ) ? want-iprops:bool )
+//    response: ( rev:number props:proplist ( entry:dirent ... )
+//                [ inherited-props:iproplist ] )
+
+// GetDir sends a "get-dir" command, asking for the properties and
+// the entries of a directory.  "fields" selects which fields of every
+// entry the server should fill in; if it is empty, all of them are sent.
+// If wantIProps is true, the server will also send the properties
+// inherited from its parents.
+func (c *Client) GetDir(path string, rev *int, wantProps bool, wantContents bool, fields []string, wantIProps bool) (Dir, error) {
+	lrev := []int{}
+	if rev != nil {
+		lrev = append(lrev, *rev)
+	}
+	if fields == nil {
+		fields = []string{}
+	}
+	var dir Dir
+	response, err := sendCommand[struct {
+		Rev            uint
+		Props          Props
+		Entries        []Dirent
+		InheritedProps []InheritedProps
+	}](c, "get-dir", []any{
+		[]byte(path),
+		lrev,
+		wantProps,
+		wantContents,
+		fields,
+		wantIProps,
+	})
+	if err != nil {
+		return dir, fmt.Errorf("GetDir: %w", err)
+	}
+	dir.Rev = response.Rev
+	dir.Props = response.Props
+	dir.Entries = response.Entries
+	if len(response.InheritedProps) > 0 {
+		dir.InheritedProps = response.InheritedProps[0]
+	}
+	return dir, nil
+}
+
+//  get-iprops
+//    params:   ( path:string [ rev:number ] )
+//    response: ( inherited-props:iproplist )
+
+// GetIProps sends a "get-iprops" command, asking for the properties
+// inherited by a path from its parents.
+func (c *Client) GetIProps(path string, rev *int) (InheritedProps, error) {
+	lrev := []int{}
+	if rev != nil {
+		lrev = append(lrev, *rev)
+	}
+	response, err := sendCommand[struct {
+		InheritedProps InheritedProps
+	}](c, "get-iprops", []any{
+		[]byte(path),
+		lrev,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetIProps: %w", err)
+	}
+	return response.InheritedProps, nil
+}
+
+//  get-mergeinfo
+//    params:   ( ( path:string ... ) [ rev:number ] inherit:word descendants:bool)
+//    response: ( ( ( path:string merge-info:string ) ... ) )
+
+// GetMergeinfo sends a "get-mergeinfo" command, asking for the
+// "svn:mergeinfo" of some paths in a revision (nil meaning HEAD).
+//
+// inherit can be "explicit" (only the mergeinfo set in the paths),
+// "inherited" (the mergeinfo of a path or, if it has none, the one it
+// inherits from its parents) or "nearest-ancestor" (only the inherited one).
+// If includeDescendants is true, the mergeinfo of the descendants
+// of the paths is also returned.
+//
+// The result only has the paths with some mergeinfo.
+func (c *Client) GetMergeinfo(paths []string, rev *int, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error) {
+	if !c.HasCapability("mergeinfo") {
+		return nil, Error{
+			AprErr:  200007, // SVN_ERR_UNSUPPORTED_FEATURE
+			Message: "Server does not support mergeinfo",
+		}
+	}
+	bpaths := make([][]byte, len(paths))
+	for i, p := range paths {
+		bpaths[i] = []byte(p)
+	}
+	response, err := sendCommand[struct {
+		Catalog []struct {
+			Path      string
+			Mergeinfo string
+		}
+	}](c, "get-mergeinfo", []any{
+		bpaths,
+		[]any{rev},
+		inherit,
+		includeDescendants,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetMergeinfo: %w", err)
+	}
+	catalog := make(map[string]mergeinfo.Mergeinfo, len(response.Catalog))
+	for _, entry := range response.Catalog {
+		m, err := mergeinfo.Parse(entry.Mergeinfo)
+		if err != nil {
+			return nil, fmt.Errorf("GetMergeinfo: %s: %w", entry.Path, err)
+		}
+		catalog[entry.Path] = m
+	}
+	return catalog, nil
 }
 
 //  log
@@ -314,7 +790,7 @@ func (c *Client) Log(paths []string, startRev *int, endRev *int, changedPaths bo
 		bpaths = append(bpaths, []byte(p))
 	}
 
-	err := c.conn.Write([]any{
+	err := c.writeCommand(
 		"log", []any{
 			bpaths,
 			srev,
@@ -326,9 +802,9 @@ func (c *Client) Log(paths []string, startRev *int, endRev *int, changedPaths bo
 				[]byte("svn:log"),
 			},
 		},
-	})
+	)
 	if err != nil {
-		return nil, fmt.Errorf("client: sending \"log\": %w", err)
+		return nil, err
 	}
 	if err = c.handleAuth(); err != nil {
 		return nil, fmt.Errorf("client: Log: auth: %w", err)
@@ -358,3 +834,524 @@ func (c *Client) Log(paths []string, startRev *int, endRev *int, changedPaths bo
 	}
 	return entries, nil
 }
+
+//  get-locations
+//    params:   ( path:string peg-rev:number ( rev:number ... ) )
+//    Before sending response, server sends location entries, ending with "done".
+//    location-entry: ( rev:number abs-path:number ) | done
+//    response: ( )
+
+// GetLocations sends a "get-locations" command, asking for the
+// absolute paths (from the root of the repository) of the node which
+// was at "path" in revision pegRev, in each of the revisions in revs.
+// Revisions where the node did not exist are not present in the result.
+func (c *Client) GetLocations(path string, pegRev int, revs []int) (map[int]string, error) {
+	if revs == nil {
+		revs = []int{}
+	}
+	entries, err := sendListCommand[struct {
+		Rev  int
+		Path string
+	}](c, "get-locations", []any{[]byte(path), pegRev, revs})
+	if err != nil {
+		return nil, fmt.Errorf("GetLocations: %w", err)
+	}
+	locations := make(map[int]string, len(entries))
+	for _, e := range entries {
+		locations[e.Rev] = e.Path
+	}
+	return locations, nil
+}
+
+//  get-location-segments
+//    params:   ( path:string [ peg-rev:number ] [ start-rev:number ]
+//                [ end-rev:number ] )
+//    Before sending response, server sends location segments, ending with "done".
+//    location-segment: ( range-start:number range-end:number
+//                        [ abs-path:string ] ) | done
+//    response: ( )
+
+// GetLocationSegments sends a "get-location-segments" command, asking
+// for the history of the node which was at "path" in revision pegRev,
+// as a list of segments (from youngest to oldest) in which the node
+// had the same path, between startRev and endRev.
+// Any of the revisions can be nil (meaning HEAD for pegRev and startRev,
+// and 0 for endRev).
+func (c *Client) GetLocationSegments(path string, pegRev *int, startRev *int, endRev *int) ([]LocationSegment, error) {
+	optRev := func(rev *int) []int {
+		if rev == nil {
+			return []int{}
+		}
+		return []int{*rev}
+	}
+	segments, err := sendListCommand[LocationSegment](c, "get-location-segments", []any{
+		[]byte(path),
+		optRev(pegRev),
+		optRev(startRev),
+		optRev(endRev),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetLocationSegments: %w", err)
+	}
+	return segments, nil
+}
+
+// ResolvePeg returns the absolute path (from the root of the repository)
+// that the node which was at "path" in revision pegRev had in revision opRev,
+// following its history across renames and copies, the same way
+// Subversion handles "path@PEG -r OP".
+func (c *Client) ResolvePeg(path string, pegRev int, opRev int) (string, error) {
+	locations, err := c.GetLocations(path, pegRev, []int{opRev})
+	if err != nil {
+		return "", err
+	}
+	p, ok := locations[opRev]
+	if !ok {
+		return "", Error{
+			AprErr:  195012, // SVN_ERR_CLIENT_UNRELATED_RESOURCES
+			Message: fmt.Sprintf("Unable to find repository location for '%s' in revision %d", path, opRev),
+		}
+	}
+	return p, nil
+}
+
+//  get-file-revs
+//    params:   ( path:string [ start-rev:number ] [ end-rev:number ]
+//                ? include-merged-revisions:bool )
+//    Before sending response, server sends file-rev entries, ending with "done".
+//    file-rev: ( path:string rev:number rev-props:proplist
+//                file-props:propdelta ? merged-revision:bool )
+//    After each file-rev, the file delta is sent as one or more strings,
+//    terminated by the empty string.  If there is no delta, server just sends
+//    the terminator.
+//    response: ( )
+
+// GetFileRevs sends a "get-file-revs" command, asking for all the
+// revisions in which a file changed between startRev and endRev,
+// and calls fn for each of them, with the full contents of the file.
+//
+// If startRev is greater than endRev, revisions are sent from the
+// youngest to the oldest; this needs a server with the
+// "file-revs-reverse" capability.
+//
+// If fn returns an error, it is not called again, and GetFileRevs
+// returns that error once all the responses have been read.
+func (c *Client) GetFileRevs(path string, startRev *int, endRev *int, includeMerged bool, fn func(FileRev) error) error {
+	if startRev != nil && endRev != nil && *startRev > *endRev && !c.HasCapability("file-revs-reverse") {
+		return Error{
+			AprErr:  200007, // SVN_ERR_UNSUPPORTED_FEATURE
+			Message: "The server does not support retrieving file revisions in reverse order",
+		}
+	}
+	optRev := func(rev *int) []int {
+		if rev == nil {
+			return []int{}
+		}
+		return []int{*rev}
+	}
+	err := c.writeCommand(
+		"get-file-revs",
+		[]any{
+			[]byte(path),
+			optRev(startRev),
+			optRev(endRev),
+			includeMerged,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if err = c.handleAuth(); err != nil {
+		return fmt.Errorf("client: GetFileRevs: auth: %w", err)
+	}
+
+	var contents []byte
+	var fnErr error
+	for {
+		var item Item
+		err = c.conn.Read(&item)
+		if err != nil {
+			return fmt.Errorf("client: GetFileRevs: reading file-rev: %w", err)
+		}
+		if item.Type == WordType && item.Text == "done" {
+			break
+		}
+		var rev FileRev
+		err = Unmarshal(item, &rev)
+		if err != nil {
+			return fmt.Errorf("client: GetFileRevs: unmarshaling file-rev: %w", err)
+		}
+		var buf bytes.Buffer
+		delta := svndiff.NewDecoder(&buf, bytes.NewReader(contents))
+		changed := false
+		for {
+			var b []byte
+			err = c.conn.Read(&b)
+			if err != nil {
+				return fmt.Errorf("client: GetFileRevs: reading delta: %w", err)
+			}
+			if len(b) == 0 {
+				break
+			}
+			changed = true
+			if _, err = delta.Write(b); err != nil {
+				return fmt.Errorf("client: GetFileRevs: r%d: %w", rev.Rev, err)
+			}
+		}
+		if changed {
+			if err = delta.Close(); err != nil {
+				return fmt.Errorf("client: GetFileRevs: r%d: %w", rev.Rev, err)
+			}
+			contents = buf.Bytes()
+		}
+		rev.Contents = contents
+		if fnErr == nil {
+			fnErr = fn(rev)
+		}
+	}
+	var item Item
+	err = c.conn.ReadResponse(&item)
+	if err != nil {
+		return fmt.Errorf("client: GetFileRevs: reading final response: %w", err)
+	}
+	return fnErr
+}
+
+//  lock
+//    params:    ( path:string [ comment:string ] steal-lock:bool
+//                 [ current-rev:number ] )
+//    response:  ( lock:lockdesc )
+
+// Lock sends a "lock" command, asking to lock a path.
+// If steal is true, any existing lock on the path is broken.
+// If currentRev is not nil, the lock fails if the path has been
+// changed after that revision.
+func (c *Client) Lock(path string, comment string, steal bool, currentRev *int) (Lock, error) {
+	// the response is unmarshaled as a list of the only lockdesc.
+	locks, err := sendCommand[[]Lock](c, "lock", []any{
+		[]byte(path),
+		optString(comment),
+		steal,
+		[]any{currentRev},
+	})
+	if err != nil {
+		return Lock{}, fmt.Errorf("Lock: %w", err)
+	}
+	if len(locks) != 1 {
+		return Lock{}, fmt.Errorf("Lock: expected a lockdesc, got %d", len(locks))
+	}
+	return locks[0], nil
+}
+
+//  unlock
+//    params:    ( path:string [ token:string ] break-lock:bool )
+//    response:  ( )
+
+// Unlock sends an "unlock" command, asking to remove the lock on a path,
+// identified by its token.  If breakLock is true, the lock is removed
+// even if it is owned by another user, and the token can be empty.
+func (c *Client) Unlock(path string, token string, breakLock bool) error {
+	_, err := sendCommand[Item](c, "unlock", []any{
+		[]byte(path),
+		optString(token),
+		breakLock,
+	})
+	if err != nil {
+		return fmt.Errorf("Unlock: %w", err)
+	}
+	return nil
+}
+
+//  get-lock
+//    params:    ( path:string )
+//    response:  ( [ lock:lockdesc ] )
+
+// GetLock sends a "get-lock" command, asking for the lock on a path.
+// It returns nil if the path is not locked.
+func (c *Client) GetLock(path string) (*Lock, error) {
+	response, err := sendCommand[struct{ Lock []Lock }](c, "get-lock", []any{[]byte(path)})
+	if err != nil {
+		return nil, fmt.Errorf("GetLock: %w", err)
+	}
+	if len(response.Lock) == 0 {
+		return nil, nil
+	}
+	return &response.Lock[0], nil
+}
+
+//  get-locks
+//    params:    ( path:string ? [ depth:word ] )
+//    response   ( ( lock:lockdesc ... ) )
+
+// GetLocks sends a "get-locks" command, asking for all the locks
+// on a path and its children, up to the given depth
+// ("empty", "files", "immediates" or "infinity").
+func (c *Client) GetLocks(path string, depth string) ([]Lock, error) {
+	response, err := sendCommand[struct{ Locks []Lock }](c, "get-locks", []any{
+		[]byte(path),
+		optWord(depth),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetLocks: %w", err)
+	}
+	return response.Locks, nil
+}
+
+//  lock-many
+//    params:    ( [ comment:string ] steal-lock:bool ( ( path:string
+//                 [ current-rev:number ] ) ... ) )
+//    Before sending response, server sends lock cmd status and descriptions,
+//    ending with "done".
+//    lock-info: ( success ( lock:lockdesc ) ) | ( failure ( err:error ) )
+//                | done
+//    response: ( )
+
+// LockMany sends a "lock-many" command, asking to lock several paths,
+// each one with an optional current revision (see [Client.Lock]).
+// It returns the result of every path, sorted by path.
+func (c *Client) LockMany(comment string, steal bool, paths map[string]*int) ([]LockResult, error) {
+	targets := make([]any, 0, len(paths))
+	results := make([]LockResult, 0, len(paths))
+	for _, path := range sortedKeys(paths) {
+		targets = append(targets, []any{[]byte(path), []any{paths[path]}})
+		results = append(results, LockResult{Path: path})
+	}
+	entries, err := sendListCommand[Item](c, "lock-many", []any{
+		optString(comment),
+		steal,
+		targets,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("LockMany: %w", err)
+	}
+	if len(entries) != len(results) {
+		return nil, fmt.Errorf("LockMany: expected %d results, got %d", len(results), len(entries))
+	}
+	for i, entry := range entries {
+		resp, err := ParseResponse(entry)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		var locks []Lock
+		if err = Unmarshal(resp, &locks); err != nil {
+			return nil, fmt.Errorf("LockMany: %w", err)
+		}
+		if len(locks) != 1 {
+			return nil, fmt.Errorf("LockMany: expected a lockdesc, got %d", len(locks))
+		}
+		results[i].Lock = locks[0]
+	}
+	return results, nil
+}
+
+//  unlock-many
+//    params:    ( break-lock:bool ( ( path:string [ token:string ] ) ... ) )
+//    Before sending response, server sends unlocked paths, ending with "done".
+//    pre-response: ( success ( path:string ) ) | ( failure ( err:error ) )
+//                  | done
+//    response:  ( )
+
+// UnlockMany sends an "unlock-many" command, asking to remove the locks
+// on several paths, given their tokens (see [Client.Unlock]).
+// It returns the result of every path, sorted by path.
+func (c *Client) UnlockMany(breakLock bool, tokens map[string]string) ([]LockResult, error) {
+	targets := make([]any, 0, len(tokens))
+	results := make([]LockResult, 0, len(tokens))
+	for _, path := range sortedKeys(tokens) {
+		targets = append(targets, []any{[]byte(path), optString(tokens[path])})
+		results = append(results, LockResult{Path: path})
+	}
+	entries, err := sendListCommand[Item](c, "unlock-many", []any{
+		breakLock,
+		targets,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("UnlockMany: %w", err)
+	}
+	if len(entries) != len(results) {
+		return nil, fmt.Errorf("UnlockMany: expected %d results, got %d", len(results), len(entries))
+	}
+	for i, entry := range entries {
+		if _, err := ParseResponse(entry); err != nil {
+			results[i].Err = err
+		}
+	}
+	return results, nil
+}
+
+//  commit
+//    params:   ( logmsg:string ? ( ( lock-path:string lock-token:string ) ... )
+//                keep-locks:bool ? rev-props:proplist )
+//    response: ( )
+//    Upon receiving response, client switches to editor command set.
+//    Upon successful completion of edit, server sends auth-request.
+//    After auth exchange completes, server sends commit-info.
+//    commit-info: ( new-rev:number date:string author:string
+//                   ? ( post-commit-err:string ) )
+
+// Commit sends a "commit" command, and calls fn to describe the changes
+// using an [Editor], starting with OpenRoot.  When fn returns, the edit is
+// closed (or aborted, if fn returns an error) and the new revision created.
+// fn must not call CloseEdit or AbortEdit.
+//
+// lockTokens has the tokens of the locks on the paths being changed,
+// indexed by path.  Unless keepLocks is true, those locks are released
+// after the commit.  revProps are additional revision properties.
+func (c *Client) Commit(message string, revProps Props, lockTokens map[string]string, keepLocks bool, fn func(Editor) error) (CommitInfo, error) {
+	props := Props{PropRevLog: message}
+	for name, value := range revProps {
+		props[name] = value
+	}
+	locks := make([]any, 0, len(lockTokens))
+	for _, path := range sortedKeys(lockTokens) {
+		locks = append(locks, []any{[]byte(path), []byte(lockTokens[path])})
+	}
+	_, err := sendCommand[Item](c, "commit", []any{
+		[]byte(message),
+		locks,
+		keepLocks,
+		props,
+	})
+	if err != nil {
+		return CommitInfo{}, fmt.Errorf("Commit: %w", err)
+	}
+
+	editor := editorSender{conn: &c.conn}
+	if err = fn(editor); err != nil {
+		editor.AbortEdit()
+		return CommitInfo{}, err
+	}
+	if err = editor.CloseEdit(); err != nil {
+		return CommitInfo{}, fmt.Errorf("Commit: %w", err)
+	}
+	if err = c.handleAuth(); err != nil {
+		return CommitInfo{}, fmt.Errorf("Commit: %w", err)
+	}
+	var info struct {
+		Rev           uint
+		Date          []string
+		Author        []string
+		PostCommitErr []string
+	}
+	if err = c.conn.Read(&info); err != nil {
+		return CommitInfo{}, fmt.Errorf("Commit: reading commit-info: %w", err)
+	}
+	result := CommitInfo{Rev: info.Rev}
+	if len(info.Date) > 0 {
+		result.Date = info.Date[0]
+	}
+	if len(info.Author) > 0 {
+		result.Author = info.Author[0]
+	}
+	if len(info.PostCommitErr) > 0 {
+		result.PostCommitErr = info.PostCommitErr[0]
+	}
+	return result, nil
+}
+
+//  update
+//    params:   ( [ rev:number ] target:string recurse:bool
+//                ? depth:word send_copyfrom_args:bool ? ignore_ancestry:bool )
+//  switch
+//    params:   ( [ rev:number ] target:string recurse:bool url:string
+//                ? depth:word ? send_copyfrom_args:bool ignore_ancestry:bool )
+//  status
+//    params:   ( target:string recurse:bool ? [ rev:number ] ? depth:word )
+//  diff
+//    params:   ( [ rev:number ] target:string recurse:bool ignore-ancestry:bool
+//                url:string ? text-deltas:bool ? depth:word )
+//    Client switches to report command set.
+//    Upon finish-report, server sends auth-request.
+//    After auth exchange completes, server switches to editor command set.
+//    After edit completes, server sends response.
+//    response: ( )
+
+// Update sends the command in report.Command ("update", "switch", "status"
+// or "diff"), followed by the state of the working copy in report.Paths,
+// and calls the methods of e with the changes sent by the server, from
+// TargetRev to CloseEdit (or AbortEdit, if the edit fails).
+// The edit is anchored at the URL of the session; see [Report].
+func (c *Client) Update(report *Report, e Editor) error {
+	rev := []any{}
+	if report.Rev != nil {
+		rev = append(rev, *report.Rev)
+	}
+	depth := report.Depth
+	if depth == "" {
+		depth = "infinity"
+	}
+	recurse := depth != "empty" && depth != "files"
+	dst := c.Info.URL
+	if report.DstPath != "" {
+		dst += "/" + report.DstPath
+	}
+	var params []any
+	switch report.Command {
+	case "update":
+		params = []any{rev, []byte(report.Target), recurse, depth, report.SendCopyFrom, report.IgnoreAncestry}
+	case "switch":
+		params = []any{rev, []byte(report.Target), recurse, []byte(dst), depth, report.SendCopyFrom, report.IgnoreAncestry}
+	case "status":
+		params = []any{[]byte(report.Target), recurse, rev, depth}
+	case "diff":
+		params = []any{rev, []byte(report.Target), recurse, report.IgnoreAncestry, []byte(dst), report.TextDeltas, depth}
+	default:
+		return fmt.Errorf("Update: unknown command %q", report.Command)
+	}
+	if err := c.writeCommand(report.Command, params); err != nil {
+		return err
+	}
+	if err := c.handleAuth(); err != nil {
+		return fmt.Errorf("Update: %w", err)
+	}
+	for _, p := range report.Paths {
+		var cmd []any
+		switch {
+		case p.Deleted:
+			cmd = []any{"delete-path", []any{[]byte(p.Path)}}
+		case p.LinkPath != "":
+			cmd = []any{"link-path", []any{[]byte(p.Path), []byte(c.Info.URL + "/" + p.LinkPath), p.Rev,
+				p.StartEmpty, optString(p.LockToken), optWord(p.Depth)}}
+		default:
+			cmd = []any{"set-path", []any{[]byte(p.Path), p.Rev, p.StartEmpty, optString(p.LockToken), optWord(p.Depth)}}
+		}
+		if err := c.conn.Write(cmd); err != nil {
+			return fmt.Errorf("Update: sending report: %w", err)
+		}
+	}
+	if err := c.conn.Write([]any{"finish-report", []any{}}); err != nil {
+		return fmt.Errorf("Update: sending report: %w", err)
+	}
+	if err := c.handleAuth(); err != nil {
+		return fmt.Errorf("Update: %w", err)
+	}
+	if err := driveEditor(&c.conn, e); err != nil && !errors.Is(err, errEditAborted) {
+		return fmt.Errorf("Update: %w", err)
+	}
+	// a failed edit is followed by the failure, which is also
+	// the one of the editor if it was the editor which failed:
+	var item Item
+	if err := c.conn.ReadResponse(&item); err != nil {
+		return fmt.Errorf("Update: %w", err)
+	}
+	return nil
+}
+
+// optWord returns the representation of an optional word.
+func optWord(w string) []any {
+	if w == "" {
+		return []any{}
+	}
+	return []any{w}
+}
+
+// sortedKeys returns the keys of a map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,288 @@
+package svn
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipeClient returns a Client for a session in url, connected
+// to the returned conn, which plays the role of the server.
+func pipeClient(url string) (*Client, *conn) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	c := &Client{
+		tunnel: newTunnel(cr, cw),
+		Info:   ReposInfo{URL: "svn://test/repo"},
+		url:    url,
+	}
+	c.parent = url
+	return c, &conn{r: sr, w: sw}
+}
+
+// readCommand reads a command and returns its name.
+func readCommand(t *testing.T, s *conn) string {
+	var command struct {
+		Name   string
+		Params Item
+	}
+	if err := s.Read(&command); err != nil {
+		t.Errorf("server: reading command: %v", err)
+	}
+	return command.Name
+}
+
+func TestSessions(t *testing.T) {
+	c, s := pipeClient("svn://test/repo/trunk")
+	var commands []string
+	go func() {
+		for i := 0; i < 4; i++ {
+			commands = append(commands, readCommand(t, s))
+			s.WriteSuccess([]any{[]any{}, []byte{}})
+			s.WriteSuccess([]any{42})
+		}
+	}()
+
+	if _, err := c.OpenSession("svn://other/repo"); err == nil {
+		t.Errorf("OpenSession in another repository: expected error")
+	}
+	branch, err := c.OpenSession("svn://test/repo/branches/b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = branch.GetLatestRev(); err != nil {
+		t.Fatal(err)
+	}
+	if rev, err := c.GetLatestRev(); err != nil || rev != 42 {
+		t.Fatalf("GetLatestRev: %d, %v", rev, err)
+	}
+	want := []string{"reparent", "get-latest-rev", "reparent", "get-latest-rev"}
+	for i := range want {
+		if i >= len(commands) || commands[i] != want[i] {
+			t.Fatalf("commands: want %q, got %q", want, commands)
+		}
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	c, s := pipeClient("svn://test/repo")
+	go func() {
+		readCommand(t, s)
+		s.WriteSuccess([]any{[]any{}, []byte{}})
+		s.Write([]any{[]any{}, 1, []any{[]byte("alice")}, []any{}, []any{}})
+		// and no more entries: the client is left waiting.
+		var item Item
+		s.Read(&item)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.WithContext(ctx).Log(nil, nil, nil, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Log with expired context: got %v", err)
+	}
+	// the connection is not usable any more:
+	_, err = c.GetLatestRev()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetLatestRev after aborting: got %v", err)
+	}
+	if err = c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+func TestReparentContext(t *testing.T) {
+	c, s := pipeClient("svn://test/repo")
+	go func() {
+		for {
+			var command struct {
+				Name   string
+				Params Item
+			}
+			if err := s.Read(&command); err != nil {
+				return
+			}
+			s.WriteSuccess([]any{[]any{}, []byte{}})
+			s.WriteSuccess([]any{7})
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := c.WithContext(ctx).GetLatestRev(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	// the cancelled context is not the one of c:
+	if err := c.Reparent("svn://test/repo/trunk"); err != nil {
+		t.Errorf("Reparent after cancelling another context: %v", err)
+	}
+	if _, err := c.GetLatestRev(); err != nil {
+		t.Errorf("GetLatestRev after Reparent: %v", err)
+	}
+	c.Close()
+}
+
+func TestRecordReplay(t *testing.T) {
+	var recording bytes.Buffer
+	c, s := pipeClient("svn://test/repo")
+	c.conn.trace = RecordWriter(&recording)
+	go serveTest(s)
+	if _, err := c.GetLatestRev(); err != nil {
+		t.Fatal(err)
+	}
+	c.CheckPath("nothing", nil)
+	c.Close()
+
+	replay, err := NewReplayServer(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay.Recording) != 5 {
+		t.Fatalf("recording has %d items, want 5", len(replay.Recording))
+	}
+	c, s = pipeClient("svn://test/repo")
+	done := make(chan error)
+	go func() {
+		done <- replay.Serve(s.r, s.w)
+	}()
+	if rev, err := c.GetLatestRev(); err != nil || rev != 7 {
+		t.Errorf("replayed GetLatestRev: %d, %v", rev, err)
+	}
+	if _, err := c.CheckPath("nothing", nil); !errors.As(err, new(Error)) {
+		t.Errorf("replayed CheckPath: got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("replay: %v", err)
+	}
+	c.Close()
+
+	// a different request:
+	c, s = pipeClient("svn://test/repo")
+	go func() {
+		err := replay.Serve(s.r, s.w)
+		s.w.(io.Closer).Close()
+		done <- err
+	}()
+	c.GetLatestRev()
+	c.CheckPath("something", nil)
+	var rerr *ReplayError
+	if err := <-done; !errors.As(err, &rerr) || rerr.Index != 3 || len(rerr.Diff) != 1 ||
+		rerr.Diff[0] != `[1][0]: want "nothing", got "something"` {
+		t.Errorf("replay with another request: got %v", err)
+	}
+	c.Close()
+}
+
+// serverClient returns a Client connected to s, for a session in url.
+func serverClient(t *testing.T, s *Server, url string) *Client {
+	client, server := net.Pipe()
+	go s.Serve(server, server)
+	c, err := NewClient(client, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientChecksums(t *testing.T) {
+	contents := "hello\n"
+	var checksum string
+	s := &Server{
+		GetFileReader: func(path string, rev *uint, wantProps bool, wantContents bool) (File, io.Reader, error) {
+			return File{Rev: 3, Checksum: checksum}, strings.NewReader(contents), nil
+		},
+	}
+	c := serverClient(t, s, "svn://example.com/repo")
+
+	for _, test := range []struct {
+		name     string
+		checksum string
+		ok       bool
+	}{
+		{"md5", fmt.Sprintf("%x", md5.Sum([]byte(contents))), true},
+		{"sha1", fmt.Sprintf("%x", sha1.Sum([]byte(contents))), true},
+		{"corrupted md5", fmt.Sprintf("%x", md5.Sum([]byte("bye\n"))), false},
+		{"corrupted sha1", fmt.Sprintf("%x", sha1.Sum([]byte("bye\n"))), false},
+	} {
+		checksum = test.checksum
+		file, err := c.GetFile("a.txt", nil, false, true, false)
+		if test.ok {
+			if err != nil || string(file.Contents) != contents || file.Checksum != test.checksum {
+				t.Errorf("%s: got %+v, %v", test.name, file, err)
+			}
+			continue
+		}
+		var svnErr Error
+		if !errors.As(err, &svnErr) || svnErr.AprErr != 200014 {
+			t.Errorf("%s: got %v, want a checksum mismatch", test.name, err)
+		}
+	}
+
+	// the connection is still usable after a mismatch:
+	checksum = ""
+	var buf bytes.Buffer
+	if _, err := c.GetFileTo("a.txt", nil, &buf); err != nil || buf.String() != contents {
+		t.Errorf("GetFileTo: %q, %v", buf.String(), err)
+	}
+}
+
+func TestClientHistory(t *testing.T) {
+	// a.txt was created in trunk in r2, and moved to b.txt in r4.
+	paths := map[uint]string{2: "/trunk/a.txt", 3: "/trunk/a.txt", 4: "/trunk/b.txt", 5: "/trunk/b.txt"}
+	s := &Server{
+		CheckPath: func(path string, rev *uint) (NodeKind, error) {
+			if rev != nil && paths[*rev] == "/trunk/"+path {
+				return NodeFile, nil
+			}
+			return NodeNone, nil
+		},
+		GetDeletedRev: func(path string, pegRev uint, endRev uint) (*uint, error) {
+			if path == "a.txt" && pegRev < 4 && endRev >= 4 {
+				rev := uint(4)
+				return &rev, nil
+			}
+			return nil, nil
+		},
+		GetLocations: func(path string, pegRev uint, revs []uint) (map[uint]string, error) {
+			locations := make(map[uint]string)
+			if paths[pegRev] != "/trunk/"+path {
+				return locations, nil
+			}
+			for _, rev := range revs {
+				if p, ok := paths[rev]; ok {
+					locations[rev] = p
+				}
+			}
+			return locations, nil
+		},
+	}
+	c := serverClient(t, s, "svn://example.com/repo/trunk")
+
+	rev := 3
+	if kind, err := c.CheckPath("a.txt", &rev); err != nil || kind != NodeFile {
+		t.Errorf("CheckPath: %q, %v", kind, err)
+	}
+	if rev, err := c.GetDeletedRev("a.txt", 2, 5); err != nil || rev != 4 {
+		t.Errorf("GetDeletedRev: %d, %v", rev, err)
+	}
+	if rev, err := c.GetDeletedRev("b.txt", 4, 5); err != nil || rev != InvalidRevision {
+		t.Errorf("GetDeletedRev of a live path: %d, %v", rev, err)
+	}
+
+	// b.txt@5 was /trunk/a.txt in r2:
+	if p, err := c.ResolvePeg("b.txt", 5, 2); err != nil || p != "/trunk/a.txt" {
+		t.Errorf("ResolvePeg: %q, %v", p, err)
+	}
+	var svnErr Error
+	if _, err := c.ResolvePeg("b.txt", 5, 1); !errors.As(err, &svnErr) || svnErr.AprErr != 195012 {
+		t.Errorf("ResolvePeg before its creation: got %v", err)
+	}
+}
@@ -8,14 +8,21 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cespedes/svn"
+	"github.com/cespedes/svn/mergeinfo"
 )
 
 func main() {
 	err := run(os.Args, os.Stdout)
+	for _, c := range sessions {
+		c.Close()
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err.Error())
 		os.Exit(1)
@@ -28,11 +35,23 @@ func run(args []string, stdout io.Writer) error {
 	var rev1, rev2 int
 	var lrev1, lrev2 *int
 	var verbose bool
+	var showInherited bool
+	var message string
+	var force bool
+	var trace bool
 	f := flag.NewFlagSet(args[0], flag.ExitOnError)
 	f.BoolVar(&verbose, "v", false, "verbose")
+	f.BoolVar(&showInherited, "show-inherited-props", false, "show inherited properties (propget and proplist)")
 	f.StringVar(&revStr, "r", "", "revision (rev or rev1:rev2")
+	f.StringVar(&message, "m", "", "lock comment (lock)")
+	f.BoolVar(&force, "force", false, "steal or break locks (lock and unlock)")
+	f.BoolVar(&trace, "trace", false, "dump the conversation with the server to stderr")
 	f.Parse(args[1:])
 
+	if trace {
+		connectOpts = append(connectOpts, svn.WithTrace(os.Stderr))
+	}
+
 	if revStr != "" {
 		srev1, srev2, found := strings.Cut(revStr, ":")
 		rev1, err = strconv.Atoi(srev1)
@@ -54,7 +73,7 @@ func run(args []string, stdout io.Writer) error {
 		help(stdout)
 		return nil
 	}
-	if len(args) != 2 {
+	if len(args) < 2 {
 		return fmt.Errorf("type 'go-svn help' for usage")
 	}
 	switch args[0] {
@@ -65,31 +84,162 @@ func run(args []string, stdout io.Writer) error {
 		if lrev2 != nil {
 			return errors.New("subcommand 'info' does not accept revision range")
 		}
-		return svnInfo(args[1], lrev1, stdout)
+		for i, target := range args[1:] {
+			repo, lrev, err := resolveTarget(target, lrev1)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Fprintln(stdout)
+			}
+			if err = svnInfo(repo, lrev, stdout); err != nil {
+				return err
+			}
+		}
+		return nil
 	case "cat":
 		if verbose {
-			return errors.New("subcommand 'info' does not accept option '-v'")
+			return errors.New("subcommand 'cat' does not accept option '-v'")
 		}
 		if lrev2 != nil {
-			return errors.New("subcommand 'info' does not accept revision range")
+			return errors.New("subcommand 'cat' does not accept revision range")
+		}
+		if len(args) != 2 {
+			return errors.New("subcommand 'cat' needs exactly one argument")
+		}
+		repo, lrev, err := resolveTarget(args[1], lrev1)
+		if err != nil {
+			return err
 		}
-		return svnCat(args[1], lrev1, stdout)
+		return svnCat(repo, lrev, stdout)
 	case "ls":
 		if lrev2 != nil {
 			return errors.New("subcommand 'ls' does not accept revision range")
 		}
-		return svnLs(args[1], lrev1, verbose, stdout)
+		if len(args) != 2 {
+			return errors.New("subcommand 'ls' needs exactly one argument")
+		}
+		repo, lrev, err := resolveTarget(args[1], lrev1)
+		if err != nil {
+			return err
+		}
+		return svnLs(repo, lrev, verbose, stdout)
 	case "log":
-		return svnLog(args[1], lrev1, lrev2, verbose, stdout)
+		if len(args) != 2 {
+			return errors.New("subcommand 'log' needs exactly one argument")
+		}
+		// the log starts at the youngest revision, so the path
+		// has to be resolved there.
+		start := lrev1
+		if lrev1 != nil && lrev2 != nil && *lrev2 > *lrev1 {
+			start = lrev2
+		}
+		repo, lrev, err := resolveTarget(args[1], start)
+		if err != nil {
+			return err
+		}
+		if lrev1 == nil {
+			lrev1 = lrev
+		}
+		return svnLog(repo, lrev1, lrev2, verbose, stdout)
+	case "blame", "praise", "annotate", "ann":
+		if len(args) != 2 {
+			return errors.New("subcommand 'blame' needs exactly one argument")
+		}
+		// a single revision is the end of the range:
+		start, end := lrev2, lrev1
+		if lrev2 != nil {
+			start, end = lrev1, lrev2
+		}
+		repo, lrev, err := resolveTarget(args[1], end)
+		if err != nil {
+			return err
+		}
+		return svnBlame(repo, start, lrev, verbose, stdout)
+	case "propget", "pget", "pg":
+		if verbose {
+			return errors.New("subcommand 'propget' does not accept option '-v'")
+		}
+		if lrev2 != nil {
+			return errors.New("subcommand 'propget' does not accept revision range")
+		}
+		if len(args) != 3 {
+			return errors.New("subcommand 'propget' needs exactly two arguments")
+		}
+		repo, lrev, err := resolveTarget(args[2], lrev1)
+		if err != nil {
+			return err
+		}
+		return svnPropget(args[1], repo, lrev, showInherited, stdout)
+	case "proplist", "plist", "pl":
+		if lrev2 != nil {
+			return errors.New("subcommand 'proplist' does not accept revision range")
+		}
+		if len(args) != 2 {
+			return errors.New("subcommand 'proplist' needs exactly one argument")
+		}
+		repo, lrev, err := resolveTarget(args[1], lrev1)
+		if err != nil {
+			return err
+		}
+		return svnProplist(repo, lrev, verbose, showInherited, stdout)
+	case "mergeinfo":
+		// this subcommand has its own options, after its name:
+		var showRevs string
+		fm := flag.NewFlagSet("mergeinfo", flag.ExitOnError)
+		fm.StringVar(&showRevs, "show-revs", "merged", "show 'merged' or 'eligible' revisions")
+		fm.Parse(args[1:])
+		if fm.NArg() != 2 {
+			return errors.New("subcommand 'mergeinfo' needs exactly two arguments")
+		}
+		if showRevs != "merged" && showRevs != "eligible" {
+			return fmt.Errorf("'%s' is not a valid --show-revs value", showRevs)
+		}
+		return svnMergeinfo(fm.Arg(0), fm.Arg(1), showRevs, stdout)
+	case "lock", "unlock":
+		if verbose {
+			return fmt.Errorf("subcommand '%s' does not accept option '-v'", args[0])
+		}
+		if lrev1 != nil {
+			return fmt.Errorf("subcommand '%s' does not accept option '-r'", args[0])
+		}
+		if len(args) != 2 {
+			return fmt.Errorf("subcommand '%s' needs exactly one argument", args[0])
+		}
+		if args[0] == "unlock" {
+			return svnUnlock(args[1], force, stdout)
+		}
+		return svnLock(args[1], message, force, stdout)
 	default:
 		return fmt.Errorf(`unknown subcommand: '%s'
 Type 'svn help' for usage`, args[0])
 	}
-	return nil
+}
+
+// sessions are the open sessions, one for every repository.
+var sessions []*svn.Client
+
+// connectOpts are the options used to connect to the servers.
+var connectOpts []svn.Option
+
+// connect returns a session for a URL, reusing the connection
+// of an open session in the same repository if there is one.
+func connect(address string) (*svn.Client, error) {
+	for _, c := range sessions {
+		if session, err := c.OpenSession(address); err == nil {
+			return session, nil
+		}
+	}
+	c, err := svn.Connect(address, connectOpts...)
+	if err != nil {
+		return nil, err
+	}
+	sessions = append(sessions, c)
+	return c, nil
 }
 
 func svnInfo(repo string, lrev *int, stdout io.Writer) error {
-	c, err := svn.Connect(repo)
+	c, err := connect(repo)
 
 	if err != nil {
 		return err
@@ -100,7 +250,11 @@ func svnInfo(repo string, lrev *int, stdout io.Writer) error {
 		log.Fatal(err)
 	}
 
-	stat, err := c.Stat("", nil)
+	if lrev != nil {
+		rev = *lrev
+	}
+
+	stat, err := c.Stat("", lrev)
 	if err != nil {
 		return err
 	}
@@ -115,27 +269,86 @@ func svnInfo(repo string, lrev *int, stdout io.Writer) error {
 	fmt.Fprintf(stdout, "Last Changed Rev: %d\n", stat.CreatedRev)
 	fmt.Fprintf(stdout, "Last Changed Date: %s\n", stat.CreatedDate)
 
+	if stat.Kind != svn.NodeFile {
+		return nil
+	}
+	lock, err := c.GetLock("")
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		fmt.Fprintf(stdout, "Lock Token: %s\n", lock.Token)
+		fmt.Fprintf(stdout, "Lock Owner: %s\n", lock.Owner)
+		fmt.Fprintf(stdout, "Lock Created: %s\n", lock.Created)
+		if lock.Expires != "" {
+			fmt.Fprintf(stdout, "Lock Expires: %s\n", lock.Expires)
+		}
+		if lock.Comment != "" {
+			lines := strings.Count(lock.Comment, "\n") + 1
+			slines := "1 line"
+			if lines > 1 {
+				slines = fmt.Sprintf("%d lines", lines)
+			}
+			fmt.Fprintf(stdout, "Lock Comment (%s):\n%s\n", slines, lock.Comment)
+		}
+	}
+
+	return nil
+}
+
+func svnLock(repo string, message string, force bool, stdout io.Writer) error {
+	c, err := connect(repo)
+	if err != nil {
+		return err
+	}
+
+	lock, err := c.Lock("", message, force, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "'%s' locked by user '%s'.\n", path.Base(repo), lock.Owner)
+
+	return nil
+}
+
+func svnUnlock(repo string, force bool, stdout io.Writer) error {
+	c, err := connect(repo)
+	if err != nil {
+		return err
+	}
+
+	lock, err := c.GetLock("")
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return fmt.Errorf("'%s' is not locked in the repository", repo)
+	}
+	if err = c.Unlock("", lock.Token, force); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "'%s' unlocked.\n", path.Base(repo))
+
 	return nil
 }
 
 func svnCat(repo string, lrev *int, stdout io.Writer) error {
-	c, err := svn.Connect(repo)
+	c, err := connect(repo)
 
 	if err != nil {
 		return err
 	}
 
-	_, content, err := c.GetFile("", lrev, true, true)
+	_, err = c.GetFileTo("", lrev, stdout)
 	if err != nil {
 		return err
 	}
-	fmt.Fprint(stdout, string(content))
 
 	return nil
 }
 
 func svnLs(repo string, lrev *int, verbose bool, stdout io.Writer) error {
-	c, err := svn.Connect(repo)
+	c, err := connect(repo)
 
 	if err != nil {
 		return err
@@ -209,7 +422,7 @@ func svnLs(repo string, lrev *int, verbose bool, stdout io.Writer) error {
 }
 
 func svnLog(repo string, lrev1 *int, lrev2 *int, verbose bool, stdout io.Writer) error {
-	c, err := svn.Connect(repo)
+	c, err := connect(repo)
 
 	if err != nil {
 		return err
@@ -245,14 +458,282 @@ func svnLog(repo string, lrev1 *int, lrev2 *int, verbose bool, stdout io.Writer)
 	return nil
 }
 
+func svnBlame(repo string, lrev1 *int, lrev2 *int, verbose bool, stdout io.Writer) error {
+	c, err := connect(repo)
+	if err != nil {
+		return err
+	}
+
+	lines, err := c.Blame("", lrev1, lrev2)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if verbose {
+			date := line.Date
+			if t, err := time.Parse(time.RFC3339Nano, line.Date); err == nil {
+				date = t.Local().Format("2006-01-02 15:04:05 -0700 (Mon, 02 Jan 2006)")
+			}
+			fmt.Fprintf(stdout, "%6d %10s %s %s\n", line.Rev, line.Author, date, line.Text)
+		} else {
+			fmt.Fprintf(stdout, "%6d %10.10s %s\n", line.Rev, line.Author, line.Text)
+		}
+	}
+
+	return nil
+}
+
+func svnMergeinfo(source string, target string, showRevs string, stdout io.Writer) error {
+	src, err := connect(source)
+	if err != nil {
+		return err
+	}
+	srcURL, err := url.Parse(source)
+	if err != nil {
+		return err
+	}
+	rootURL, err := url.Parse(src.Info.URL)
+	if err != nil {
+		return err
+	}
+	srcPath := "/" + strings.Trim(strings.TrimPrefix(srcURL.Path, rootURL.Path), "/")
+
+	tgt, err := connect(target)
+	if err != nil {
+		return err
+	}
+	if tgt.Info.UUID != src.Info.UUID {
+		return fmt.Errorf("'%s' and '%s' are not in the same repository", source, target)
+	}
+	catalog, err := tgt.GetMergeinfo([]string{""}, nil, "inherited", false)
+	if err != nil {
+		return err
+	}
+	merged := catalog[""]
+	// the revisions in the history of the target are
+	// already in it, as if they had been merged:
+	history := mergeinfo.Mergeinfo{}
+	segments, err := tgt.GetLocationSegments("", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		if seg.Path == "" || seg.RangeEnd == 0 {
+			continue
+		}
+		p := "/" + strings.TrimPrefix(seg.Path, "/")
+		history = history.Union(mergeinfo.Mergeinfo{p: {{Start: max(seg.RangeStart, 1), End: seg.RangeEnd}}})
+	}
+	inTarget := merged.Union(history)[srcPath]
+
+	// the log follows copies: only the revisions in which the source
+	// was in its current path are candidates.
+	own := mergeinfo.Mergeinfo{}
+	segments, err = src.GetLocationSegments("", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	for _, seg := range segments {
+		if "/"+strings.TrimPrefix(seg.Path, "/") != srcPath || seg.RangeEnd == 0 {
+			continue
+		}
+		own = own.Union(mergeinfo.Mergeinfo{srcPath: {{Start: max(seg.RangeStart, 1), End: seg.RangeEnd}}})
+	}
+
+	logs, err := src.Log(nil, nil, nil, false)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(logs, func(a, b svn.LogEntry) int {
+		return int(a.Rev) - int(b.Rev)
+	})
+	for _, l := range logs {
+		if l.Rev == 0 || !own[srcPath].Contains(l.Rev) {
+			continue
+		}
+		switch {
+		case showRevs == "merged" && merged[srcPath].Contains(l.Rev),
+			showRevs == "eligible" && !inTarget.Contains(l.Rev):
+			fmt.Fprintf(stdout, "r%d\n", l.Rev)
+		}
+	}
+
+	return nil
+}
+
+// getProps returns the properties of the node pointed by repo,
+// whether it is a file or a directory, and optionally the ones
+// it inherits from its parents.
+// It also returns the URL of the repository root.
+func getProps(repo string, lrev *int, wantIProps bool) (svn.Props, svn.InheritedProps, string, error) {
+	c, err := connect(repo)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	kind, err := c.CheckPath("", lrev)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	switch kind {
+	case svn.NodeFile:
+		file, err := c.GetFile("", lrev, true, false, wantIProps)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return file.Props, file.InheritedProps, c.Info.URL, nil
+	case svn.NodeDir:
+		dir, err := c.GetDir("", lrev, true, false, nil, wantIProps)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return dir.Props, dir.InheritedProps, c.Info.URL, nil
+	default:
+		return nil, nil, "", fmt.Errorf("path '%s' does not exist", repo)
+	}
+}
+
+// inheritedURL returns the URL of a path in the inherited properties
+// (which is relative to the root of the repository).
+func inheritedURL(root string, path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return root
+	}
+	return strings.TrimSuffix(root, "/") + "/" + path
+}
+
+func svnPropget(name string, repo string, lrev *int, showInherited bool, stdout io.Writer) error {
+	props, iprops, root, err := getProps(repo, lrev, showInherited)
+	if err != nil {
+		return err
+	}
+	if showInherited {
+		for _, ip := range iprops {
+			if value, ok := ip.Props[name]; ok {
+				fmt.Fprintf(stdout, "%s - %s\n", inheritedURL(root, ip.Path), value)
+			}
+		}
+		if value, ok := props[name]; ok {
+			fmt.Fprintf(stdout, "%s - %s\n", repo, value)
+		}
+		return nil
+	}
+	value, ok := props[name]
+	if !ok {
+		return fmt.Errorf("property '%s' not found on '%s'", name, repo)
+	}
+	fmt.Fprintln(stdout, value)
+
+	return nil
+}
+
+func svnProplist(repo string, lrev *int, verbose bool, showInherited bool, stdout io.Writer) error {
+	props, iprops, root, err := getProps(repo, lrev, showInherited)
+	if err != nil {
+		return err
+	}
+	printProps := func(props svn.Props) {
+		for _, name := range props.Names() {
+			fmt.Fprintf(stdout, "  %s\n", name)
+			if verbose {
+				for _, line := range strings.Split(strings.TrimSuffix(props[name], "\n"), "\n") {
+					fmt.Fprintf(stdout, "    %s\n", line)
+				}
+			}
+		}
+	}
+	for _, ip := range iprops {
+		if len(ip.Props) == 0 {
+			continue
+		}
+		fmt.Fprintf(stdout, "Inherited properties on '%s',\nfrom '%s':\n", repo, inheritedURL(root, ip.Path))
+		printProps(ip.Props)
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	fmt.Fprintf(stdout, "Properties on '%s':\n", repo)
+	printProps(props)
+
+	return nil
+}
+
+// splitPeg splits a target of the form "URL@PEG" into the URL and the
+// peg revision, which is nil if there is none or if it is "HEAD".
+func splitPeg(target string) (string, *int, error) {
+	i := strings.LastIndexByte(target, '@')
+	if i < 0 || strings.ContainsRune(target[i:], '/') {
+		return target, nil, nil
+	}
+	peg := target[i+1:]
+	target = target[:i]
+	if peg == "" || peg == "HEAD" {
+		return target, nil, nil
+	}
+	rev, err := strconv.Atoi(peg)
+	if err != nil {
+		return "", nil, fmt.Errorf("syntax error in revision argument '%s'", peg)
+	}
+	return target, &rev, nil
+}
+
+// resolveTarget parses a target of the form "URL[@PEG]" and returns the URL
+// where that node was in revision opRev, following its history across
+// renames and copies, and the revision in which the operation should be done.
+// As in Subversion, the default peg revision is HEAD, and the
+// default operative revision is the peg revision.
+func resolveTarget(target string, opRev *int) (string, *int, error) {
+	repo, pegRev, err := splitPeg(target)
+	if err != nil {
+		return "", nil, err
+	}
+	if opRev == nil {
+		return repo, pegRev, nil
+	}
+	if pegRev != nil && *pegRev == *opRev {
+		return repo, opRev, nil
+	}
+
+	c, err := connect(repo)
+	if err != nil {
+		return "", nil, err
+	}
+	if pegRev == nil {
+		head, err := c.GetLatestRev()
+		if err != nil {
+			return "", nil, err
+		}
+		if head == *opRev {
+			return repo, opRev, nil
+		}
+		pegRev = &head
+	}
+	p, err := c.ResolvePeg("", *pegRev, *opRev)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSuffix(c.Info.URL, "/") + (&url.URL{Path: p}).EscapedPath(), opRev, nil
+}
+
 func help(stdout io.Writer) {
-	fmt.Fprintln(stdout, `usage: go-svn [-v] [-r revision[:revision2]] <subcommand> <repo>
+	fmt.Fprintln(stdout, `usage: go-svn [-v] [-r revision[:revision2]] [-show-inherited-props] [-m message] [-force] [-trace] <subcommand> <args>
+
+Every URL can be followed by "@PEG" to specify the revision in which
+it is looked up; if "-r" is also given, its history is followed
+to find where it was in that revision.
 
 Available subcommands:
-   info
-   cat
-   ls
-   log
+   info URL...
+   cat URL
+   ls URL
+   log URL
+   blame (praise, annotate, ann) URL
+   propget (pget, pg) PROPNAME URL
+   proplist (plist, pl) URL
+   mergeinfo [--show-revs merged|eligible] SOURCE TARGET
+   lock URL
+   unlock URL
 
 go-svn is a client for the Subversion protocol.`)
 }
@@ -12,6 +12,8 @@ type conn struct {
 	r io.Reader
 	w io.Writer
 	i *Itemizer
+	// trace, if not nil, is called with every Item sent or received.
+	trace func(dir Direction, item Item)
 }
 
 // Write converts "what" into an Item,
@@ -21,6 +23,9 @@ func (c *conn) Write(what any) error {
 	if err != nil {
 		return nil
 	}
+	if c.trace != nil {
+		c.trace(Sent, item)
+	}
 	_, err = c.w.Write([]byte(item.String() + " "))
 	return err
 }
@@ -68,6 +73,9 @@ func (c *conn) Read(where any) error {
 	if err != nil {
 		return err
 	}
+	if c.trace != nil {
+		c.trace(Received, item)
+	}
 	return Unmarshal(item, where)
 }
 
@@ -0,0 +1,260 @@
+package svn
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// XML namespaces used by Subversion's WebDAV (HTTPv2) protocol,
+// as implemented by mod_dav_svn.
+const (
+	davNS        = "DAV:"
+	davSVNNS     = "svn:"                                       // reports
+	davLiveNS    = "http://subversion.tigris.org/xmlns/dav/"    // live properties
+	davPropSVNNS = "http://subversion.tigris.org/xmlns/svn/"    // "svn:*" properties
+	davPropNS    = "http://subversion.tigris.org/xmlns/custom/" // other properties
+	davApacheNS  = "http://apache.org/dav/xmlns"                // errors
+	davCapPrefix = "http://subversion.tigris.org/xmlns/dav/svn/"
+)
+
+// Headers used by mod_dav_svn.
+const (
+	davYoungestRevHeader = "SVN-Youngest-Rev"
+	davUUIDHeader        = "SVN-Repository-UUID"
+	davRootHeader        = "SVN-Repository-Root"
+	davMeHeader          = "SVN-Me-Resource"
+	davRevRootHeader     = "SVN-Rev-Root-Stub"
+	davRevHeader         = "SVN-Rev-Stub"
+	davTxnRootHeader     = "SVN-Txn-Root-Stub"
+	davTxnHeader         = "SVN-Txn-Stub"
+	davTxnNameHeader     = "SVN-Txn-Name"
+	davPostsHeader       = "SVN-Supported-Posts"
+	davMergeinfoHeader   = "SVN-Repository-MergeInfo"
+	davVersionHeader     = "X-SVN-Version-Name"
+	davOptionsHeader     = "X-SVN-Options"
+	davBaseMD5Header     = "X-SVN-Base-Fulltext-MD5"
+	davResultMD5Header   = "X-SVN-Result-Fulltext-MD5"
+	davLockOwnerHeader   = "X-SVN-Lock-Owner"
+	davCreationHeader    = "X-SVN-Creation-Date"
+)
+
+// MIME types of the bodies of some requests and responses.
+const (
+	davSvndiffType = "application/vnd.svn-svndiff"
+	davSkelType    = "application/vnd.svn-skel"
+	davXMLType     = `text/xml; charset="utf-8"`
+)
+
+// davCapabilities maps the capabilities of the SVN protocol
+// to the names advertised in the "DAV" header of OPTIONS responses
+// (after davCapPrefix).
+var davCapabilities = map[string]string{
+	"depth":              "depth",
+	"mergeinfo":          "mergeinfo",
+	"log-revprops":       "log-revprops",
+	"atomic-revprops":    "atomic-revprops",
+	"partial-replay":     "partial-replay",
+	"inherited-props":    "inherited-props",
+	"file-revs-reverse":  "reverse-file-revs",
+	"ephemeral-txnprops": "ephemeral-txnprops",
+	"list":               "list",
+}
+
+// davPropName returns the name of the node property represented
+// by an XML element, or false if the element is not a node property.
+func davPropName(name xml.Name) (string, bool) {
+	switch name.Space {
+	case davPropSVNNS:
+		return "svn:" + name.Local, true
+	case davPropNS:
+		return name.Local, true
+	}
+	return "", false
+}
+
+// davPropXMLName returns the XML element which represents
+// a node property.
+func davPropXMLName(prop string) xml.Name {
+	if local, ok := strings.CutPrefix(prop, "svn:"); ok {
+		return xml.Name{Space: davPropSVNNS, Local: local}
+	}
+	return xml.Name{Space: davPropNS, Local: prop}
+}
+
+// xmlEscape returns s with the characters special in XML escaped.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// xmlSafe reports whether s can be sent as XML text:
+// values which cannot (such as binary properties)
+// are sent encoded in base64.
+func xmlSafe(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// xmlValue returns the attributes and the contents of an element
+// with value s: either s escaped, or s in base64 with an encoding
+// attribute (named attr).
+func xmlValue(attr string, s string) (string, string) {
+	if xmlSafe(s) {
+		return "", xmlEscape(s)
+	}
+	return fmt.Sprintf(` %s="base64"`, attr), base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// A davValue is an XML element whose text may be encoded in base64.
+// The encoding attribute is in no namespace in reports, and in
+// davLiveNS in properties.
+type davValue struct {
+	Encoding     string `xml:"encoding,attr"`
+	PropEncoding string `xml:"http://subversion.tigris.org/xmlns/dav/ encoding,attr"`
+	Text         string `xml:",chardata"`
+}
+
+// String returns the decoded value.
+func (v davValue) String() string {
+	if v.Encoding == "base64" || v.PropEncoding == "base64" {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v.Text))
+		if err == nil {
+			return string(b)
+		}
+	}
+	return v.Text
+}
+
+// A davMultistatus is the body of a PROPFIND response.
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Prop struct {
+		Props []davProp `xml:",any"`
+	} `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+// A davProp is any property in a PROPFIND response.
+type davProp struct {
+	XMLName xml.Name
+	davValue
+	// Children are the elements inside the property,
+	// such as "collection" in "resourcetype".
+	Children []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// props returns the properties of a response with a successful status.
+func (r davResponse) props() map[xml.Name]davProp {
+	props := make(map[xml.Name]davProp)
+	for _, ps := range r.Propstats {
+		if fields := strings.Fields(ps.Status); len(fields) > 1 && fields[1] != "200" {
+			continue
+		}
+		for _, p := range ps.Prop.Props {
+			props[p.XMLName] = p
+		}
+	}
+	return props
+}
+
+// davError returns the error represented by a failed response:
+// either the one described in the body, as mod_dav_svn does,
+// or one made up from the status.
+func davError(resp *http.Response) error {
+	var body struct {
+		Human struct {
+			Code int    `xml:"errcode,attr"`
+			Text string `xml:",chardata"`
+		} `xml:"http://apache.org/dav/xmlns human-readable"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := xml.Unmarshal(data, &body); err == nil && body.Human.Text != "" {
+		code := body.Human.Code
+		if code == 0 {
+			code = 175002 // SVN_ERR_RA_DAV_REQUEST_FAILED
+		}
+		return Error{AprErr: code, Message: strings.TrimSpace(body.Human.Text)}
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return Error{
+			AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+			Message: fmt.Sprintf("'%s' path not found", resp.Request.URL.Path),
+		}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return Error{
+			AprErr:  170001, // SVN_ERR_RA_NOT_AUTHORIZED
+			Message: fmt.Sprintf("Access to '%s' forbidden", resp.Request.URL.Path),
+		}
+	case http.StatusLocked:
+		return Error{
+			AprErr:  160035, // SVN_ERR_FS_PATH_ALREADY_LOCKED
+			Message: fmt.Sprintf("'%s' is already locked", resp.Request.URL.Path),
+		}
+	}
+	return Error{
+		AprErr:  175002, // SVN_ERR_RA_DAV_REQUEST_FAILED
+		Message: fmt.Sprintf("Unexpected HTTP status %s on '%s'", resp.Status, resp.Request.URL.Path),
+	}
+}
+
+// walkXML reads an XML document and calls fn for every element.
+// fn can consume the element (with d.DecodeElement or d.Skip);
+// if it does not, the elements inside it are visited too.
+func walkXML(r io.Reader, fn func(d *xml.Decoder, start xml.StartElement) error) error {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return Error{
+				AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+				Message: fmt.Sprintf("Malformed XML: %v", err),
+			}
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if err = fn(d, start); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// xmlRev parses a revision number in an XML element or attribute.
+func xmlRev(s string) (uint, error) {
+	rev, err := strconv.ParseUint(strings.TrimSpace(s), 10, 0)
+	if err != nil {
+		return 0, Error{
+			AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+			Message: fmt.Sprintf("Invalid revision %q", s),
+		}
+	}
+	return uint(rev), nil
+}
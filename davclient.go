@@ -0,0 +1,1817 @@
+package svn
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cespedes/svn/mergeinfo"
+	"github.com/cespedes/svn/svndiff"
+)
+
+// WithHTTPClient returns an Option which makes the sessions for "http"
+// and "https" URLs send their requests with c, instead of with
+// [http.DefaultClient].
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = c
+	}
+}
+
+// A davSession is a session in a repository served through HTTP,
+// using the WebDAV-based protocol of mod_dav_svn ("HTTPv2").
+//
+// Every operation is one or more HTTP requests: OPTIONS to discover the
+// repository, PROPFIND for the nodes and their properties, GET for the
+// contents of files, REPORT for the history and the updates, LOCK and
+// UNLOCK for the locks, and a transaction (created with POST and merged
+// with MERGE) to commit.
+type davSession struct {
+	client *http.Client
+	// ctx is the context of the requests, if any.
+	ctx context.Context
+	// base is the scheme and host of the URLs ("https://example.com").
+	base string
+	user *url.Userinfo
+	url  string
+	info ReposInfo
+	// root is the path of the repository in the server, and path
+	// the one of the session in the repository ("" for its root).
+	root string
+	path string
+	// me, revRoot, txnRoot and txn are the paths of the special
+	// resources announced by the server.
+	me      string
+	revRoot string
+	txnRoot string
+	txn     string
+	closed  bool
+}
+
+var _ Session = (*davSession)(nil)
+
+// davDriver opens sessions for "http" and "https" URLs.
+func davDriver(ctx context.Context, address string, opts ...Option) (Session, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("svn connect: parsing %q: %w", address, err)
+	}
+	o := newOptions(opts)
+	s := &davSession{
+		client: o.httpClient,
+		ctx:    ctx,
+		base:   u.Scheme + "://" + u.Host,
+		user:   u.User,
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+	if err = s.open(u); err != nil {
+		return nil, fmt.Errorf("svn: connect to %q: %w", address, err)
+	}
+	s.ctx = nil
+	return s, nil
+}
+
+// open sends an OPTIONS request for the URL of the session,
+// and fills in the information about the repository.
+func (s *davSession) open(u *url.URL) error {
+	header, err := s.options(u.Path)
+	if err != nil {
+		return err
+	}
+	resource := func(name string) string {
+		v := header.Get(name)
+		if r, err := url.Parse(v); err == nil {
+			return r.Path
+		}
+		return v
+	}
+	s.me = resource(davMeHeader)
+	if s.me == "" {
+		return Error{
+			AprErr:  200007, // SVN_ERR_UNSUPPORTED_FEATURE
+			Message: fmt.Sprintf("The server at '%s' does not support the HTTP/DAV protocol of Subversion 1.7 or later", s.base),
+		}
+	}
+	s.root = strings.TrimSuffix(resource(davRootHeader), "/")
+	s.revRoot = resource(davRevRootHeader)
+	s.txnRoot = resource(davTxnRootHeader)
+	s.txn = resource(davTxnHeader)
+	p := strings.TrimSuffix(u.Path, "/")
+	if p != s.root && !strings.HasPrefix(p, s.root+"/") {
+		return Error{
+			AprErr:  175002, // SVN_ERR_RA_DAV_REQUEST_FAILED
+			Message: fmt.Sprintf("The repository root '%s' does not contain '%s'", s.root, u.Path),
+		}
+	}
+	s.path = strings.TrimPrefix(p, s.root)
+
+	root := url.URL{Scheme: u.Scheme, Host: u.Host, Path: s.root}
+	s.info.URL = root.String()
+	s.info.UUID = header.Get(davUUIDHeader)
+	u.User = nil
+	s.url = u.String()
+
+	for _, value := range header.Values("DAV") {
+		for _, token := range strings.Split(value, ",") {
+			name, ok := strings.CutPrefix(strings.TrimSpace(token), davCapPrefix)
+			if !ok {
+				continue
+			}
+			for capability, dav := range davCapabilities {
+				if dav == name {
+					s.info.Capabilities = append(s.info.Capabilities, capability)
+				}
+			}
+		}
+	}
+	if header.Get(davMergeinfoHeader) == "yes" && !s.HasCapability("mergeinfo") {
+		s.info.Capabilities = append(s.info.Capabilities, "mergeinfo")
+	}
+	// revision properties are always sent when creating a transaction:
+	s.info.Capabilities = append(s.info.Capabilities, "commit-revprops")
+	slices.Sort(s.info.Capabilities)
+	return nil
+}
+
+// options sends an OPTIONS request, and returns the headers of the response.
+func (s *davSession) options(p string) (http.Header, error) {
+	resp, err := s.request("OPTIONS", p, map[string]string{"Content-Type": davXMLType},
+		davXMLHeader+`<D:options xmlns:D="DAV:"><D:activity-collection-set/></D:options>`)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.Header, nil
+}
+
+// davXMLHeader is the start of every XML body.
+const davXMLHeader = `<?xml version="1.0" encoding="utf-8"?>` + "\n"
+
+// request sends a request for a path in the server, and returns
+// the response if its status is successful, or the error described
+// in it otherwise.  The caller must close the body of the response.
+func (s *davSession) request(method string, p string, header map[string]string, body string) (*http.Response, error) {
+	if s.closed {
+		return nil, ErrClosed
+	}
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.base+(&url.URL{Path: p}).EscapedPath(), r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", SvnClient)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	if s.user != nil {
+		password, _ := s.user.Password()
+		req.SetBasicAuth(s.user.Username(), password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, davError(resp)
+	}
+	return resp, nil
+}
+
+// ReposInfo returns the UUID, root URL and capabilities of the repository.
+func (s *davSession) ReposInfo() ReposInfo {
+	return s.info
+}
+
+// URL returns the URL of the session.
+func (s *davSession) URL() string {
+	return s.url
+}
+
+// HasCapability reports whether the server announced the given
+// capability (using the names of the SVN protocol).
+func (s *davSession) HasCapability(capability string) bool {
+	return slices.Contains(s.info.Capabilities, capability)
+}
+
+// Reparent changes the URL of the session to another one
+// in the same repository.  No request is sent.
+func (s *davSession) Reparent(address string) error {
+	p, ok := relativeURL(s.info.URL, address)
+	if !ok {
+		return fmt.Errorf("svn: %q is not in repository %q", address, s.info.URL)
+	}
+	s.url = address
+	s.path = ""
+	if p != "" {
+		s.path = "/" + p
+	}
+	return nil
+}
+
+// Close marks the session as closed.  The connections are
+// managed by the [http.Client], which is not affected.
+func (s *davSession) Close() error {
+	s.closed = true
+	return nil
+}
+
+// reposPath returns the path in the repository of p,
+// relative to the session.
+func (s *davSession) reposPath(p string) string {
+	return path.Join("/", s.path, p)
+}
+
+// publicPath returns the path in the server of the latest
+// version of reposPath.
+func (s *davSession) publicPath(reposPath string) string {
+	return s.root + reposPath
+}
+
+// revPath returns the path in the server of reposPath
+// in a revision.
+func (s *davSession) revPath(rev uint, reposPath string) string {
+	return fmt.Sprintf("%s/%d%s", s.revRoot, rev, reposPath)
+}
+
+// resolveRev returns rev, or the latest revision if it is nil.
+func (s *davSession) resolveRev(rev *int) (uint, error) {
+	if rev != nil {
+		return uint(*rev), nil
+	}
+	latest, err := s.GetLatestRev()
+	return uint(latest), err
+}
+
+// GetLatestRev sends an OPTIONS request, asking for the
+// latest revision of the repository.
+func (s *davSession) GetLatestRev() (int, error) {
+	header, err := s.options(s.publicPath("/"))
+	if err != nil {
+		return 0, fmt.Errorf("GetLatestRev: %w", err)
+	}
+	rev, err := strconv.Atoi(header.Get(davYoungestRevHeader))
+	if err != nil {
+		return 0, fmt.Errorf("GetLatestRev: invalid %s: %q", davYoungestRevHeader, header.Get(davYoungestRevHeader))
+	}
+	return rev, nil
+}
+
+// Names of the properties used to fill in a Dirent.
+var (
+	davResourceType   = xml.Name{Space: davNS, Local: "resourcetype"}
+	davContentLength  = xml.Name{Space: davNS, Local: "getcontentlength"}
+	davVersionName    = xml.Name{Space: davNS, Local: "version-name"}
+	davCreationDate   = xml.Name{Space: davNS, Local: "creationdate"}
+	davCreator        = xml.Name{Space: davNS, Local: "creator-displayname"}
+	davDeadPropCount  = xml.Name{Space: davLiveNS, Local: "deadprop-count"}
+	davMD5Checksum    = xml.Name{Space: davLiveNS, Local: "md5-checksum"}
+	davDirentProps    = []xml.Name{davResourceType, davContentLength, davVersionName, davCreationDate, davCreator, davDeadPropCount}
+	davFileProps      = []xml.Name{davResourceType, davContentLength, davMD5Checksum}
+	davLogItem        = xml.Name{Space: davSVNNS, Local: "log-item"}
+	davDAVResponse    = xml.Name{Space: davNS, Local: "response"}
+	davPostCommitErr  = xml.Name{Space: davSVNNS, Local: "post-commit-err"}
+	davNotFoundErrors = []int{
+		160013, // SVN_ERR_FS_NOT_FOUND
+		170000, // SVN_ERR_RA_ILLEGAL_URL
+	}
+)
+
+// propfind sends a PROPFIND request for the given properties
+// (all of them if props is nil) of the resource in p and,
+// if depth is "1", of its children.
+func (s *davSession) propfind(p string, depth string, props []xml.Name) ([]davResponse, error) {
+	var body strings.Builder
+	body.WriteString(davXMLHeader + `<propfind xmlns="DAV:">`)
+	if props == nil {
+		body.WriteString(`<allprop/>`)
+	} else {
+		body.WriteString(`<prop>`)
+		for _, name := range props {
+			fmt.Fprintf(&body, `<%s xmlns="%s"/>`, name.Local, name.Space)
+		}
+		body.WriteString(`</prop>`)
+	}
+	body.WriteString(`</propfind>`)
+	resp, err := s.request("PROPFIND", p, map[string]string{
+		"Content-Type": davXMLType,
+		"Depth":        depth,
+	}, body.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var ms davMultistatus
+	if err = xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, Error{
+			AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+			Message: fmt.Sprintf("Malformed PROPFIND response: %v", err),
+		}
+	}
+	if len(ms.Responses) == 0 {
+		return nil, Error{
+			AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+			Message: "Empty PROPFIND response",
+		}
+	}
+	return ms.Responses, nil
+}
+
+// isNotFound reports whether err means that a path does not exist.
+func isNotFound(err error) bool {
+	var svnErr Error
+	return errors.As(err, &svnErr) && slices.Contains(davNotFoundErrors, svnErr.AprErr)
+}
+
+// dirent converts a response to a PROPFIND into a Dirent, whose Path
+// is the one in the repository.  prefix is the path in the server
+// of the root of the repository in the revision.
+func (s *davSession) dirent(r davResponse, prefix string) Dirent {
+	props := r.props()
+	href := r.Href
+	if u, err := url.Parse(href); err == nil {
+		href = u.Path
+	}
+	d := Dirent{
+		Path:        "/" + strings.Trim(strings.TrimPrefix(href, prefix), "/"),
+		Kind:        NodeFile,
+		CreatedDate: strings.TrimSpace(props[davCreationDate].Text),
+		LastAuthor:  props[davCreator].String(),
+	}
+	for _, child := range props[davResourceType].Children {
+		if child.XMLName.Local == "collection" {
+			d.Kind = NodeDir
+		}
+	}
+	d.Size, _ = strconv.ParseUint(strings.TrimSpace(props[davContentLength].Text), 10, 64)
+	d.CreatedRev, _ = xmlRev(props[davVersionName].Text)
+	count, _ := strconv.Atoi(strings.TrimSpace(props[davDeadPropCount].Text))
+	d.HasProps = count > 0
+	return d
+}
+
+// nodeProps returns the node properties in a response to a PROPFIND.
+func nodeProps(r davResponse) Props {
+	props := Props{}
+	for name, p := range r.props() {
+		if prop, ok := davPropName(name); ok {
+			props[prop] = p.String()
+		}
+	}
+	return props
+}
+
+// Stat sends a PROPFIND request, asking for the status of a path
+// in a revision (nil meaning HEAD).
+// If the path does not exist, the returned Kind is [NodeNone].
+func (s *davSession) Stat(path string, rev *int) (Stat, error) {
+	r, err := s.resolveRev(rev)
+	if err != nil {
+		return Stat{}, fmt.Errorf("Stat: %w", err)
+	}
+	resps, err := s.propfind(s.revPath(r, s.reposPath(path)), "0", davDirentProps)
+	if isNotFound(err) {
+		return Stat{Kind: NodeNone}, nil
+	}
+	if err != nil {
+		return Stat{}, fmt.Errorf("Stat: %w", err)
+	}
+	d := s.dirent(resps[0], s.revPath(r, ""))
+	return Stat{
+		Kind:        d.Kind,
+		Size:        d.Size,
+		HasProps:    d.HasProps,
+		CreatedRev:  d.CreatedRev,
+		CreatedDate: d.CreatedDate,
+		LastAuthor:  d.LastAuthor,
+	}, nil
+}
+
+// CheckPath returns the kind of node of a path in a revision
+// ([NodeNone] if it does not exist).
+func (s *davSession) CheckPath(path string, rev *int) (NodeKind, error) {
+	stat, err := s.Stat(path, rev)
+	if err != nil {
+		return "", fmt.Errorf("CheckPath: %w", err)
+	}
+	return stat.Kind, nil
+}
+
+// List sends PROPFIND requests, asking for the entries of a directory
+// up to the given depth.  The paths of the returned entries are
+// absolute paths in the repository, starting with the one of path.
+// All the fields of the entries are filled in.
+func (s *davSession) List(path string, rev *int, depth string, fields []string) ([]Dirent, error) {
+	r, err := s.resolveRev(rev)
+	if err != nil {
+		return nil, fmt.Errorf("List: %w", err)
+	}
+	dirents, err := s.list(s.reposPath(path), r, depth, true)
+	if err != nil {
+		return nil, fmt.Errorf("List: %w", err)
+	}
+	return dirents, nil
+}
+
+// list returns the entries in the directory reposPath, sorted by path,
+// preceded by the directory itself if withSelf is true.  A file is
+// returned alone (or it is an error if withSelf is false).
+func (s *davSession) list(reposPath string, rev uint, depth string, withSelf bool) ([]Dirent, error) {
+	propDepth := "1"
+	if depth == "empty" {
+		propDepth = "0"
+	}
+	resps, err := s.propfind(s.revPath(rev, reposPath), propDepth, davDirentProps)
+	if err != nil {
+		return nil, err
+	}
+	var self *Dirent
+	var children []Dirent
+	for _, resp := range resps {
+		d := s.dirent(resp, s.revPath(rev, ""))
+		if d.Path == reposPath {
+			self = &d
+		} else {
+			children = append(children, d)
+		}
+	}
+	if self == nil {
+		return nil, Error{
+			AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+			Message: fmt.Sprintf("The PROPFIND response did not include '%s'", reposPath),
+		}
+	}
+	var dirents []Dirent
+	if withSelf {
+		dirents = append(dirents, *self)
+	}
+	if self.Kind != NodeDir {
+		if !withSelf {
+			return nil, Error{
+				AprErr:  160016, // SVN_ERR_FS_NOT_DIRECTORY
+				Message: fmt.Sprintf("Can't get entries of non-directory '%s'", reposPath),
+			}
+		}
+		return dirents, nil
+	}
+	slices.SortFunc(children, func(a, b Dirent) int { return strings.Compare(a.Path, b.Path) })
+	for _, child := range children {
+		if depth == "files" && child.Kind == NodeDir {
+			continue
+		}
+		dirents = append(dirents, child)
+		if depth == "infinity" && child.Kind == NodeDir {
+			sub, err := s.list(child.Path, rev, depth, false)
+			if err != nil {
+				return nil, err
+			}
+			dirents = append(dirents, sub...)
+		}
+	}
+	return dirents, nil
+}
+
+// GetFile sends PROPFIND and GET requests, asking for the properties
+// and contents of a file.
+func (s *davSession) GetFile(path string, rev *int, wantProps bool, wantContent bool, wantIProps bool) (File, error) {
+	var w io.Writer
+	var buf bytes.Buffer
+	if wantContent {
+		w = &buf
+	}
+	file, err := s.getFile(path, rev, wantProps, wantIProps, w)
+	if err != nil {
+		return file, fmt.Errorf("GetFile: %w", err)
+	}
+	if wantContent {
+		file.Contents = buf.Bytes()
+	}
+	return file, nil
+}
+
+// GetFileTo is like GetFile, but writes the contents of the file to w
+// as they are received, and verifies their checksum.
+func (s *davSession) GetFileTo(path string, rev *int, w io.Writer) (File, error) {
+	file, err := s.getFile(path, rev, true, false, w)
+	if err != nil {
+		return file, fmt.Errorf("GetFileTo: %w", err)
+	}
+	return file, nil
+}
+
+func (s *davSession) getFile(path string, rev *int, wantProps bool, wantIProps bool, w io.Writer) (File, error) {
+	var file File
+	r, err := s.resolveRev(rev)
+	if err != nil {
+		return file, err
+	}
+	file.Rev = r
+	p := s.revPath(r, s.reposPath(path))
+	resps, err := s.propfind(p, "0", davFileProps)
+	if err != nil {
+		return file, err
+	}
+	if s.dirent(resps[0], s.revPath(r, "")).Kind == NodeDir {
+		return file, Error{
+			AprErr:  160017, // SVN_ERR_FS_NOT_FILE
+			Message: fmt.Sprintf("Attempted to get textual contents of a *non*-file node '%s'", s.reposPath(path)),
+		}
+	}
+	file.Checksum = strings.TrimSpace(resps[0].props()[davMD5Checksum].Text)
+	if wantProps {
+		if resps, err = s.propfind(p, "0", nil); err != nil {
+			return file, err
+		}
+		file.Props = nodeProps(resps[0])
+	}
+	if wantIProps {
+		if file.InheritedProps, err = s.getIProps(path, r); err != nil {
+			return file, err
+		}
+	}
+	if w == nil {
+		return file, nil
+	}
+
+	resp, err := s.request("GET", p, nil, "")
+	if err != nil {
+		return file, err
+	}
+	defer resp.Body.Close()
+	h := newChecksum(file.Checksum)
+	n, err := io.Copy(io.MultiWriter(h, w), resp.Body)
+	file.Size = uint64(n)
+	if err != nil {
+		return file, fmt.Errorf("reading content: %w", err)
+	}
+	if err = h.verify(); err != nil {
+		return file, err
+	}
+	return file, nil
+}
+
+// GetDir sends PROPFIND requests, asking for the properties and
+// the entries of a directory.  All the fields of the entries are
+// filled in, and their paths are their names.
+func (s *davSession) GetDir(path string, rev *int, wantProps bool, wantContents bool, fields []string, wantIProps bool) (Dir, error) {
+	var dir Dir
+	r, err := s.resolveRev(rev)
+	if err != nil {
+		return dir, fmt.Errorf("GetDir: %w", err)
+	}
+	dir.Rev = r
+	reposPath := s.reposPath(path)
+	if wantProps {
+		resps, err := s.propfind(s.revPath(r, reposPath), "0", nil)
+		if err != nil {
+			return dir, fmt.Errorf("GetDir: %w", err)
+		}
+		dir.Props = nodeProps(resps[0])
+	}
+	if wantContents {
+		dir.Entries, err = s.list(reposPath, r, "immediates", false)
+		if err != nil {
+			return dir, fmt.Errorf("GetDir: %w", err)
+		}
+		for i := range dir.Entries {
+			dir.Entries[i].Path = strings.TrimPrefix(dir.Entries[i].Path, strings.TrimSuffix(reposPath, "/")+"/")
+		}
+	}
+	if wantIProps {
+		if dir.InheritedProps, err = s.getIProps(path, r); err != nil {
+			return dir, fmt.Errorf("GetDir: %w", err)
+		}
+	}
+	return dir, nil
+}
+
+// report sends a REPORT request to the resource in p, and calls fn
+// for every element in the response (see walkXML).
+func (s *davSession) report(p string, body string, fn func(d *xml.Decoder, start xml.StartElement) error) error {
+	resp, err := s.request("REPORT", p, map[string]string{"Content-Type": davXMLType}, davXMLHeader+body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return walkXML(resp.Body, fn)
+}
+
+// sessionRevPath returns the path in the server of the URL
+// of the session in a revision, to which most REPORT requests are
+// sent; the paths in them are relative to the session.
+func (s *davSession) sessionRevPath(rev uint) string {
+	return s.revPath(rev, s.reposPath(""))
+}
+
+// GetIProps sends an "inherited-props-report" REPORT request, asking
+// for the properties a path inherits from its parents.
+func (s *davSession) GetIProps(path string, rev *int) (InheritedProps, error) {
+	r, err := s.resolveRev(rev)
+	if err != nil {
+		return nil, fmt.Errorf("GetIProps: %w", err)
+	}
+	iprops, err := s.getIProps(path, r)
+	if err != nil {
+		return nil, fmt.Errorf("GetIProps: %w", err)
+	}
+	return iprops, nil
+}
+
+func (s *davSession) getIProps(path string, rev uint) (InheritedProps, error) {
+	body := fmt.Sprintf(`<S:inherited-props-report xmlns:S="svn:" xmlns:D="DAV:">`+
+		`<S:revision>%d</S:revision><S:path>%s</S:path></S:inherited-props-report>`,
+		rev, xmlEscape(path))
+	iprops := InheritedProps{}
+	err := s.report(s.sessionRevPath(rev), body, func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name != (xml.Name{Space: davSVNNS, Local: "iprop-item"}) {
+			return nil
+		}
+		var item struct {
+			Path  string   `xml:"svn: iprop-path"`
+			Name  string   `xml:"svn: iprop-propname"`
+			Value davValue `xml:"svn: iprop-propval"`
+		}
+		if err := d.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		if len(iprops) == 0 || iprops[len(iprops)-1].Path != item.Path {
+			iprops = append(iprops, InheritedProps{{Path: item.Path, Props: Props{}}}...)
+		}
+		iprops[len(iprops)-1].Props[item.Name] = item.Value.String()
+		return nil
+	})
+	return iprops, err
+}
+
+// GetMergeinfo sends a "mergeinfo-report" REPORT request, asking for the
+// "svn:mergeinfo" of some paths in a revision (nil meaning HEAD).
+// See [Client.GetMergeinfo].
+func (s *davSession) GetMergeinfo(paths []string, rev *int, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error) {
+	if !s.HasCapability("mergeinfo") {
+		return nil, Error{
+			AprErr:  200007, // SVN_ERR_UNSUPPORTED_FEATURE
+			Message: "Server does not support mergeinfo",
+		}
+	}
+	r, err := s.resolveRev(rev)
+	if err != nil {
+		return nil, fmt.Errorf("GetMergeinfo: %w", err)
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, `<S:mergeinfo-report xmlns:S="svn:" xmlns:D="DAV:">`+
+		`<S:revision>%d</S:revision><S:inherit>%s</S:inherit>`, r, inherit)
+	if includeDescendants {
+		body.WriteString(`<S:include-descendants>yes</S:include-descendants>`)
+	}
+	for _, p := range paths {
+		fmt.Fprintf(&body, `<S:path>%s</S:path>`, xmlEscape(p))
+	}
+	body.WriteString(`</S:mergeinfo-report>`)
+
+	catalog := map[string]mergeinfo.Mergeinfo{}
+	err = s.report(s.sessionRevPath(r), body.String(), func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name != (xml.Name{Space: davSVNNS, Local: "mergeinfo-item"}) {
+			return nil
+		}
+		var item struct {
+			Path string   `xml:"svn: mergeinfo-path"`
+			Info davValue `xml:"svn: mergeinfo-info"`
+		}
+		if err := d.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		m, err := mergeinfo.Parse(item.Info.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", item.Path, err)
+		}
+		catalog[item.Path] = m
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetMergeinfo: %w", err)
+	}
+	return catalog, nil
+}
+
+// davChangeModes maps the elements of the changed paths in a "log-report"
+// to their actions.
+var davChangeModes = map[string]string{
+	"added-path":    "A",
+	"deleted-path":  "D",
+	"replaced-path": "R",
+	"modified-path": "M",
+}
+
+// Log sends a "log-report" REPORT request, asking for log entries
+// between startRev (nil meaning HEAD) and endRev (nil meaning 0).
+func (s *davSession) Log(paths []string, startRev *int, endRev *int, changedPaths bool) ([]LogEntry, error) {
+	start, err := s.resolveRev(startRev)
+	if err != nil {
+		return nil, fmt.Errorf("Log: %w", err)
+	}
+	var end uint
+	if endRev != nil {
+		end = uint(*endRev)
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, `<S:log-report xmlns:S="svn:">`+
+		`<S:start-revision>%d</S:start-revision><S:end-revision>%d</S:end-revision>`, start, end)
+	if changedPaths {
+		body.WriteString(`<S:discover-changed-paths/>`)
+	}
+	for _, name := range []string{PropRevAuthor, PropRevDate, PropRevLog} {
+		fmt.Fprintf(&body, `<S:revprop>%s</S:revprop>`, name)
+	}
+	body.WriteString(`<S:encode-binary-props/>`)
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	for _, p := range paths {
+		fmt.Fprintf(&body, `<S:path>%s</S:path>`, xmlEscape(p))
+	}
+	body.WriteString(`</S:log-report>`)
+
+	var entries []LogEntry
+	err = s.report(s.sessionRevPath(max(start, end)), body.String(), func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name != davLogItem {
+			return nil
+		}
+		var item struct {
+			Rev     string   `xml:"DAV: version-name"`
+			Author  davValue `xml:"DAV: creator-displayname"`
+			Date    davValue `xml:"svn: date"`
+			Comment davValue `xml:"DAV: comment"`
+			Changed []struct {
+				XMLName xml.Name
+				Path    string `xml:",chardata"`
+			} `xml:",any"`
+		}
+		if err := d.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		rev, err := xmlRev(item.Rev)
+		if err != nil {
+			return err
+		}
+		entry := LogEntry{
+			Rev:     rev,
+			Author:  item.Author.String(),
+			Date:    item.Date.String(),
+			Message: item.Comment.String(),
+		}
+		for _, c := range item.Changed {
+			if mode, ok := davChangeModes[c.XMLName.Local]; ok {
+				entry.Changed = append(entry.Changed, struct {
+					Path string
+					Mode string
+				}{Path: c.Path, Mode: mode})
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Log: %w", err)
+	}
+	return entries, nil
+}
+
+// GetLocations sends a "get-locations" REPORT request, asking for the
+// paths that the node which was at path in pegRev had in other revisions.
+func (s *davSession) GetLocations(path string, pegRev int, revs []int) (map[int]string, error) {
+	var body strings.Builder
+	fmt.Fprintf(&body, `<S:get-locations xmlns:S="svn:" xmlns:D="DAV:">`+
+		`<S:path>%s</S:path><S:peg-revision>%d</S:peg-revision>`, xmlEscape(path), pegRev)
+	for _, rev := range revs {
+		fmt.Fprintf(&body, `<S:location-revision>%d</S:location-revision>`, rev)
+	}
+	body.WriteString(`</S:get-locations>`)
+
+	locations := map[int]string{}
+	err := s.report(s.sessionRevPath(uint(pegRev)), body.String(), func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name != (xml.Name{Space: davSVNNS, Local: "location"}) {
+			return nil
+		}
+		var location struct {
+			Rev  int    `xml:"rev,attr"`
+			Path string `xml:"path,attr"`
+		}
+		if err := d.DecodeElement(&location, &start); err != nil {
+			return err
+		}
+		locations[location.Rev] = location.Path
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetLocations: %w", err)
+	}
+	return locations, nil
+}
+
+// GetLocationSegments sends a "get-location-segments" REPORT request.
+// See [Client.GetLocationSegments].
+func (s *davSession) GetLocationSegments(path string, pegRev *int, startRev *int, endRev *int) ([]LocationSegment, error) {
+	peg, err := s.resolveRev(pegRev)
+	if err != nil {
+		return nil, fmt.Errorf("GetLocationSegments: %w", err)
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, `<S:get-location-segments xmlns:S="svn:" xmlns:D="DAV:">`+
+		`<S:path>%s</S:path><S:peg-revision>%d</S:peg-revision>`, xmlEscape(path), peg)
+	if startRev != nil {
+		fmt.Fprintf(&body, `<S:start-revision>%d</S:start-revision>`, *startRev)
+	}
+	if endRev != nil {
+		fmt.Fprintf(&body, `<S:end-revision>%d</S:end-revision>`, *endRev)
+	}
+	body.WriteString(`</S:get-location-segments>`)
+
+	var segments []LocationSegment
+	err = s.report(s.sessionRevPath(peg), body.String(), func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name != (xml.Name{Space: davSVNNS, Local: "location-segment"}) {
+			return nil
+		}
+		var segment struct {
+			Path  string `xml:"path,attr"`
+			Start uint   `xml:"range-start,attr"`
+			End   uint   `xml:"range-end,attr"`
+		}
+		if err := d.DecodeElement(&segment, &start); err != nil {
+			return err
+		}
+		segments = append(segments, LocationSegment{
+			RangeStart: segment.Start,
+			RangeEnd:   segment.End,
+			Path:       strings.TrimPrefix(segment.Path, "/"),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetLocationSegments: %w", err)
+	}
+	return segments, nil
+}
+
+// GetDeletedRev sends a "get-deleted-rev-report" REPORT request, asking
+// for the revision in which path (as it was in pegRev) was deleted,
+// looking no further than endRev.
+// If it was not deleted in that range, it returns [InvalidRevision].
+func (s *davSession) GetDeletedRev(path string, pegRev int, endRev int) (int, error) {
+	body := fmt.Sprintf(`<S:get-deleted-rev-report xmlns:S="svn:" xmlns:D="DAV:">`+
+		`<S:path>%s</S:path><S:peg-revision>%d</S:peg-revision><S:end-revision>%d</S:end-revision>`+
+		`</S:get-deleted-rev-report>`, xmlEscape(path), pegRev, endRev)
+	rev := InvalidRevision
+	err := s.report(s.sessionRevPath(uint(pegRev)), body, func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name != davVersionName {
+			return nil
+		}
+		var text string
+		if err := d.DecodeElement(&text, &start); err != nil {
+			return err
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(text)); err == nil && n >= 0 {
+			rev = n
+		}
+		return nil
+	})
+	if err != nil {
+		return InvalidRevision, fmt.Errorf("GetDeletedRev: %w", err)
+	}
+	return rev, nil
+}
+
+// A davNamedValue is an element with a name attribute and a value,
+// such as a property in a "file-revs-report".
+type davNamedValue struct {
+	Name string `xml:"name,attr"`
+	davValue
+}
+
+// GetFileRevs sends a "file-revs-report" REPORT request, asking for all
+// the revisions in which a file changed between startRev and endRev.
+// See [Client.GetFileRevs].
+func (s *davSession) GetFileRevs(path string, startRev *int, endRev *int, includeMerged bool, fn func(FileRev) error) error {
+	if startRev != nil && endRev != nil && *startRev > *endRev && !s.HasCapability("file-revs-reverse") {
+		return Error{
+			AprErr:  200007, // SVN_ERR_UNSUPPORTED_FEATURE
+			Message: "The server does not support retrieving file revisions in reverse order",
+		}
+	}
+	var body strings.Builder
+	body.WriteString(`<S:file-revs-report xmlns:S="svn:" xmlns:D="DAV:">`)
+	if startRev != nil {
+		fmt.Fprintf(&body, `<S:start-revision>%d</S:start-revision>`, *startRev)
+	}
+	if endRev != nil {
+		fmt.Fprintf(&body, `<S:end-revision>%d</S:end-revision>`, *endRev)
+	}
+	if includeMerged {
+		body.WriteString(`<S:include-merged-revisions/>`)
+	}
+	fmt.Fprintf(&body, `<S:path>%s</S:path></S:file-revs-report>`, xmlEscape(path))
+	var peg uint
+	if startRev != nil && endRev != nil {
+		peg = uint(max(*startRev, *endRev))
+	} else {
+		latest, err := s.GetLatestRev()
+		if err != nil {
+			return fmt.Errorf("GetFileRevs: %w", err)
+		}
+		peg = uint(latest)
+	}
+
+	var contents []byte
+	var fnErr error
+	err := s.report(s.sessionRevPath(peg), body.String(), func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name != (xml.Name{Space: davSVNNS, Local: "file-rev"}) {
+			return nil
+		}
+		var item struct {
+			Path        string          `xml:"path,attr"`
+			Rev         uint            `xml:"rev,attr"`
+			RevProps    []davNamedValue `xml:"svn: rev-prop"`
+			SetProps    []davNamedValue `xml:"svn: set-prop"`
+			RemoveProps []davNamedValue `xml:"svn: remove-prop"`
+			Merged      []struct{}      `xml:"svn: merged-revision"`
+			TxDelta     []string        `xml:"svn: txdelta"`
+		}
+		if err := d.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		rev := FileRev{
+			Path:           item.Path,
+			Rev:            item.Rev,
+			RevProps:       Props{},
+			PropDelta:      PropDelta{},
+			MergedRevision: len(item.Merged) > 0,
+		}
+		for _, p := range item.RevProps {
+			rev.RevProps[p.Name] = p.String()
+		}
+		for _, p := range item.SetProps {
+			value := p.String()
+			rev.PropDelta[p.Name] = &value
+		}
+		for _, p := range item.RemoveProps {
+			rev.PropDelta[p.Name] = nil
+		}
+		if len(item.TxDelta) > 0 {
+			delta, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(item.TxDelta[0]), ""))
+			if err != nil {
+				return fmt.Errorf("r%d: decoding delta: %w", rev.Rev, err)
+			}
+			if len(delta) > 0 {
+				if contents, err = svndiff.Apply(contents, delta); err != nil {
+					return fmt.Errorf("r%d: %w", rev.Rev, err)
+				}
+			}
+		}
+		rev.Contents = contents
+		if fnErr == nil {
+			fnErr = fn(rev)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("GetFileRevs: %w", err)
+	}
+	return fnErr
+}
+
+// Update sends an "update-report" REPORT request, with the state of the
+// working copy in report, and calls the methods of e with the edit in the
+// response, from TargetRev to CloseEdit (or AbortEdit, if it fails).
+// The whole edit, including the contents of the files, is asked to be in
+// the response ("send-all" mode).  See [Client.Update].
+func (s *davSession) Update(report *Report, e Editor) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, `<S:update-report xmlns:S="svn:" send-all="true"><S:src-path>%s</S:src-path>`, xmlEscape(s.url))
+	if report.Rev != nil {
+		fmt.Fprintf(&body, `<S:target-revision>%d</S:target-revision>`, *report.Rev)
+	}
+	switch report.Command {
+	case "update", "status":
+	case "switch", "diff":
+		dst := s.info.URL
+		if report.DstPath != "" {
+			dst += "/" + report.DstPath
+		}
+		fmt.Fprintf(&body, `<S:dst-path>%s</S:dst-path>`, xmlEscape(dst))
+	default:
+		return fmt.Errorf("Update: unknown command %q", report.Command)
+	}
+	if report.Target != "" {
+		fmt.Fprintf(&body, `<S:update-target>%s</S:update-target>`, xmlEscape(report.Target))
+	}
+	if report.Depth != "" {
+		fmt.Fprintf(&body, `<S:depth>%s</S:depth>`, report.Depth)
+	}
+	if report.IgnoreAncestry {
+		body.WriteString(`<S:ignore-ancestry>yes</S:ignore-ancestry>`)
+	}
+	if report.SendCopyFrom {
+		body.WriteString(`<S:send-copyfrom-args>yes</S:send-copyfrom-args>`)
+	}
+	if !report.TextDeltas {
+		body.WriteString(`<S:text-deltas>no</S:text-deltas>`)
+	}
+	for _, p := range report.Paths {
+		if p.Deleted {
+			fmt.Fprintf(&body, `<S:missing>%s</S:missing>`, xmlEscape(p.Path))
+			continue
+		}
+		fmt.Fprintf(&body, `<S:entry rev="%d"`, p.Rev)
+		if p.Depth != "" {
+			fmt.Fprintf(&body, ` depth="%s"`, p.Depth)
+		}
+		if p.StartEmpty {
+			body.WriteString(` start-empty="true"`)
+		}
+		if p.LinkPath != "" {
+			fmt.Fprintf(&body, ` linkpath="/%s"`, xmlEscape(p.LinkPath))
+		}
+		if p.LockToken != "" {
+			fmt.Fprintf(&body, ` lock-token="%s"`, xmlEscape(p.LockToken))
+		}
+		fmt.Fprintf(&body, `>%s</S:entry>`, xmlEscape(p.Path))
+	}
+	body.WriteString(`</S:update-report>`)
+
+	resp, err := s.request("REPORT", s.me, map[string]string{"Content-Type": davXMLType}, davXMLHeader+body.String())
+	if err != nil {
+		return fmt.Errorf("Update: %w", err)
+	}
+	defer resp.Body.Close()
+	u := &davUpdateDriver{e: e}
+	if err = u.drive(xml.NewDecoder(resp.Body)); err != nil {
+		if !u.closed {
+			e.AbortEdit()
+		}
+		return fmt.Errorf("Update: %w", err)
+	}
+	return nil
+}
+
+// A davUpdateDriver calls the methods of an Editor for the elements
+// of an "update-report" response.
+type davUpdateDriver struct {
+	e Editor
+	// stack has the open directories and files, the innermost last.
+	stack []davUpdateNode
+	// tokens is the number of tokens given to the editor.
+	tokens int
+	// closed is true after CloseEdit.
+	closed bool
+}
+
+// A davUpdateNode is a directory or file open in a davUpdateDriver.
+type davUpdateNode struct {
+	path  string
+	token string
+	file  bool
+	// checksum is the MD5 checksum of the contents of a file, if sent.
+	checksum string
+}
+
+// drive reads the response until its end, which closes the edit.
+func (u *davUpdateDriver) drive(d *xml.Decoder) error {
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) && !u.closed {
+			err = io.ErrUnexpectedEOF
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return Error{
+				AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+				Message: fmt.Sprintf("Malformed XML: %v", err),
+			}
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			err = u.start(d, t)
+		case xml.EndElement:
+			err = u.end(t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// malformed returns the error used for an unexpected element.
+func (u *davUpdateDriver) malformed(name xml.Name) error {
+	return Error{
+		AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+		Message: fmt.Sprintf("Unexpected element '%s' in update-report", name.Local),
+	}
+}
+
+// top returns the innermost open node,
+// which must be a directory unless file is true.
+func (u *davUpdateDriver) top(name xml.Name, file bool) (*davUpdateNode, error) {
+	if len(u.stack) == 0 || (u.stack[len(u.stack)-1].file && !file) {
+		return nil, u.malformed(name)
+	}
+	return &u.stack[len(u.stack)-1], nil
+}
+
+// xmlAttr returns the value of an attribute of an element.
+func xmlAttr(start xml.StartElement, name string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// start handles the start of an element.
+func (u *davUpdateDriver) start(d *xml.Decoder, start xml.StartElement) error {
+	if start.Name.Space != davSVNNS {
+		return nil
+	}
+	var rev *uint
+	if v := xmlAttr(start, "rev"); v != "" {
+		r, err := xmlRev(v)
+		if err != nil {
+			return err
+		}
+		rev = &r
+	}
+	switch start.Name.Local {
+	case "target-revision":
+		if rev == nil {
+			return u.malformed(start.Name)
+		}
+		return u.e.TargetRev(*rev)
+	case "open-directory", "add-directory", "open-file", "add-file":
+		n := davUpdateNode{file: strings.HasSuffix(start.Name.Local, "-file")}
+		n.token = fmt.Sprintf("d%d", u.tokens)
+		if n.file {
+			n.token = fmt.Sprintf("f%d", u.tokens)
+		}
+		u.tokens++
+		var err error
+		if len(u.stack) == 0 {
+			if start.Name.Local != "open-directory" {
+				return u.malformed(start.Name)
+			}
+			err = u.e.OpenRoot(rev, n.token)
+		} else {
+			var parent *davUpdateNode
+			if parent, err = u.top(start.Name, false); err != nil {
+				return err
+			}
+			n.path = path.Join(parent.path, xmlAttr(start, "name"))
+			copyPath := xmlAttr(start, "copyfrom-path")
+			var copyRev uint
+			if copyPath != "" {
+				if copyRev, err = xmlRev(xmlAttr(start, "copyfrom-rev")); err != nil {
+					return err
+				}
+			}
+			switch start.Name.Local {
+			case "open-directory":
+				err = u.e.OpenDir(n.path, parent.token, n.token, rev)
+			case "add-directory":
+				err = u.e.AddDir(n.path, parent.token, n.token, copyPath, copyRev)
+			case "open-file":
+				err = u.e.OpenFile(n.path, parent.token, n.token, rev)
+			case "add-file":
+				err = u.e.AddFile(n.path, parent.token, n.token, copyPath, copyRev)
+			}
+		}
+		u.stack = append(u.stack, n)
+		return err
+	case "delete-entry", "absent-directory", "absent-file":
+		parent, err := u.top(start.Name, false)
+		if err != nil {
+			return err
+		}
+		p := path.Join(parent.path, xmlAttr(start, "name"))
+		switch start.Name.Local {
+		case "delete-entry":
+			return u.e.DeleteEntry(p, rev, parent.token)
+		case "absent-directory":
+			return u.e.AbsentDir(p, parent.token)
+		}
+		return u.e.AbsentFile(p, parent.token)
+	case "set-prop", "remove-prop":
+		n, err := u.top(start.Name, true)
+		if err != nil {
+			return err
+		}
+		var prop davNamedValue
+		if err = d.DecodeElement(&prop, &start); err != nil {
+			return err
+		}
+		var value *string
+		if start.Name.Local == "set-prop" {
+			v := prop.String()
+			value = &v
+		}
+		return u.changeProp(n, prop.Name, value)
+	case "txdelta":
+		n, err := u.top(start.Name, true)
+		if err != nil || !n.file {
+			return u.malformed(start.Name)
+		}
+		var txdelta struct {
+			BaseChecksum string `xml:"base-checksum,attr"`
+			Text         string `xml:",chardata"`
+		}
+		if err = d.DecodeElement(&txdelta, &start); err != nil {
+			return err
+		}
+		delta, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(txdelta.Text), ""))
+		if err != nil {
+			return fmt.Errorf("%s: decoding delta: %w", n.path, err)
+		}
+		w, err := u.e.ApplyTextDelta(n.token, txdelta.BaseChecksum)
+		if err != nil {
+			return err
+		}
+		if len(delta) > 0 {
+			if _, err = w.Write(delta); err != nil {
+				w.Close()
+				return err
+			}
+		}
+		return w.Close()
+	case "prop":
+		n, err := u.top(start.Name, true)
+		if err != nil {
+			return err
+		}
+		var prop struct {
+			Checksum    string          `xml:"http://subversion.tigris.org/xmlns/dav/ md5-checksum"`
+			Rev         string          `xml:"DAV: version-name"`
+			Date        string          `xml:"DAV: creationdate"`
+			Author      string          `xml:"DAV: creator-displayname"`
+			RemoveProps []davNamedValue `xml:"svn: remove-prop"`
+		}
+		if err = d.DecodeElement(&prop, &start); err != nil {
+			return err
+		}
+		if prop.Checksum != "" {
+			n.checksum = prop.Checksum
+		}
+		// the "svn:entry:" properties may be sent as live properties:
+		for _, entry := range [][2]string{
+			{"svn:entry:committed-rev", prop.Rev},
+			{"svn:entry:committed-date", prop.Date},
+			{"svn:entry:last-author", prop.Author},
+		} {
+			if entry[1] != "" {
+				if err = u.changeProp(n, entry[0], &entry[1]); err != nil {
+					return err
+				}
+			}
+		}
+		for _, p := range prop.RemoveProps {
+			if err = u.changeProp(n, p.Name, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "fetch-props", "fetch-file":
+		return Error{
+			AprErr:  200007, // SVN_ERR_UNSUPPORTED_FEATURE
+			Message: "The server did not send the whole edit in the update-report",
+		}
+	}
+	return nil
+}
+
+// changeProp changes a property of a directory or file.
+func (u *davUpdateDriver) changeProp(n *davUpdateNode, name string, value *string) error {
+	if n.file {
+		return u.e.ChangeFileProp(n.token, name, value)
+	}
+	return u.e.ChangeDirProp(n.token, name, value)
+}
+
+// end handles the end of an element, closing directories,
+// files and the edit.
+func (u *davUpdateDriver) end(end xml.EndElement) error {
+	if end.Name.Space != davSVNNS {
+		return nil
+	}
+	switch end.Name.Local {
+	case "open-directory", "add-directory", "open-file", "add-file":
+		n := u.stack[len(u.stack)-1]
+		u.stack = u.stack[:len(u.stack)-1]
+		if n.file {
+			return u.e.CloseFile(n.token, n.checksum)
+		}
+		return u.e.CloseDir(n.token)
+	case "update-report":
+		if len(u.stack) > 0 {
+			return u.malformed(end.Name)
+		}
+		u.closed = true
+		return u.e.CloseEdit()
+	}
+	return nil
+}
+
+// Lock sends a LOCK request, asking to lock a path.
+// See [Client.Lock].
+func (s *davSession) Lock(path string, comment string, steal bool, currentRev *int) (Lock, error) {
+	reposPath := s.reposPath(path)
+	header := map[string]string{"Content-Type": davXMLType}
+	if currentRev != nil {
+		header[davVersionHeader] = strconv.Itoa(*currentRev)
+	}
+	if steal {
+		header[davOptionsHeader] = "lock-steal"
+	}
+	body := davXMLHeader + `<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope>` +
+		`<D:locktype><D:write/></D:locktype>`
+	if comment != "" {
+		body += `<D:owner>` + xmlEscape(comment) + `</D:owner>`
+	}
+	body += `</D:lockinfo>`
+	resp, err := s.request("LOCK", s.publicPath(reposPath), header, body)
+	if err != nil {
+		return Lock{}, fmt.Errorf("Lock: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return Lock{
+		Path:    reposPath,
+		Token:   strings.Trim(resp.Header.Get("Lock-Token"), "<>"),
+		Owner:   resp.Header.Get(davLockOwnerHeader),
+		Comment: comment,
+		Created: resp.Header.Get(davCreationHeader),
+	}, nil
+}
+
+// Unlock sends an UNLOCK request, asking to remove the lock on a path.
+// If breakLock is true, the lock is removed even if token is not its
+// token (or it is empty).
+func (s *davSession) Unlock(path string, token string, breakLock bool) error {
+	if token == "" {
+		lock, err := s.GetLock(path)
+		if err != nil {
+			return fmt.Errorf("Unlock: %w", err)
+		}
+		if lock == nil {
+			return Error{
+				AprErr:  160040, // SVN_ERR_FS_NO_SUCH_LOCK
+				Message: fmt.Sprintf("No lock on path '%s'", s.reposPath(path)),
+			}
+		}
+		token = lock.Token
+	}
+	header := map[string]string{"Lock-Token": "<" + token + ">"}
+	if breakLock {
+		header[davOptionsHeader] = "lock-break"
+	}
+	resp, err := s.request("UNLOCK", s.publicPath(s.reposPath(path)), header, "")
+	if err != nil {
+		return fmt.Errorf("Unlock: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetLock returns the lock on a path, or nil if it is not locked.
+func (s *davSession) GetLock(path string) (*Lock, error) {
+	locks, err := s.GetLocks(path, "empty")
+	if err != nil {
+		return nil, fmt.Errorf("GetLock: %w", err)
+	}
+	for _, lock := range locks {
+		if lock.Path == s.reposPath(path) {
+			return &lock, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetLocks sends a "get-locks-report" REPORT request, asking for all
+// the locks on a path and its children, up to the given depth
+// ("empty", "files", "immediates" or "infinity").
+func (s *davSession) GetLocks(path string, depth string) ([]Lock, error) {
+	body := `<S:get-locks-report xmlns:S="svn:" xmlns:D="DAV:"`
+	if depth != "" {
+		body += ` depth="` + depth + `"`
+	}
+	body += `/>`
+	var locks []Lock
+	err := s.report(s.publicPath(s.reposPath(path)), body, func(d *xml.Decoder, start xml.StartElement) error {
+		if start.Name != (xml.Name{Space: davSVNNS, Local: "lock"}) {
+			return nil
+		}
+		var lock struct {
+			Path    string   `xml:"svn: path"`
+			Token   string   `xml:"svn: token"`
+			Owner   davValue `xml:"svn: owner"`
+			Comment davValue `xml:"svn: comment"`
+			Created string   `xml:"svn: creationdate"`
+			Expires string   `xml:"svn: expirationdate"`
+		}
+		if err := d.DecodeElement(&lock, &start); err != nil {
+			return err
+		}
+		locks = append(locks, Lock{
+			Path:    lock.Path,
+			Token:   lock.Token,
+			Owner:   lock.Owner.String(),
+			Comment: lock.Comment.String(),
+			Created: lock.Created,
+			Expires: lock.Expires,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetLocks: %w", err)
+	}
+	return locks, nil
+}
+
+// LockMany locks several paths, one request at a time.
+// It returns the result of every path, sorted by path.
+func (s *davSession) LockMany(comment string, steal bool, paths map[string]*int) ([]LockResult, error) {
+	results := make([]LockResult, 0, len(paths))
+	for _, path := range sortedKeys(paths) {
+		lock, err := s.Lock(path, comment, steal, paths[path])
+		var svnErr Error
+		if err != nil && !errors.As(err, &svnErr) {
+			return nil, err
+		}
+		results = append(results, LockResult{Path: path, Lock: lock, Err: err})
+	}
+	return results, nil
+}
+
+// UnlockMany removes the locks on several paths, one request at a time.
+// It returns the result of every path, sorted by path.
+func (s *davSession) UnlockMany(breakLock bool, tokens map[string]string) ([]LockResult, error) {
+	results := make([]LockResult, 0, len(tokens))
+	for _, path := range sortedKeys(tokens) {
+		err := s.Unlock(path, tokens[path], breakLock)
+		var svnErr Error
+		if err != nil && !errors.As(err, &svnErr) {
+			return nil, err
+		}
+		results = append(results, LockResult{Path: path, Err: err})
+	}
+	return results, nil
+}
+
+// Commit creates a transaction with a POST request, calls fn to describe
+// the changes using an [Editor] which sends them to the transaction, and
+// merges it into a new revision with a MERGE request (or deletes it, if
+// fn returns an error).  See [Client.Commit].
+func (s *davSession) Commit(message string, revProps Props, lockTokens map[string]string, keepLocks bool, fn func(Editor) error) (CommitInfo, error) {
+	props := Props{PropRevLog: message}
+	for name, value := range revProps {
+		props[name] = value
+	}
+	var skel strings.Builder
+	skel.WriteString("( create-txn-with-props (")
+	for _, name := range props.Names() {
+		fmt.Fprintf(&skel, " %d %s %d %s", len(name), name, len(props[name]), props[name])
+	}
+	skel.WriteString(" ) )")
+	resp, err := s.request("POST", s.me, map[string]string{"Content-Type": davSkelType}, skel.String())
+	if err != nil {
+		return CommitInfo{}, fmt.Errorf("Commit: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	name := resp.Header.Get(davTxnNameHeader)
+	if name == "" {
+		return CommitInfo{}, fmt.Errorf("Commit: the server did not send the name of the transaction")
+	}
+
+	e := &davCommitEditor{
+		s:          s,
+		txnRoot:    s.txnRoot + "/" + name,
+		txn:        s.txn + "/" + name,
+		nodes:      map[string]*davCommitNode{},
+		lockTokens: lockTokens,
+		keepLocks:  keepLocks,
+	}
+	if err = fn(e); err != nil {
+		e.AbortEdit()
+		return CommitInfo{}, err
+	}
+	if err = e.CloseEdit(); err != nil {
+		e.AbortEdit()
+		return CommitInfo{}, fmt.Errorf("Commit: %w", err)
+	}
+	return e.info, nil
+}
+
+// A davCommitEditor is the Editor used by [davSession.Commit]:
+// every change is sent as a request on the transaction,
+// and closing the edit merges it.
+type davCommitEditor struct {
+	s          *davSession
+	txnRoot    string
+	txn        string
+	nodes      map[string]*davCommitNode
+	lockTokens map[string]string
+	keepLocks  bool
+	info       CommitInfo
+}
+
+// A davCommitNode is a directory or file open in a davCommitEditor.
+type davCommitNode struct {
+	// path is the path in the repository.
+	path  string
+	rev   *uint
+	added bool
+	props PropDelta
+	// delta is the svndiff sent to ApplyTextDelta, if any.
+	delta        *bytes.Buffer
+	baseChecksum string
+}
+
+func (e *davCommitEditor) node(token string) (*davCommitNode, error) {
+	n, ok := e.nodes[token]
+	if !ok {
+		return nil, fmt.Errorf("unknown token %q", token)
+	}
+	return n, nil
+}
+
+func (e *davCommitEditor) open(token string, path string, rev *uint, added bool) {
+	e.nodes[token] = &davCommitNode{path: e.s.reposPath(path), rev: rev, added: added, props: PropDelta{}}
+}
+
+// send sends a request on the transaction, and discards the response.
+func (e *davCommitEditor) send(method string, p string, header map[string]string, body string) error {
+	resp, err := e.s.request(method, p, header, body)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// versionHeader returns the headers with the base revision of a node,
+// used by the server to check that it is up to date.
+func versionHeader(rev *uint) map[string]string {
+	header := map[string]string{}
+	if rev != nil {
+		header[davVersionHeader] = strconv.FormatUint(uint64(*rev), 10)
+	}
+	return header
+}
+
+// copy copies copyPath (a URL or a path in the repository) in copyRev
+// to path in the transaction.
+func (e *davCommitEditor) copy(copyPath string, copyRev uint, path string) error {
+	source := copyPath
+	if rel, ok := relativeURL(e.s.info.URL, copyPath); ok {
+		source = "/" + rel
+	}
+	dest := url.URL{Path: e.txnRoot + e.s.reposPath(path)}
+	return e.send("COPY", e.s.revPath(copyRev, source), map[string]string{
+		"Destination": e.s.base + dest.EscapedPath(),
+		"Depth":       "infinity",
+	}, "")
+}
+
+func (e *davCommitEditor) TargetRev(rev uint) error {
+	return nil
+}
+
+func (e *davCommitEditor) OpenRoot(rev *uint, rootToken string) error {
+	e.open(rootToken, "", rev, false)
+	return nil
+}
+
+func (e *davCommitEditor) DeleteEntry(path string, rev *uint, dirToken string) error {
+	return e.send("DELETE", e.txnRoot+e.s.reposPath(path), versionHeader(rev), "")
+}
+
+func (e *davCommitEditor) AddDir(path string, parentToken string, childToken string, copyPath string, copyRev uint) error {
+	e.open(childToken, path, nil, true)
+	if copyPath != "" {
+		return e.copy(copyPath, copyRev, path)
+	}
+	return e.send("MKCOL", e.txnRoot+e.s.reposPath(path), nil, "")
+}
+
+func (e *davCommitEditor) OpenDir(path string, parentToken string, childToken string, rev *uint) error {
+	e.open(childToken, path, rev, false)
+	return nil
+}
+
+func (e *davCommitEditor) ChangeDirProp(dirToken string, name string, value *string) error {
+	n, err := e.node(dirToken)
+	if err != nil {
+		return err
+	}
+	n.props[name] = value
+	return nil
+}
+
+func (e *davCommitEditor) CloseDir(dirToken string) error {
+	n, err := e.node(dirToken)
+	if err != nil {
+		return err
+	}
+	delete(e.nodes, dirToken)
+	return e.proppatch(n)
+}
+
+// proppatch sends a PROPPATCH request with the properties
+// changed in a node, if any.
+func (e *davCommitEditor) proppatch(n *davCommitNode) error {
+	if len(n.props) == 0 {
+		return nil
+	}
+	var set, remove strings.Builder
+	for _, name := range sortedKeys(n.props) {
+		xmlName := davPropXMLName(name)
+		prefix := "S"
+		if xmlName.Space == davPropNS {
+			prefix = "C"
+		}
+		if n.props[name] == nil {
+			fmt.Fprintf(&remove, `<%s:%s/>`, prefix, xmlName.Local)
+			continue
+		}
+		attr, value := xmlValue("V:encoding", *n.props[name])
+		fmt.Fprintf(&set, `<%s:%s%s>%s</%[1]s:%[2]s>`, prefix, xmlName.Local, attr, value)
+	}
+	body := davXMLHeader + `<D:propertyupdate xmlns:D="DAV:" xmlns:V="` + davLiveNS +
+		`" xmlns:S="` + davPropSVNNS + `" xmlns:C="` + davPropNS + `">`
+	if set.Len() > 0 {
+		body += `<D:set><D:prop>` + set.String() + `</D:prop></D:set>`
+	}
+	if remove.Len() > 0 {
+		body += `<D:remove><D:prop>` + remove.String() + `</D:prop></D:remove>`
+	}
+	body += `</D:propertyupdate>`
+	header := versionHeader(n.rev)
+	header["Content-Type"] = davXMLType
+	return e.send("PROPPATCH", e.txnRoot+n.path, header, body)
+}
+
+func (e *davCommitEditor) AbsentDir(path string, parentToken string) error {
+	return nil
+}
+
+func (e *davCommitEditor) AddFile(path string, dirToken string, fileToken string, copyPath string, copyRev uint) error {
+	// new files are created when their contents are sent,
+	// even if they are empty.
+	e.open(fileToken, path, nil, copyPath == "")
+	if copyPath != "" {
+		return e.copy(copyPath, copyRev, path)
+	}
+	return nil
+}
+
+func (e *davCommitEditor) OpenFile(path string, dirToken string, fileToken string, rev *uint) error {
+	e.open(fileToken, path, rev, false)
+	return nil
+}
+
+// davDeltaBuffer keeps a text delta until the file is closed.
+type davDeltaBuffer struct {
+	*bytes.Buffer
+}
+
+func (davDeltaBuffer) Close() error {
+	return nil
+}
+
+func (e *davCommitEditor) ApplyTextDelta(fileToken string, baseChecksum string) (io.WriteCloser, error) {
+	n, err := e.node(fileToken)
+	if err != nil {
+		return nil, err
+	}
+	n.delta = &bytes.Buffer{}
+	n.baseChecksum = baseChecksum
+	return davDeltaBuffer{n.delta}, nil
+}
+
+func (e *davCommitEditor) ChangeFileProp(fileToken string, name string, value *string) error {
+	return e.ChangeDirProp(fileToken, name, value)
+}
+
+func (e *davCommitEditor) CloseFile(fileToken string, textChecksum string) error {
+	n, err := e.node(fileToken)
+	if err != nil {
+		return err
+	}
+	delete(e.nodes, fileToken)
+	if n.delta != nil || n.added {
+		delta := svndiff.Diff(nil, nil, 0)
+		if n.delta != nil {
+			delta = n.delta.Bytes()
+		}
+		header := versionHeader(n.rev)
+		header["Content-Type"] = davSvndiffType
+		if n.baseChecksum != "" {
+			header[davBaseMD5Header] = n.baseChecksum
+		}
+		if textChecksum != "" {
+			header[davResultMD5Header] = textChecksum
+		}
+		if err = e.send("PUT", e.txnRoot+n.path, header, string(delta)); err != nil {
+			return err
+		}
+	}
+	return e.proppatch(n)
+}
+
+func (e *davCommitEditor) AbsentFile(path string, parentToken string) error {
+	return nil
+}
+
+// CloseEdit sends a MERGE request, which makes a new revision
+// from the transaction.
+func (e *davCommitEditor) CloseEdit() error {
+	var body strings.Builder
+	body.WriteString(davXMLHeader + `<D:merge xmlns:D="DAV:">`)
+	fmt.Fprintf(&body, `<D:source><D:href>%s</D:href></D:source>`, xmlEscape(e.txn))
+	body.WriteString(`<D:no-auto-merge/><D:no-checkout/><D:prop><D:checked-in/><D:version-name/>` +
+		`<D:resourcetype/><D:creationdate/><D:creator-displayname/></D:prop>`)
+	if len(e.lockTokens) > 0 {
+		body.WriteString(`<S:lock-token-list xmlns:S="svn:">`)
+		for _, p := range sortedKeys(e.lockTokens) {
+			fmt.Fprintf(&body, `<S:lock><S:lock-path>%s</S:lock-path><S:lock-token>%s</S:lock-token></S:lock>`,
+				xmlEscape(p), xmlEscape(e.lockTokens[p]))
+		}
+		body.WriteString(`</S:lock-token-list>`)
+	}
+	body.WriteString(`</D:merge>`)
+	header := map[string]string{"Content-Type": davXMLType}
+	if !e.keepLocks {
+		header[davOptionsHeader] = "release-locks"
+	}
+	resp, err := e.s.request("MERGE", e.s.publicPath(e.s.reposPath("")), header, body.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	found := false
+	err = walkXML(resp.Body, func(d *xml.Decoder, start xml.StartElement) error {
+		switch start.Name {
+		case davDAVResponse:
+			var r davResponse
+			if err := d.DecodeElement(&r, &start); err != nil {
+				return err
+			}
+			props := r.props()
+			for _, child := range props[davResourceType].Children {
+				if child.XMLName.Local == "baseline" {
+					rev, err := xmlRev(props[davVersionName].Text)
+					if err != nil {
+						return err
+					}
+					found = true
+					e.info.Rev = rev
+					e.info.Date = strings.TrimSpace(props[davCreationDate].Text)
+					e.info.Author = props[davCreator].String()
+				}
+			}
+		case davPostCommitErr:
+			return d.DecodeElement(&e.info.PostCommitErr, &start)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("the MERGE response did not include the new revision")
+	}
+	return nil
+}
+
+// AbortEdit deletes the transaction.
+func (e *davCommitEditor) AbortEdit() error {
+	return e.send("DELETE", e.txn, nil, "")
+}
@@ -0,0 +1,378 @@
+package svn
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cespedes/svn/svndiff"
+)
+
+// fakeDAV is a minimal imitation of mod_dav_svn, serving a repository
+// in "/repo" whose latest revision is 3, with a directory "trunk"
+// and a file "trunk/a b.txt".
+type fakeDAV struct {
+	// requests are the method and path of every request received.
+	requests []string
+	// bodies are the bodies of the requests, indexed as requests.
+	bodies []string
+}
+
+const fakeDAVFile = "hello\n"
+
+func (f *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.bodies = append(f.bodies, string(body))
+	sum := md5.Sum([]byte(fakeDAVFile))
+	entry := func(href string, dir bool, allprop bool) string {
+		props := `<D:version-name>2</D:version-name><D:creationdate>2024-01-02T03:04:05.000000Z</D:creationdate>` +
+			`<D:creator-displayname>alice</D:creator-displayname>`
+		if dir {
+			props += `<D:resourcetype><D:collection/></D:resourcetype><V:deadprop-count>0</V:deadprop-count>`
+		} else {
+			props += `<D:resourcetype/><D:getcontentlength>6</D:getcontentlength>` +
+				`<V:md5-checksum>` + hex.EncodeToString(sum[:]) + `</V:md5-checksum><V:deadprop-count>2</V:deadprop-count>`
+			if allprop {
+				props += `<S:mime-type>text/plain</S:mime-type><C:bin V:encoding="base64">AAE=</C:bin>`
+			}
+		}
+		return `<D:response><D:href>` + href + `</D:href><D:propstat><D:prop>` + props +
+			`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat>` +
+			`<D:propstat><D:prop><D:getcontentlanguage/></D:prop><D:status>HTTP/1.1 404 Not Found</D:status></D:propstat></D:response>`
+	}
+	multistatus := func(responses ...string) {
+		w.Header().Set("Content-Type", davXMLType)
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, davXMLHeader+`<D:multistatus xmlns:D="DAV:" xmlns:V="`+davLiveNS+`" xmlns:S="`+davPropSVNNS+
+			`" xmlns:C="`+davPropNS+`">`+strings.Join(responses, "")+`</D:multistatus>`)
+	}
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, davXMLHeader+`<D:error xmlns:D="DAV:" xmlns:m="http://apache.org/dav/xmlns" xmlns:C="svn:">`+
+			`<C:error/><m:human-readable errcode="160013">`+"\nPath not found\n"+`</m:human-readable></D:error>`)
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "OPTIONS /repo/trunk", "OPTIONS /repo/":
+		h := w.Header()
+		h.Add("DAV", "1,2")
+		h.Add("DAV", davCapPrefix+"depth, "+davCapPrefix+"log-revprops")
+		h.Set(davMergeinfoHeader, "yes")
+		h.Set(davYoungestRevHeader, "3")
+		h.Set(davUUIDHeader, "0b1d3c55-uuid")
+		h.Set(davRootHeader, "/repo")
+		h.Set(davMeHeader, "/repo/!svn/me")
+		h.Set(davRevRootHeader, "/repo/!svn/rvr")
+		h.Set(davTxnRootHeader, "/repo/!svn/txr")
+		h.Set(davTxnHeader, "/repo/!svn/txn")
+	case "PROPFIND /repo/!svn/rvr/3/trunk":
+		if r.Header.Get("Depth") == "0" {
+			multistatus(entry("/repo/!svn/rvr/3/trunk/", true, false))
+			return
+		}
+		multistatus(entry("/repo/!svn/rvr/3/trunk/", true, false), entry("/repo/!svn/rvr/3/trunk/a%20b.txt", false, false))
+	case "PROPFIND /repo/!svn/rvr/3/trunk/a b.txt":
+		multistatus(entry("/repo/!svn/rvr/3/trunk/a%20b.txt", false, strings.Contains(string(body), "allprop")))
+	case "GET /repo/!svn/rvr/3/trunk/a b.txt":
+		fmt.Fprint(w, fakeDAVFile)
+	case "REPORT /repo/!svn/rvr/3/trunk":
+		fmt.Fprint(w, davXMLHeader+`<S:log-report xmlns:S="svn:" xmlns:D="DAV:">`+
+			`<S:log-item><D:version-name>3</D:version-name><D:creator-displayname>bob</D:creator-displayname>`+
+			`<S:date>2024-01-03T00:00:00.000000Z</S:date><D:comment encoding="base64">Zml4CnRoaW5ncw==</D:comment>`+
+			`<S:modified-path node-kind="file">/trunk/a b.txt</S:modified-path><S:added-path>/trunk/c</S:added-path></S:log-item>`+
+			`<S:log-item><D:version-name>2</D:version-name><D:creator-displayname>alice</D:creator-displayname>`+
+			`<S:date>2024-01-02T03:04:05.000000Z</S:date><D:comment>first &amp; only</D:comment></S:log-item>`+
+			`</S:log-report>`)
+	case "POST /repo/!svn/me":
+		w.Header().Set(davTxnNameHeader, "3-4")
+		w.WriteHeader(http.StatusCreated)
+	case "PUT /repo/!svn/txr/3-4/trunk/a b.txt",
+		"MKCOL /repo/!svn/txr/3-4/trunk/dir", "PROPPATCH /repo/!svn/txr/3-4/trunk/dir":
+		w.WriteHeader(http.StatusCreated)
+	case "MERGE /repo/trunk":
+		fmt.Fprint(w, davXMLHeader+`<D:merge-response xmlns:D="DAV:"><D:updated-set>`+
+			`<D:response><D:href>/repo/!svn/vcc/default</D:href><D:propstat><D:prop>`+
+			`<D:resourcetype><D:baseline/></D:resourcetype><D:version-name>4</D:version-name>`+
+			`<D:creationdate>2024-01-04T00:00:00.000000Z</D:creationdate><D:creator-displayname>alice</D:creator-displayname>`+
+			`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`+
+			`</D:updated-set></D:merge-response>`)
+	case "DELETE /repo/!svn/txn/3-4":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		notFound()
+	}
+}
+
+func TestDAVSession(t *testing.T) {
+	f := &fakeDAV{}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	s, err := Open(ts.URL + "/repo/trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	info := s.ReposInfo()
+	if info.URL != ts.URL+"/repo" || info.UUID != "0b1d3c55-uuid" {
+		t.Errorf("ReposInfo: %+v", info)
+	}
+	for _, capability := range []string{"depth", "log-revprops", "mergeinfo", "commit-revprops"} {
+		if !s.HasCapability(capability) {
+			t.Errorf("missing capability %q in %q", capability, info.Capabilities)
+		}
+	}
+	if rev, err := s.GetLatestRev(); err != nil || rev != 3 {
+		t.Errorf("GetLatestRev: %d, %v", rev, err)
+	}
+
+	stat, err := s.Stat("a b.txt", nil)
+	want := Stat{Kind: NodeFile, Size: 6, HasProps: true, CreatedRev: 2, CreatedDate: "2024-01-02T03:04:05.000000Z", LastAuthor: "alice"}
+	if err != nil || stat != want {
+		t.Errorf("Stat: %+v, %v", stat, err)
+	}
+	if kind, err := s.CheckPath("missing", nil); err != nil || kind != NodeNone {
+		t.Errorf("CheckPath(missing): %q, %v", kind, err)
+	}
+
+	dirents, err := s.List("", nil, "immediates", nil)
+	if err != nil || len(dirents) != 2 || dirents[0].Path != "/trunk" || dirents[0].Kind != NodeDir ||
+		dirents[1].Path != "/trunk/a b.txt" || dirents[1].Size != 6 {
+		t.Errorf("List: %+v, %v", dirents, err)
+	}
+	dir, err := s.GetDir("", nil, false, true, nil, false)
+	if err != nil || dir.Rev != 3 || len(dir.Entries) != 1 || dir.Entries[0].Path != "a b.txt" {
+		t.Errorf("GetDir: %+v, %v", dir, err)
+	}
+
+	file, err := s.GetFile("a b.txt", nil, true, true, false)
+	if err != nil || string(file.Contents) != fakeDAVFile || file.Rev != 3 ||
+		file.Props["svn:mime-type"] != "text/plain" || file.Props["bin"] != "\x00\x01" {
+		t.Errorf("GetFile: %+v, %v", file, err)
+	}
+	_, err = s.GetFile("missing", nil, false, true, false)
+	var svnErr Error
+	if !errors.As(err, &svnErr) || svnErr != (Error{AprErr: 160013, Message: "Path not found"}) {
+		t.Errorf("GetFile(missing): %v", err)
+	}
+
+	entries, err := s.Log(nil, nil, nil, true)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Log: %+v, %v", entries, err)
+	}
+	if e := entries[0]; e.Rev != 3 || e.Author != "bob" || e.Message != "fix\nthings" || len(e.Changed) != 2 ||
+		e.Changed[0].Path != "/trunk/a b.txt" || e.Changed[0].Mode != "M" || e.Changed[1].Mode != "A" {
+		t.Errorf("Log: %+v", e)
+	}
+	if e := entries[1]; e.Rev != 2 || e.Message != "first & only" || len(e.Changed) != 0 {
+		t.Errorf("Log: %+v", e)
+	}
+}
+
+func TestDAVCommit(t *testing.T) {
+	f := &fakeDAV{}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	s, err := Open(ts.URL + "/repo/trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.requests = nil
+	f.bodies = nil
+	rev := uint(2)
+	value := "v"
+	info, err := s.Commit("msg", nil, nil, false, func(e Editor) error {
+		if err := e.OpenRoot(&rev, "d0"); err != nil {
+			return err
+		}
+		if err := e.OpenFile("a b.txt", "d0", "f1", &rev); err != nil {
+			return err
+		}
+		w, err := e.ApplyTextDelta("f1", "")
+		if err != nil {
+			return err
+		}
+		w.Write(svndiff.Diff([]byte(fakeDAVFile), []byte("bye\n"), 0))
+		w.Close()
+		if err = e.CloseFile("f1", ""); err != nil {
+			return err
+		}
+		if err = e.AddDir("dir", "d0", "d1", "", 0); err != nil {
+			return err
+		}
+		if err = e.ChangeDirProp("d1", "svn:ignore", &value); err != nil {
+			return err
+		}
+		if err = e.CloseDir("d1"); err != nil {
+			return err
+		}
+		return e.CloseDir("d0")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Rev != 4 || info.Author != "alice" || info.Date != "2024-01-04T00:00:00.000000Z" {
+		t.Errorf("CommitInfo: %+v", info)
+	}
+	want := []string{
+		"POST /repo/!svn/me",
+		"PUT /repo/!svn/txr/3-4/trunk/a b.txt",
+		"MKCOL /repo/!svn/txr/3-4/trunk/dir",
+		"PROPPATCH /repo/!svn/txr/3-4/trunk/dir",
+		"MERGE /repo/trunk",
+	}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests:\nwant %q\ngot  %q", want, f.requests)
+	}
+	if len(f.bodies) == len(want) {
+		if f.bodies[0] != "( create-txn-with-props ( 7 svn:log 3 msg ) )" {
+			t.Errorf("POST body: %q", f.bodies[0])
+		}
+		if got, err := svndiff.Apply([]byte(fakeDAVFile), []byte(f.bodies[1])); err != nil || string(got) != "bye\n" {
+			t.Errorf("PUT body: %q, %v", got, err)
+		}
+		if !strings.Contains(f.bodies[3], `<S:ignore>v</S:ignore>`) {
+			t.Errorf("PROPPATCH body: %q", f.bodies[3])
+		}
+		if !strings.Contains(f.bodies[4], `<D:href>/repo/!svn/txn/3-4</D:href>`) {
+			t.Errorf("MERGE body: %q", f.bodies[4])
+		}
+	}
+
+	// a failure aborts the transaction:
+	f.requests = nil
+	_, err = s.Commit("msg", nil, nil, false, func(e Editor) error {
+		e.OpenRoot(&rev, "d0")
+		return e.DeleteEntry("missing", &rev, "d0")
+	})
+	want = []string{
+		"POST /repo/!svn/me",
+		"DELETE /repo/!svn/txr/3-4/trunk/missing",
+		"DELETE /repo/!svn/txn/3-4",
+	}
+	if err == nil || !slices.Equal(f.requests, want) {
+		t.Errorf("failed commit: %v\nwant %q\ngot  %q", err, want, f.requests)
+	}
+}
+
+// closeRecorder is an Editor which records the calls of another one,
+// taking CloseEdit and AbortEdit as the end of the edit.
+type closeRecorder struct {
+	Editor
+	closed  bool
+	aborted bool
+}
+
+func (e *closeRecorder) CloseEdit() error {
+	e.closed = true
+	return nil
+}
+
+func (e *closeRecorder) AbortEdit() error {
+	e.aborted = true
+	return nil
+}
+
+// failingEditor is a closeRecorder which fails to add files.
+type failingEditor struct {
+	closeRecorder
+}
+
+func (e *failingEditor) AddFile(path string, dirToken string, fileToken string, copyPath string, copyRev uint) error {
+	return errors.New("no space left")
+}
+
+func TestDAVUpdate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"trunk/a.txt":     {Data: []byte("hello\n")},
+		"trunk/doc/b.txt": {Data: []byte("bye\n")},
+	}
+	var ts *httptest.Server
+	ts = httptest.NewServer(&HTTPHandler{NewServer: func(*http.Request) (*Server, error) { return NewFSServer(fsys, ts.URL), nil }})
+	defer ts.Close()
+	sess, err := Open(ts.URL + "/trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	c := serverClient(t, NewFSServer(fsys, "svn://example.com/repo"), "svn://example.com/repo/trunk")
+
+	// update runs a report through a session, and returns
+	// the names of the editor commands and the commands:
+	update := func(sess Session, report *Report) ([]string, []Item) {
+		t.Helper()
+		var items []Item
+		e := &closeRecorder{Editor: editorItems(&items)}
+		if err := sess.Update(report, e); err != nil || !e.closed {
+			t.Fatalf("%s: closed=%v, %v", sess.URL(), e.closed, err)
+		}
+		var names []string
+		for _, item := range items {
+			names = append(names, item.List[0].Text)
+		}
+		return names, items
+	}
+	checkout := &Report{
+		Command:    "update",
+		Depth:      "infinity",
+		TextDeltas: true,
+		Paths:      []ReportedPath{{Rev: 0, StartEmpty: true, Depth: "infinity"}},
+	}
+	got, items := update(sess, checkout)
+	want, _ := update(c, checkout)
+	if !slices.Equal(got, want) {
+		t.Errorf("checkout:\n got %q\nwant %q", got, want)
+	}
+	// the first file is a.txt:
+	var delta []byte
+	var checksum string
+	for _, item := range items {
+		switch item.List[0].Text {
+		case "textdelta-chunk":
+			if delta == nil {
+				delta = []byte(item.List[1].List[1].Text)
+			}
+		case "close-file":
+			if checksum == "" {
+				checksum = item.List[1].List[1].List[0].Text
+			}
+		}
+	}
+	if contents, err := svndiff.Apply(nil, delta); err != nil || string(contents) != "hello\n" {
+		t.Errorf("contents of a.txt: %q, %v", contents, err)
+	}
+	if want := fmt.Sprintf("%x", md5.Sum([]byte("hello\n"))); checksum != want {
+		t.Errorf("checksum of a.txt: %q, want %q", checksum, want)
+	}
+
+	upToDate := &Report{Command: "update", Depth: "infinity", Paths: []ReportedPath{{Rev: 1, Depth: "infinity"}}}
+	got, _ = update(sess, upToDate)
+	if want := []string{"target-rev", "open-root", "close-dir"}; !slices.Equal(got, want) {
+		t.Errorf("update of trunk:\n got %q\nwant %q", got, want)
+	}
+
+	// a failure of the editor aborts the edit:
+	e := &failingEditor{closeRecorder: closeRecorder{Editor: editorItems(new([]Item))}}
+	if err := sess.Update(checkout, e); err == nil || !strings.Contains(err.Error(), "no space left") || e.closed || !e.aborted {
+		t.Errorf("failed update: closed=%v aborted=%v, %v", e.closed, e.aborted, err)
+	}
+
+	// a server without updates:
+	ts.Config.Handler = &HTTPHandler{NewServer: func(*http.Request) (*Server, error) {
+		return &Server{GetLatestRev: func() (uint, error) { return 1, nil }}, nil
+	}}
+	var svnErr Error
+	if err = sess.Update(checkout, &closeRecorder{Editor: editorItems(new([]Item))}); !errors.As(err, &svnErr) || svnErr.AprErr != 210001 {
+		t.Errorf("update without Update: %v", err)
+	}
+}
@@ -0,0 +1,1345 @@
+package svn
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cespedes/svn/svndiff"
+)
+
+// An HTTPHandler serves a repository through HTTP, implementing the read
+// side of the WebDAV-based protocol of mod_dav_svn ("HTTPv2"), so that
+// Subversion clients can read it (list, cat, log, blame, checkout...)
+// and browsers can navigate it.
+//
+// Every request is handled by a new [Server], returned by NewServer, whose
+// functions are called as in [Server.Serve]: first Greet, with the URL
+// of the root of the repository, and then the ones needed to answer the
+// request, with paths relative to that root.  The user name sent by the
+// client with basic authentication, if any, is the User of the session.
+//
+// The password is not verified: the requests must be authenticated before
+// they reach the handler (by a front proxy, or by a wrapping [http.Handler]),
+// or by NewServer, which can check [http.Request.BasicAuth] and reject the
+// request returning an [Error] with code 170001 (SVN_ERR_RA_NOT_AUTHORIZED),
+// answered with "403 Forbidden".
+//
+// Only the methods used to read are supported: OPTIONS, PROPFIND,
+// GET, HEAD and REPORT.
+type HTTPHandler struct {
+	// Root is the path of the repository in the URLs
+	// (such as "/svn/repo"), or "" if it is served at "/".
+	Root string
+	// NewServer returns the Server which handles a request.
+	NewServer func(r *http.Request) (*Server, error)
+}
+
+// davRequest is the state of a request being served by an HTTPHandler.
+type davRequest struct {
+	s    *Server
+	w    http.ResponseWriter
+	r    *http.Request
+	root string
+	// target is the resource of the request.
+	target davTarget
+	head   *uint
+}
+
+// A davTarget is a resource in a HTTPHandler.
+type davTarget struct {
+	// path is the path in the repository, without a leading slash.
+	path string
+	// rev is the revision, or nil for the latest one.
+	rev *uint
+	// me is true for the resource to which the requests which
+	// do not apply to a single path are sent.
+	me bool
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	root := strings.TrimSuffix(h.Root, "/")
+	p, ok := strings.CutPrefix(r.URL.Path, root)
+	if !ok || (p != "" && p[0] != '/') {
+		http.NotFound(w, r)
+		return
+	}
+	s, err := h.NewServer(r)
+	if err != nil {
+		writeDAVError(w, err)
+		return
+	}
+	d := &davRequest{s: s.connection(), w: w, r: r, root: root}
+	if err = d.greet(); err != nil {
+		writeDAVError(w, err)
+		return
+	}
+	if d.target, err = d.parseTarget(p); err != nil {
+		writeDAVError(w, err)
+		return
+	}
+	switch r.Method {
+	case "OPTIONS":
+		err = d.options()
+	case "PROPFIND":
+		err = d.propfind()
+	case "GET", "HEAD":
+		err = d.get()
+	case "REPORT":
+		err = d.report()
+	default:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PROPFIND, REPORT")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		fmt.Fprint(w, davErrorBody(Error{
+			AprErr:  175002, // SVN_ERR_RA_DAV_REQUEST_FAILED
+			Message: fmt.Sprintf("Method %s is not supported: the repository is read-only", r.Method),
+		}))
+		return
+	}
+	if err != nil {
+		writeDAVError(w, err)
+	}
+}
+
+// url returns the URL of a path in the repository.
+func (d *davRequest) url(p string) string {
+	u := url.URL{Scheme: "http", Host: d.r.Host, Path: d.root}
+	if d.r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if p != "" {
+		u.Path += "/" + p
+	}
+	return u.String()
+}
+
+// greet calls the Greet function of the server,
+// as if a client connected to the root of the repository.
+func (d *davRequest) greet() error {
+	if user, _, ok := d.r.BasicAuth(); ok {
+		d.s.session.User = user
+	}
+	agent := d.r.UserAgent()
+	return d.s.greet(SvnVersion, []string{"depth", "mergeinfo", "log-revprops"}, d.url(""), agent, &agent)
+}
+
+// parseTarget returns the resource of the path p of a URL,
+// relative to the root of the repository.
+func (d *davRequest) parseTarget(p string) (davTarget, error) {
+	p = strings.TrimPrefix(p, "/")
+	special, ok := strings.CutPrefix(p, "!svn/")
+	if !ok {
+		return davTarget{path: strings.Trim(p, "/")}, nil
+	}
+	kind, rest, _ := strings.Cut(special, "/")
+	switch kind {
+	case "me":
+		return davTarget{me: true}, nil
+	case "vcc":
+		// the version-controlled configuration, announced in the
+		// properties of every node, is where the clients without
+		// HTTPv2 send their REPORT requests:
+		if strings.Trim(rest, "/") == "default" {
+			return davTarget{me: true}, nil
+		}
+	case "rvr", "ver":
+		revText, rest, _ := strings.Cut(rest, "/")
+		rev, err := strconv.ParseUint(revText, 10, 0)
+		if err != nil {
+			break
+		}
+		r := uint(rev)
+		return davTarget{path: strings.Trim(rest, "/"), rev: &r}, nil
+	}
+	return davTarget{}, Error{
+		AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+		Message: fmt.Sprintf("Unknown resource '%s'", d.r.URL.Path),
+	}
+}
+
+// unimplemented returns the error used when the server
+// does not implement a function needed for a request.
+func unimplemented(name string) error {
+	return Error{
+		AprErr:  210001,
+		Message: fmt.Sprintf("Command '%s' unimplemented", name),
+	}
+}
+
+// latest returns the latest revision of the repository.
+func (d *davRequest) latest() (uint, error) {
+	if d.head != nil {
+		return *d.head, nil
+	}
+	if d.s.GetLatestRev == nil {
+		return 0, unimplemented("get-latest-rev")
+	}
+	head, err := d.s.GetLatestRev()
+	if err != nil {
+		return 0, err
+	}
+	d.head = &head
+	return head, nil
+}
+
+// rev returns rev, or the latest revision if it is nil.
+func (d *davRequest) rev(rev *uint) (uint, error) {
+	if rev != nil {
+		return *rev, nil
+	}
+	return d.latest()
+}
+
+// join returns the path in the repository of p,
+// relative to the target of the request.
+func (d *davRequest) join(p string) string {
+	return strings.Trim(path.Join(d.target.path, p), "/")
+}
+
+// writeXML sends a successful response with an XML body.
+func (d *davRequest) writeXML(status int, body string) {
+	d.w.Header().Set("Content-Type", davXMLType)
+	d.w.WriteHeader(status)
+	io.WriteString(d.w, davXMLHeader+body)
+}
+
+// writeDAVError sends an error response, as mod_dav_svn does.
+func writeDAVError(w http.ResponseWriter, err error) {
+	var svnErr Error
+	if !errors.As(err, &svnErr) {
+		svnErr = Error{Message: err.Error()}
+	}
+	status := http.StatusInternalServerError
+	switch svnErr.AprErr {
+	case 160013, // SVN_ERR_FS_NOT_FOUND
+		160006: // SVN_ERR_FS_NO_SUCH_REVISION
+		status = http.StatusNotFound
+	case 170001: // SVN_ERR_RA_NOT_AUTHORIZED
+		status = http.StatusForbidden
+	case 210001, // unimplemented
+		200007: // SVN_ERR_UNSUPPORTED_FEATURE
+		status = http.StatusNotImplemented
+	case 160016, // SVN_ERR_FS_NOT_DIRECTORY
+		160017: // SVN_ERR_FS_NOT_FILE
+		status = http.StatusConflict
+	}
+	w.Header().Set("Content-Type", davXMLType)
+	w.WriteHeader(status)
+	io.WriteString(w, davErrorBody(svnErr))
+}
+
+// davErrorBody returns the XML description of an error.
+func davErrorBody(e Error) string {
+	return davXMLHeader + `<D:error xmlns:D="DAV:" xmlns:m="` + davApacheNS + `" xmlns:C="svn:">` + "\n" +
+		`<C:error/>` + "\n" +
+		`<m:human-readable errcode="` + strconv.Itoa(e.AprErr) + `">` + "\n" +
+		xmlEscape(e.Message) + "\n" +
+		`</m:human-readable>` + "\n" +
+		`</D:error>` + "\n"
+}
+
+// options answers an OPTIONS request, announcing the capabilities of
+// the server and the paths of the special resources in its headers.
+func (d *davRequest) options() error {
+	head, err := d.latest()
+	if err != nil {
+		return err
+	}
+	root := (&url.URL{Path: d.root}).EscapedPath()
+	caps := []string{"depth"}
+	if d.s.GetMergeinfo != nil {
+		caps = append(caps, "mergeinfo")
+		d.w.Header().Set(davMergeinfoHeader, "yes")
+	}
+	if d.s.GetIProps != nil {
+		caps = append(caps, "inherited-props")
+	}
+	if d.s.GetFileRevs != nil {
+		caps = append(caps, "file-revs-reverse")
+	}
+	for i, c := range caps {
+		caps[i] = davCapPrefix + davCapabilities[c]
+	}
+	h := d.w.Header()
+	h.Add("DAV", "1,2")
+	h.Add("DAV", "version-control,checkout,working-resource")
+	h.Add("DAV", "merge,baseline,activity,version-controlled-collection")
+	h.Add("DAV", strings.Join(caps, ", "))
+	h.Set("Allow", "OPTIONS,GET,HEAD,PROPFIND,REPORT")
+	h.Set(davYoungestRevHeader, strconv.FormatUint(uint64(head), 10))
+	h.Set(davUUIDHeader, d.s.info.UUID)
+	h.Set(davRootHeader, root)
+	h.Set(davMeHeader, root+"/!svn/me")
+	h.Set(davRevRootHeader, root+"/!svn/rvr")
+	h.Set(davRevHeader, root+"/!svn/rev")
+	d.writeXML(http.StatusOK, `<D:options-response xmlns:D="DAV:"><D:activity-collection-set>`+
+		`<D:href>`+root+`/!svn/act/</D:href></D:activity-collection-set></D:options-response>`)
+	return nil
+}
+
+// stat returns the status of a path in the repository.
+func (d *davRequest) stat(p string, rev uint) (Dirent, error) {
+	switch {
+	case d.s.Stat != nil:
+		stat, err := d.s.Stat(p, &rev)
+		return Dirent{
+			Path:        p,
+			Kind:        stat.Kind,
+			Size:        stat.Size,
+			HasProps:    stat.HasProps,
+			CreatedRev:  stat.CreatedRev,
+			CreatedDate: stat.CreatedDate,
+			LastAuthor:  stat.LastAuthor,
+		}, err
+	case d.s.CheckPath != nil:
+		kind, err := d.s.CheckPath(p, &rev)
+		return Dirent{Path: p, Kind: kind}, err
+	}
+	return Dirent{}, unimplemented("stat")
+}
+
+// node returns the status of a path, failing if it does not exist.
+func (d *davRequest) node(p string, rev uint) (Dirent, error) {
+	dirent, err := d.stat(p, rev)
+	if err == nil && dirent.Kind == NodeNone {
+		err = Error{
+			AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+			Message: fmt.Sprintf("File not found: revision %d, path '/%s'", rev, p),
+		}
+	}
+	return dirent, err
+}
+
+// entries returns the entries of a directory, whose paths are their names.
+func (d *davRequest) entries(p string, rev uint) ([]Dirent, error) {
+	switch {
+	case d.s.GetDir != nil:
+		dir, err := d.s.GetDir(p, &rev, false, true, nil)
+		return dir.Entries, err
+	case d.s.List != nil:
+		dirents, err := d.s.List(p, &rev, "immediates", nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var entries []Dirent
+		for _, dirent := range dirents {
+			if strings.Trim(dirent.Path, "/") != p {
+				dirent.Path = path.Base(dirent.Path)
+				entries = append(entries, dirent)
+			}
+		}
+		return entries, nil
+	}
+	return nil, unimplemented("get-dir")
+}
+
+// props returns the properties of a node.
+func (d *davRequest) props(p string, rev uint, kind NodeKind) (Props, error) {
+	if kind == NodeDir {
+		if d.s.GetDir == nil {
+			return nil, unimplemented("get-dir")
+		}
+		dir, err := d.s.GetDir(p, &rev, true, false, nil)
+		return dir.Props, err
+	}
+	if d.s.GetFile == nil && d.s.GetFileReader == nil {
+		return nil, unimplemented("get-file")
+	}
+	file, r, err := d.s.getFile(p, &rev, true, false)
+	if r != nil {
+		closeReader(r)
+	}
+	return file.Props, err
+}
+
+// checksum returns the MD5 checksum of a file.
+func (d *davRequest) checksum(p string, rev uint) (string, error) {
+	if d.s.GetFile == nil && d.s.GetFileReader == nil {
+		return "", unimplemented("get-file")
+	}
+	file, r, err := d.s.getFile(p, &rev, false, true)
+	if err != nil {
+		return "", err
+	}
+	defer closeReader(r)
+	if len(file.Checksum) == 2*16 {
+		return file.Checksum, nil
+	}
+	h := newChecksum("")
+	if _, err = io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return h.String(), nil
+}
+
+// Names of the live properties sent in response to "allprop".
+var davAllProps = []xml.Name{
+	davResourceType, davContentLength, {Space: davNS, Local: "getcontenttype"},
+	davVersionName, davCreationDate, {Space: davNS, Local: "getlastmodified"}, davCreator,
+	{Space: davNS, Local: "getetag"}, {Space: davNS, Local: "checked-in"},
+	{Space: davNS, Local: "version-controlled-configuration"},
+	{Space: davLiveNS, Local: "baseline-relative-path"}, {Space: davLiveNS, Local: "repository-uuid"},
+	davDeadPropCount,
+}
+
+// propfind answers a PROPFIND request with the requested properties
+// of the target and, if the depth is not 0, of its entries.
+func (d *davRequest) propfind() error {
+	if d.target.me {
+		return Error{
+			AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+			Message: "The resource has no properties",
+		}
+	}
+	var req struct {
+		Prop struct {
+			Names []struct {
+				XMLName xml.Name
+			} `xml:",any"`
+		} `xml:"DAV: prop"`
+	}
+	body, err := io.ReadAll(d.r.Body)
+	if err != nil {
+		return err
+	}
+	var names []xml.Name
+	allProps := true
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err = xml.Unmarshal(body, &req); err != nil {
+			return Error{
+				AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+				Message: fmt.Sprintf("Malformed PROPFIND request: %v", err),
+			}
+		}
+		for _, n := range req.Prop.Names {
+			names = append(names, n.XMLName)
+		}
+		allProps = len(names) == 0
+	}
+
+	rev, err := d.rev(d.target.rev)
+	if err != nil {
+		return err
+	}
+	self, err := d.node(d.target.path, rev)
+	if err != nil {
+		return err
+	}
+	href := d.r.URL.Path
+	if self.Kind == NodeDir && !strings.HasSuffix(href, "/") {
+		href += "/"
+	}
+	var out strings.Builder
+	out.WriteString(`<D:multistatus xmlns:D="DAV:" xmlns:V="` + davLiveNS + `" xmlns:S="` + davPropSVNNS +
+		`" xmlns:C="` + davPropNS + `">` + "\n")
+	if err = d.propResponse(&out, href, self, rev, names, allProps); err != nil {
+		return err
+	}
+	if self.Kind == NodeDir && d.r.Header.Get("Depth") != "0" {
+		entries, err := d.entries(d.target.path, rev)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			entryHref := href + entry.Path
+			entry.Path = d.join(entry.Path)
+			if entry.Kind == NodeDir {
+				entryHref += "/"
+			}
+			if err = d.propResponse(&out, entryHref, entry, rev, names, allProps); err != nil {
+				return err
+			}
+		}
+	}
+	out.WriteString(`</D:multistatus>` + "\n")
+	d.writeXML(http.StatusMultiStatus, out.String())
+	return nil
+}
+
+// propResponse writes the "response" element of a node in a PROPFIND
+// response, with the properties in names, or all of them if allProps is true.
+func (d *davRequest) propResponse(out *strings.Builder, href string, node Dirent, rev uint, names []xml.Name, allProps bool) error {
+	var props Props
+	loadProps := func() error {
+		if props != nil {
+			return nil
+		}
+		var err error
+		props, err = d.props(node.Path, rev, node.Kind)
+		if props == nil {
+			props = Props{}
+		}
+		return err
+	}
+	if allProps {
+		names = davAllProps
+		if node.HasProps || d.s.Stat == nil {
+			if err := loadProps(); err != nil {
+				return err
+			}
+			for _, name := range props.Names() {
+				names = append(names, davPropXMLName(name))
+			}
+		}
+	}
+
+	var found, missing strings.Builder
+	for _, name := range names {
+		value, ok, err := d.liveProp(name, node, rev, loadProps, &props)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintf(&missing, "<%s xmlns=\"%s\"/>\n", name.Local, xmlEscape(name.Space))
+			continue
+		}
+		found.WriteString(value)
+	}
+	fmt.Fprintf(out, "<D:response>\n<D:href>%s</D:href>\n", xmlEscape((&url.URL{Path: href}).EscapedPath()))
+	if found.Len() > 0 {
+		fmt.Fprintf(out, "<D:propstat>\n<D:prop>\n%s</D:prop>\n<D:status>HTTP/1.1 200 OK</D:status>\n</D:propstat>\n", found.String())
+	}
+	if missing.Len() > 0 {
+		fmt.Fprintf(out, "<D:propstat>\n<D:prop>\n%s</D:prop>\n<D:status>HTTP/1.1 404 Not Found</D:status>\n</D:propstat>\n", missing.String())
+	}
+	out.WriteString("</D:response>\n")
+	return nil
+}
+
+// liveProp returns the XML representation of a property of a node,
+// and whether the node has it.  loadProps fills in props, if needed.
+func (d *davRequest) liveProp(name xml.Name, node Dirent, rev uint, loadProps func() error, props *Props) (string, bool, error) {
+	elem := func(prefix string, value string) (string, bool, error) {
+		return fmt.Sprintf("<%s:%s>%s</%[1]s:%[2]s>\n", prefix, name.Local, value), true, nil
+	}
+	href := func(p string) string {
+		return "<D:href>" + xmlEscape((&url.URL{Path: p}).EscapedPath()) + "</D:href>"
+	}
+	isFile := node.Kind == NodeFile
+	if prop, ok := davPropName(name); ok {
+		if err := loadProps(); err != nil {
+			return "", false, err
+		}
+		value, ok := (*props)[prop]
+		if !ok {
+			return "", false, nil
+		}
+		prefix := "S"
+		if name.Space == davPropNS {
+			prefix = "C"
+		}
+		attr, text := xmlValue("V:encoding", value)
+		return fmt.Sprintf("<%s:%s%s>%s</%[1]s:%[2]s>\n", prefix, name.Local, attr, text), true, nil
+	}
+	switch name {
+	case davResourceType:
+		if isFile {
+			return "<D:resourcetype/>\n", true, nil
+		}
+		return elem("D", "<D:collection/>")
+	case davContentLength:
+		if isFile && d.s.Stat != nil {
+			return elem("D", strconv.FormatUint(node.Size, 10))
+		}
+	case xml.Name{Space: davNS, Local: "getcontenttype"}:
+		if !isFile {
+			return elem("D", "text/html; charset=UTF-8")
+		}
+		if err := loadProps(); err != nil {
+			return "", false, err
+		}
+		return elem("D", xmlEscape(davContentType(*props)))
+	case davVersionName:
+		if node.CreatedRev > 0 || d.s.Stat != nil {
+			return elem("D", strconv.FormatUint(uint64(node.CreatedRev), 10))
+		}
+	case davCreationDate:
+		if node.CreatedDate != "" {
+			return elem("D", xmlEscape(node.CreatedDate))
+		}
+	case xml.Name{Space: davNS, Local: "getlastmodified"}:
+		if t, err := time.Parse(time.RFC3339Nano, node.CreatedDate); err == nil {
+			return elem("D", t.UTC().Format(http.TimeFormat))
+		}
+	case davCreator:
+		if node.LastAuthor != "" {
+			return elem("D", xmlEscape(node.LastAuthor))
+		}
+	case xml.Name{Space: davNS, Local: "getetag"}:
+		if node.CreatedRev > 0 {
+			return elem("D", xmlEscape(fmt.Sprintf(`W/"%d//%s"`, node.CreatedRev, node.Path)))
+		}
+	case xml.Name{Space: davNS, Local: "checked-in"}:
+		return elem("D", href(fmt.Sprintf("%s/!svn/ver/%d/%s", d.root, rev, node.Path)))
+	case xml.Name{Space: davNS, Local: "version-controlled-configuration"}:
+		return elem("D", href(d.root+"/!svn/vcc/default"))
+	case xml.Name{Space: davLiveNS, Local: "baseline-relative-path"}:
+		return elem("V", xmlEscape(node.Path))
+	case xml.Name{Space: davLiveNS, Local: "repository-uuid"}:
+		return elem("V", xmlEscape(d.s.info.UUID))
+	case davDeadPropCount:
+		if d.s.Stat != nil && !node.HasProps {
+			return elem("V", "0")
+		}
+		if err := loadProps(); err != nil {
+			return "", false, err
+		}
+		return elem("V", strconv.Itoa(len(*props)))
+	case davMD5Checksum:
+		if isFile {
+			sum, err := d.checksum(node.Path, rev)
+			if err != nil {
+				return "", false, err
+			}
+			return elem("V", sum)
+		}
+	case xml.Name{Space: davNS, Local: "lockdiscovery"}:
+		if d.s.GetLock == nil || d.target.rev != nil {
+			return "<D:lockdiscovery/>\n", true, nil
+		}
+		lock, err := d.s.GetLock(node.Path)
+		if err != nil {
+			return "", false, err
+		}
+		if lock == nil {
+			return "<D:lockdiscovery/>\n", true, nil
+		}
+		return elem("D", "<D:activelock><D:locktype><D:write/></D:locktype><D:lockscope><D:exclusive/></D:lockscope>"+
+			"<D:depth>0</D:depth><D:owner>"+xmlEscape(lock.Comment)+"</D:owner><D:timeout>Infinite</D:timeout>"+
+			"<D:locktoken><D:href>"+xmlEscape(lock.Token)+"</D:href></D:locktoken></D:activelock>")
+	}
+	return "", false, nil
+}
+
+// davContentType returns the MIME type of a file with some properties.
+func davContentType(props Props) string {
+	if mime := props.MimeType(); mime != "" {
+		return mime
+	}
+	return "text/plain"
+}
+
+// get answers a GET or HEAD request, with the contents of a file
+// or a listing of the entries of a directory.
+func (d *davRequest) get() error {
+	if d.target.me {
+		return Error{
+			AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+			Message: "The resource has no contents",
+		}
+	}
+	rev, err := d.rev(d.target.rev)
+	if err != nil {
+		return err
+	}
+	node, err := d.node(d.target.path, rev)
+	if err != nil {
+		return err
+	}
+	if node.Kind == NodeDir {
+		return d.listing(node, rev)
+	}
+	if d.s.GetFile == nil && d.s.GetFileReader == nil {
+		return unimplemented("get-file")
+	}
+	file, r, err := d.s.getFile(d.target.path, &rev, true, true)
+	if err != nil {
+		return err
+	}
+	defer closeReader(r)
+	h := d.w.Header()
+	h.Set("Content-Type", davContentType(file.Props))
+	if file.Size > 0 || d.s.GetFileReader == nil {
+		h.Set("Content-Length", strconv.FormatUint(file.Size, 10))
+	}
+	if node.CreatedRev > 0 {
+		h.Set("ETag", fmt.Sprintf(`"%d//%s"`, node.CreatedRev, node.Path))
+	}
+	if t, err := time.Parse(time.RFC3339Nano, node.CreatedDate); err == nil {
+		h.Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+	d.w.WriteHeader(http.StatusOK)
+	if d.r.Method != "HEAD" {
+		io.Copy(d.w, r)
+	}
+	return nil
+}
+
+// listing sends an HTML page with the entries of a directory,
+// for browsers.
+func (d *davRequest) listing(node Dirent, rev uint) error {
+	if !strings.HasSuffix(d.r.URL.Path, "/") {
+		http.Redirect(d.w, d.r, (&url.URL{Path: d.r.URL.Path + "/"}).EscapedPath(), http.StatusMovedPermanently)
+		return nil
+	}
+	entries, err := d.entries(node.Path, rev)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b Dirent) int { return strings.Compare(a.Path, b.Path) })
+	title := html.EscapeString(fmt.Sprintf("Revision %d: /%s", rev, node.Path))
+	var out strings.Builder
+	fmt.Fprintf(&out, "<html><head><title>%s</title></head>\n<body>\n<h2>%s</h2>\n<ul>\n", title, title)
+	if node.Path != "" {
+		out.WriteString(`  <li><a href="../">..</a></li>` + "\n")
+	}
+	for _, entry := range entries {
+		name := entry.Path
+		if entry.Kind == NodeDir {
+			name += "/"
+		}
+		fmt.Fprintf(&out, "  <li><a href=\"%s\">%s</a></li>\n",
+			html.EscapeString((&url.URL{Path: name}).EscapedPath()), html.EscapeString(name))
+	}
+	out.WriteString("</ul>\n</body></html>\n")
+	d.w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	if d.r.Method != "HEAD" {
+		io.WriteString(d.w, out.String())
+	}
+	return nil
+}
+
+// report answers a REPORT request, according to the element
+// at the root of its body.
+func (d *davRequest) report() error {
+	body, err := io.ReadAll(d.r.Body)
+	if err != nil {
+		return err
+	}
+	var root struct {
+		XMLName xml.Name
+	}
+	if err = xml.Unmarshal(body, &root); err != nil {
+		return Error{
+			AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+			Message: fmt.Sprintf("Malformed REPORT request: %v", err),
+		}
+	}
+	reports := map[string]func([]byte) (string, error){
+		"update-report":          d.updateReport,
+		"log-report":             d.logReport,
+		"get-locations":          d.locationsReport,
+		"get-location-segments":  d.segmentsReport,
+		"get-deleted-rev-report": d.deletedRevReport,
+		"file-revs-report":       d.fileRevsReport,
+		"mergeinfo-report":       d.mergeinfoReport,
+		"inherited-props-report": d.ipropsReport,
+		"get-locks-report":       d.locksReport,
+	}
+	fn, ok := reports[root.XMLName.Local]
+	if root.XMLName.Space != davSVNNS || !ok {
+		return Error{
+			AprErr:  200007, // SVN_ERR_UNSUPPORTED_FEATURE
+			Message: fmt.Sprintf("The requested report is unknown: '%s'", root.XMLName.Local),
+		}
+	}
+	out, err := fn(body)
+	if err != nil {
+		return err
+	}
+	d.writeXML(http.StatusOK, out)
+	return nil
+}
+
+// unmarshalReport decodes the body of a REPORT request.
+func unmarshalReport(body []byte, v any) error {
+	if err := xml.Unmarshal(body, v); err != nil {
+		return Error{
+			AprErr:  175009, // SVN_ERR_RA_DAV_MALFORMED_DATA
+			Message: fmt.Sprintf("Malformed REPORT request: %v", err),
+		}
+	}
+	return nil
+}
+
+// davReportHeader is the start of the root element of the reports.
+const davReportHeader = ` xmlns:S="svn:" xmlns:D="DAV:">` + "\n"
+
+// davChangeElements maps the actions of the changed paths in a log entry
+// to the elements used in a "log-report" (see davChangeModes).
+var davChangeElements = map[string]string{
+	"A": "added-path",
+	"D": "deleted-path",
+	"R": "replaced-path",
+	"M": "modified-path",
+}
+
+// logReport answers a "log-report", using the Log function.
+func (d *davRequest) logReport(body []byte) (string, error) {
+	var req struct {
+		Start   *uint     `xml:"svn: start-revision"`
+		End     *uint     `xml:"svn: end-revision"`
+		Limit   int       `xml:"svn: limit"`
+		Changed *struct{} `xml:"svn: discover-changed-paths"`
+		Paths   []string  `xml:"svn: path"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.Log == nil {
+		return "", unimplemented("log")
+	}
+	start, err := d.rev(req.Start)
+	if err != nil {
+		return "", err
+	}
+	end, err := d.rev(req.End)
+	if err != nil {
+		return "", err
+	}
+	if len(req.Paths) == 0 {
+		req.Paths = []string{""}
+	}
+	paths := make([]string, len(req.Paths))
+	for i, p := range req.Paths {
+		paths[i] = d.join(p)
+	}
+	entries, err := d.s.Log(paths, start, end, req.Changed != nil)
+	if err != nil {
+		return "", err
+	}
+	if req.Limit > 0 && len(entries) > req.Limit {
+		entries = entries[:req.Limit]
+	}
+	var out strings.Builder
+	out.WriteString(`<S:log-report` + davReportHeader)
+	for _, e := range entries {
+		fmt.Fprintf(&out, "<S:log-item>\n<D:version-name>%d</D:version-name>\n", e.Rev)
+		if e.Author != "" {
+			attr, value := xmlValue("encoding", e.Author)
+			fmt.Fprintf(&out, "<D:creator-displayname%s>%s</D:creator-displayname>\n", attr, value)
+		}
+		if e.Date != "" {
+			fmt.Fprintf(&out, "<S:date>%s</S:date>\n", xmlEscape(e.Date))
+		}
+		attr, value := xmlValue("encoding", e.Message)
+		fmt.Fprintf(&out, "<D:comment%s>%s</D:comment>\n", attr, value)
+		for _, c := range e.Changed {
+			if elem, ok := davChangeElements[c.Mode]; ok {
+				fmt.Fprintf(&out, "<S:%s>%s</S:%[1]s>\n", elem, xmlEscape(c.Path))
+			}
+		}
+		out.WriteString("</S:log-item>\n")
+	}
+	out.WriteString("</S:log-report>\n")
+	return out.String(), nil
+}
+
+// locationsReport answers a "get-locations" report,
+// using the GetLocations function.
+func (d *davRequest) locationsReport(body []byte) (string, error) {
+	var req struct {
+		Path string `xml:"svn: path"`
+		Peg  uint   `xml:"svn: peg-revision"`
+		Revs []uint `xml:"svn: location-revision"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.GetLocations == nil {
+		return "", unimplemented("get-locations")
+	}
+	locations, err := d.s.GetLocations(d.join(req.Path), req.Peg, req.Revs)
+	if err != nil {
+		return "", err
+	}
+	revs := make([]uint, 0, len(locations))
+	for rev := range locations {
+		revs = append(revs, rev)
+	}
+	slices.Sort(revs)
+	var out strings.Builder
+	out.WriteString(`<S:get-locations-report` + davReportHeader)
+	for _, rev := range revs {
+		fmt.Fprintf(&out, "<S:location rev=\"%d\" path=\"%s\"/>\n", rev, xmlEscape(locations[rev]))
+	}
+	out.WriteString("</S:get-locations-report>\n")
+	return out.String(), nil
+}
+
+// segmentsReport answers a "get-location-segments" report,
+// using the GetLocationSegments function.
+func (d *davRequest) segmentsReport(body []byte) (string, error) {
+	var req struct {
+		Path  string `xml:"svn: path"`
+		Peg   *uint  `xml:"svn: peg-revision"`
+		Start *uint  `xml:"svn: start-revision"`
+		End   *uint  `xml:"svn: end-revision"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.GetLocationSegments == nil {
+		return "", unimplemented("get-location-segments")
+	}
+	segments, err := d.s.GetLocationSegments(d.join(req.Path), req.Peg, req.Start, req.End)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.WriteString(`<S:get-location-segments-report` + davReportHeader)
+	for _, seg := range segments {
+		out.WriteString("<S:location-segment")
+		if seg.Path != "" {
+			fmt.Fprintf(&out, ` path="/%s"`, xmlEscape(strings.TrimPrefix(seg.Path, "/")))
+		}
+		fmt.Fprintf(&out, " range-start=\"%d\" range-end=\"%d\"/>\n", seg.RangeStart, seg.RangeEnd)
+	}
+	out.WriteString("</S:get-location-segments-report>\n")
+	return out.String(), nil
+}
+
+// deletedRevReport answers a "get-deleted-rev-report",
+// using the GetDeletedRev function.
+func (d *davRequest) deletedRevReport(body []byte) (string, error) {
+	var req struct {
+		Path string `xml:"svn: path"`
+		Peg  uint   `xml:"svn: peg-revision"`
+		End  uint   `xml:"svn: end-revision"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.GetDeletedRev == nil {
+		return "", unimplemented("get-deleted-rev")
+	}
+	rev, err := d.s.GetDeletedRev(d.join(req.Path), req.Peg, req.End)
+	if err != nil {
+		return "", err
+	}
+	deleted := InvalidRevision
+	if rev != nil {
+		deleted = int(*rev)
+	}
+	return fmt.Sprintf("<S:get-deleted-rev-report%s<D:version-name>%d</D:version-name>\n</S:get-deleted-rev-report>\n",
+		davReportHeader, deleted), nil
+}
+
+// fileRevsReport answers a "file-revs-report", using the GetFileRevs
+// function.  The contents of every revision are sent as a delta against
+// the previous one.
+func (d *davRequest) fileRevsReport(body []byte) (string, error) {
+	var req struct {
+		Start         *uint     `xml:"svn: start-revision"`
+		End           *uint     `xml:"svn: end-revision"`
+		IncludeMerged *struct{} `xml:"svn: include-merged-revisions"`
+		Path          string    `xml:"svn: path"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.GetFileRevs == nil {
+		return "", unimplemented("get-file-revs")
+	}
+	revs, err := d.s.GetFileRevs(d.join(req.Path), req.Start, req.End, req.IncludeMerged != nil)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.WriteString(`<S:file-revs-report` + davReportHeader)
+	var prev []byte
+	for i, rev := range revs {
+		fmt.Fprintf(&out, "<S:file-rev path=\"%s\" rev=\"%d\">\n", xmlEscape(rev.Path), rev.Rev)
+		for _, name := range rev.RevProps.Names() {
+			attr, value := xmlValue("encoding", rev.RevProps[name])
+			fmt.Fprintf(&out, "<S:rev-prop name=\"%s\"%s>%s</S:rev-prop>\n", xmlEscape(name), attr, value)
+		}
+		for _, name := range sortedKeys(rev.PropDelta) {
+			if rev.PropDelta[name] == nil {
+				fmt.Fprintf(&out, "<S:remove-prop name=\"%s\"/>\n", xmlEscape(name))
+				continue
+			}
+			attr, value := xmlValue("encoding", *rev.PropDelta[name])
+			fmt.Fprintf(&out, "<S:set-prop name=\"%s\"%s>%s</S:set-prop>\n", xmlEscape(name), attr, value)
+		}
+		if rev.MergedRevision {
+			out.WriteString("<S:merged-revision/>\n")
+		}
+		if i == 0 || !bytes.Equal(prev, rev.Contents) {
+			delta := svndiff.Diff(prev, rev.Contents, 0)
+			fmt.Fprintf(&out, "<S:txdelta>%s</S:txdelta>\n", base64.StdEncoding.EncodeToString(delta))
+			prev = rev.Contents
+		}
+		out.WriteString("</S:file-rev>\n")
+	}
+	out.WriteString("</S:file-revs-report>\n")
+	return out.String(), nil
+}
+
+// mergeinfoReport answers a "mergeinfo-report", using the GetMergeinfo
+// function.  The paths are relative to the target of the request.
+func (d *davRequest) mergeinfoReport(body []byte) (string, error) {
+	var req struct {
+		Rev         string   `xml:"svn: revision"`
+		Inherit     string   `xml:"svn: inherit"`
+		Descendants string   `xml:"svn: include-descendants"`
+		Paths       []string `xml:"svn: path"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.GetMergeinfo == nil {
+		return "", unimplemented("get-mergeinfo")
+	}
+	var rev *uint
+	if n, err := strconv.ParseUint(strings.TrimSpace(req.Rev), 10, 0); err == nil {
+		r := uint(n)
+		rev = &r
+	}
+	if req.Inherit == "" {
+		req.Inherit = "explicit"
+	}
+	paths := make([]string, len(req.Paths))
+	for i, p := range req.Paths {
+		paths[i] = d.join(p)
+	}
+	catalog, err := d.s.GetMergeinfo(paths, rev, req.Inherit, req.Descendants == "yes")
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.WriteString(`<S:mergeinfo-report` + davReportHeader)
+	for _, p := range sortedKeys(catalog) {
+		rel := strings.TrimPrefix(p, "/")
+		if d.target.path != "" {
+			rel = strings.TrimPrefix(strings.TrimPrefix(rel, d.target.path), "/")
+		}
+		attr, value := xmlValue("encoding", catalog[p].String())
+		fmt.Fprintf(&out, "<S:mergeinfo-item>\n<S:mergeinfo-path>%s</S:mergeinfo-path>\n"+
+			"<S:mergeinfo-info%s>%s</S:mergeinfo-info>\n</S:mergeinfo-item>\n", xmlEscape(rel), attr, value)
+	}
+	out.WriteString("</S:mergeinfo-report>\n")
+	return out.String(), nil
+}
+
+// ipropsReport answers an "inherited-props-report",
+// using the GetIProps function.
+func (d *davRequest) ipropsReport(body []byte) (string, error) {
+	var req struct {
+		Rev  *uint  `xml:"svn: revision"`
+		Path string `xml:"svn: path"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.GetIProps == nil {
+		return "", unimplemented("get-iprops")
+	}
+	if req.Rev == nil {
+		req.Rev = d.target.rev
+	}
+	iprops, err := d.s.GetIProps(d.join(req.Path), req.Rev)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.WriteString(`<S:inherited-props-report` + davReportHeader)
+	for _, ip := range iprops {
+		for _, name := range ip.Props.Names() {
+			attr, value := xmlValue("encoding", ip.Props[name])
+			fmt.Fprintf(&out, "<S:iprop-item>\n<S:iprop-path>%s</S:iprop-path>\n<S:iprop-propname>%s</S:iprop-propname>\n"+
+				"<S:iprop-propval%s>%s</S:iprop-propval>\n</S:iprop-item>\n", xmlEscape(ip.Path), xmlEscape(name), attr, value)
+		}
+	}
+	out.WriteString("</S:inherited-props-report>\n")
+	return out.String(), nil
+}
+
+// locksReport answers a "get-locks-report", using the GetLocks function.
+func (d *davRequest) locksReport(body []byte) (string, error) {
+	var req struct {
+		Depth string `xml:"depth,attr"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.GetLocks == nil {
+		return "", unimplemented("get-locks")
+	}
+	if req.Depth == "" {
+		req.Depth = "infinity"
+	}
+	locks, err := d.s.GetLocks(d.target.path, req.Depth)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.WriteString(`<S:get-locks-report` + davReportHeader)
+	for _, lock := range locks {
+		fmt.Fprintf(&out, "<S:lock>\n<S:path>%s</S:path>\n<S:token>%s</S:token>\n", xmlEscape(lock.Path), xmlEscape(lock.Token))
+		attr, value := xmlValue("encoding", lock.Owner)
+		fmt.Fprintf(&out, "<S:owner%s>%s</S:owner>\n", attr, value)
+		if lock.Comment != "" {
+			attr, value = xmlValue("encoding", lock.Comment)
+			fmt.Fprintf(&out, "<S:comment%s>%s</S:comment>\n", attr, value)
+		}
+		fmt.Fprintf(&out, "<S:creationdate>%s</S:creationdate>\n", xmlEscape(lock.Created))
+		if lock.Expires != "" {
+			fmt.Fprintf(&out, "<S:expirationdate>%s</S:expirationdate>\n", xmlEscape(lock.Expires))
+		}
+		out.WriteString("</S:lock>\n")
+	}
+	out.WriteString("</S:get-locks-report>\n")
+	return out.String(), nil
+}
+
+// updateReport answers an "update-report", using the Update function.
+// The whole edit is sent in the response ("send-all" mode), including
+// the contents of the files.  The report is an "update", or a "switch"
+// if it has a dst-path (which is also used for "diff").
+func (d *davRequest) updateReport(body []byte) (string, error) {
+	var req struct {
+		SrcPath        string  `xml:"svn: src-path"`
+		DstPath        string  `xml:"svn: dst-path"`
+		Target         string  `xml:"svn: update-target"`
+		Rev            *uint   `xml:"svn: target-revision"`
+		Depth          string  `xml:"svn: depth"`
+		Recursive      *string `xml:"svn: recursive"`
+		IgnoreAncestry string  `xml:"svn: ignore-ancestry"`
+		SendCopyFrom   string  `xml:"svn: send-copyfrom-args"`
+		TextDeltas     string  `xml:"svn: text-deltas"`
+		// Paths are the "entry" and "missing" elements, in order.
+		Paths []struct {
+			XMLName    xml.Name
+			Rev        uint   `xml:"rev,attr"`
+			StartEmpty string `xml:"start-empty,attr"`
+			Depth      string `xml:"depth,attr"`
+			LinkPath   string `xml:"linkpath,attr"`
+			LockToken  string `xml:"lock-token,attr"`
+			Path       string `xml:",chardata"`
+		} `xml:",any"`
+	}
+	if err := unmarshalReport(body, &req); err != nil {
+		return "", err
+	}
+	if d.s.Update == nil {
+		return "", unimplemented("update")
+	}
+	report := &Report{
+		Command:        "update",
+		Rev:            req.Rev,
+		Target:         req.Target,
+		Depth:          req.Depth,
+		SendCopyFrom:   req.SendCopyFrom == "yes",
+		IgnoreAncestry: req.IgnoreAncestry == "yes",
+		TextDeltas:     req.TextDeltas != "no",
+	}
+	if report.Depth == "" {
+		report.Depth = "files"
+		if req.Recursive == nil || *req.Recursive != "no" {
+			report.Depth = "infinity"
+		}
+	}
+	if req.DstPath != "" {
+		p, ok := relativeURL(d.s.info.URL, req.DstPath)
+		if !ok {
+			return "", Error{
+				AprErr:  170000, // SVN_ERR_RA_ILLEGAL_URL
+				Message: fmt.Sprintf("'%s' is not the same repository as '%s'", req.DstPath, d.s.info.URL),
+			}
+		}
+		report.Command, report.DstPath = "switch", p
+	}
+	for _, entry := range req.Paths {
+		if entry.XMLName.Space != "svn:" {
+			continue
+		}
+		p := strings.Trim(entry.Path, "/")
+		switch entry.XMLName.Local {
+		case "entry":
+			if entry.Depth == "" {
+				entry.Depth = "infinity"
+			}
+			report.Paths = append(report.Paths, ReportedPath{
+				Path:       p,
+				Rev:        entry.Rev,
+				StartEmpty: entry.StartEmpty == "true",
+				LockToken:  entry.LockToken,
+				Depth:      entry.Depth,
+				LinkPath:   strings.Trim(entry.LinkPath, "/"),
+			})
+		case "missing":
+			report.Paths = append(report.Paths, ReportedPath{Path: p, Deleted: true})
+		}
+	}
+	if src, err := url.Parse(req.SrcPath); err == nil && d.s.Reparent != nil {
+		rel := strings.Trim(strings.TrimPrefix(src.Path, d.root), "/")
+		if err = d.s.Reparent(d.url(rel)); err != nil {
+			return "", err
+		}
+		d.s.session.URL = d.url(rel)
+	}
+
+	var out strings.Builder
+	e := &davUpdateEditor{out: &out, closing: map[string]string{}}
+	out.WriteString(`<S:update-report xmlns:S="svn:" xmlns:V="` + davLiveNS + `" xmlns:D="DAV:" send-all="true" inline-props="true">` + "\n")
+	if err := d.s.Update(report, e); err != nil {
+		return "", err
+	}
+	// as in Serve, the edit is closed after Update.
+	e.CloseEdit()
+	return out.String(), nil
+}
+
+// A davUpdateEditor is an Editor which writes the body
+// of an "update-report" response.
+type davUpdateEditor struct {
+	out *strings.Builder
+	// closing has the end tags of the open directories and files,
+	// indexed by token.
+	closing map[string]string
+}
+
+// open writes the start tag of a directory or file.
+func (e *davUpdateEditor) open(elem string, token string, path string, rev *uint, copyPath string, copyRev uint) {
+	fmt.Fprintf(e.out, "<S:%s", elem)
+	if path != "" {
+		fmt.Fprintf(e.out, ` name="%s"`, xmlEscape(pathBase(path)))
+	}
+	if rev != nil {
+		fmt.Fprintf(e.out, ` rev="%d"`, *rev)
+	}
+	if copyPath != "" {
+		fmt.Fprintf(e.out, ` copyfrom-path="%s" copyfrom-rev="%d"`, xmlEscape(copyPath), copyRev)
+	}
+	e.out.WriteString(">\n")
+	e.closing[token] = "</S:" + elem + ">\n"
+}
+
+// close writes the end tag of a directory or file.
+func (e *davUpdateEditor) close(token string) error {
+	end, ok := e.closing[token]
+	if !ok {
+		return Error{AprErr: 210004, Message: fmt.Sprintf("Invalid token '%s'", token)}
+	}
+	delete(e.closing, token)
+	e.out.WriteString(end)
+	return nil
+}
+
+// pathBase returns the last element of a path.
+func pathBase(p string) string {
+	return path.Base("/" + p)
+}
+
+func (e *davUpdateEditor) TargetRev(rev uint) error {
+	fmt.Fprintf(e.out, "<S:target-revision rev=\"%d\"/>\n", rev)
+	return nil
+}
+
+func (e *davUpdateEditor) OpenRoot(rev *uint, rootToken string) error {
+	e.open("open-directory", rootToken, "", rev, "", 0)
+	return nil
+}
+
+func (e *davUpdateEditor) DeleteEntry(path string, rev *uint, dirToken string) error {
+	fmt.Fprintf(e.out, "<S:delete-entry name=\"%s\"", xmlEscape(pathBase(path)))
+	if rev != nil {
+		fmt.Fprintf(e.out, ` rev="%d"`, *rev)
+	}
+	e.out.WriteString("/>\n")
+	return nil
+}
+
+func (e *davUpdateEditor) AddDir(path string, parentToken string, childToken string, copyPath string, copyRev uint) error {
+	e.open("add-directory", childToken, path, nil, copyPath, copyRev)
+	return nil
+}
+
+func (e *davUpdateEditor) OpenDir(path string, parentToken string, childToken string, rev *uint) error {
+	e.open("open-directory", childToken, path, rev, "", 0)
+	return nil
+}
+
+func (e *davUpdateEditor) ChangeDirProp(dirToken string, name string, value *string) error {
+	if value == nil {
+		fmt.Fprintf(e.out, "<S:remove-prop name=\"%s\"/>\n", xmlEscape(name))
+		return nil
+	}
+	attr, text := xmlValue("encoding", *value)
+	fmt.Fprintf(e.out, "<S:set-prop name=\"%s\"%s>%s</S:set-prop>\n", xmlEscape(name), attr, text)
+	return nil
+}
+
+func (e *davUpdateEditor) CloseDir(dirToken string) error {
+	return e.close(dirToken)
+}
+
+func (e *davUpdateEditor) AbsentDir(path string, parentToken string) error {
+	fmt.Fprintf(e.out, "<S:absent-directory name=\"%s\"/>\n", xmlEscape(pathBase(path)))
+	return nil
+}
+
+func (e *davUpdateEditor) AddFile(path string, dirToken string, fileToken string, copyPath string, copyRev uint) error {
+	e.open("add-file", fileToken, path, nil, copyPath, copyRev)
+	return nil
+}
+
+func (e *davUpdateEditor) OpenFile(path string, dirToken string, fileToken string, rev *uint) error {
+	e.open("open-file", fileToken, path, rev, "", 0)
+	return nil
+}
+
+// davTxDelta writes a text delta in base64, inside a "txdelta" element.
+type davTxDelta struct {
+	out *strings.Builder
+	enc io.WriteCloser
+}
+
+func (t davTxDelta) Write(p []byte) (int, error) {
+	return t.enc.Write(p)
+}
+
+func (t davTxDelta) Close() error {
+	err := t.enc.Close()
+	t.out.WriteString("</S:txdelta>\n")
+	return err
+}
+
+func (e *davUpdateEditor) ApplyTextDelta(fileToken string, baseChecksum string) (io.WriteCloser, error) {
+	e.out.WriteString("<S:txdelta")
+	if baseChecksum != "" {
+		fmt.Fprintf(e.out, ` base-checksum="%s"`, xmlEscape(baseChecksum))
+	}
+	e.out.WriteString(">")
+	return davTxDelta{out: e.out, enc: base64.NewEncoder(base64.StdEncoding, e.out)}, nil
+}
+
+func (e *davUpdateEditor) ChangeFileProp(fileToken string, name string, value *string) error {
+	return e.ChangeDirProp(fileToken, name, value)
+}
+
+func (e *davUpdateEditor) CloseFile(fileToken string, textChecksum string) error {
+	if textChecksum != "" {
+		fmt.Fprintf(e.out, "<S:prop><V:md5-checksum>%s</V:md5-checksum></S:prop>\n", xmlEscape(textChecksum))
+	}
+	return e.close(fileToken)
+}
+
+func (e *davUpdateEditor) AbsentFile(path string, parentToken string) error {
+	fmt.Fprintf(e.out, "<S:absent-file name=\"%s\"/>\n", xmlEscape(pathBase(path)))
+	return nil
+}
+
+func (e *davUpdateEditor) CloseEdit() error {
+	e.out.WriteString("</S:update-report>\n")
+	return nil
+}
+
+func (e *davUpdateEditor) AbortEdit() error {
+	return Error{
+		AprErr:  200015, // SVN_ERR_CANCELLED
+		Message: "The edit was aborted",
+	}
+}
@@ -0,0 +1,101 @@
+package svn
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cespedes/svn/svndiff"
+)
+
+func TestHTTPHandlerReports(t *testing.T) {
+	contents := []string{"one\n", "one\ntwo\n"}
+	var report *Report
+	s := &Server{
+		GetLatestRev: func() (uint, error) { return 2, nil },
+		Stat: func(path string, rev *uint) (Stat, error) {
+			if path == "" {
+				return Stat{Kind: NodeDir, CreatedRev: 2}, nil
+			}
+			return Stat{Kind: NodeFile, CreatedRev: 2, Size: 8}, nil
+		},
+		GetFileRevs: func(path string, start, end *uint, includeMerged bool) ([]FileRev, error) {
+			var revs []FileRev
+			for i, c := range contents {
+				revs = append(revs, FileRev{Path: "/" + path, Rev: uint(i + 1), RevProps: Props{PropRevAuthor: "alice"}, Contents: []byte(c)})
+			}
+			return revs, nil
+		},
+		Update: func(r *Report, e Editor) error {
+			report = r
+			rev := uint(1)
+			e.TargetRev(2)
+			e.OpenRoot(&rev, "d")
+			e.AddFile("a.txt", "d", "f", "", 0)
+			w, _ := e.ApplyTextDelta("f", "")
+			w.Write(svndiff.Diff(nil, []byte(contents[1]), 0))
+			w.Close()
+			e.CloseFile("f", "")
+			return e.CloseDir("d")
+		},
+	}
+	ts := httptest.NewServer(&HTTPHandler{NewServer: func(*http.Request) (*Server, error) { return s, nil }})
+	defer ts.Close()
+
+	sess, err := Open(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	var got []string
+	err = sess.GetFileRevs("a.txt", nil, nil, false, func(fr FileRev) error {
+		got = append(got, string(fr.Contents))
+		return nil
+	})
+	if err != nil || !slices.Equal(got, contents) {
+		t.Errorf("get-file-revs: %q, %v", got, err)
+	}
+
+	resp, err := http.Post(ts.URL+"/!svn/me", "text/xml", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %s", resp.Status)
+	}
+
+	// sent to the version-controlled configuration, as the clients without HTTPv2 do:
+	req, _ := http.NewRequest("REPORT", ts.URL+"/!svn/vcc/default", strings.NewReader(`<S:update-report xmlns:S="svn:" send-all="true">`+
+		`<S:src-path>`+ts.URL+`/</S:src-path><S:target-revision>2</S:target-revision><S:depth>infinity</S:depth>`+
+		`<S:entry rev="1" start-empty="true"></S:entry><S:missing>b.txt</S:missing>`+
+		`<S:entry rev="1" depth="empty">c</S:entry></S:update-report>`))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{
+		`<S:target-revision rev="2"/>`,
+		`<S:open-directory rev="1">`,
+		`<S:add-file name="a.txt">`,
+		`<S:txdelta>`,
+		`</S:add-file>`,
+		`</S:update-report>`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("update-report: missing %q in\n%s", want, body)
+		}
+	}
+	if want := []ReportedPath{
+		{Rev: 1, StartEmpty: true, Depth: "infinity"},
+		{Path: "b.txt", Deleted: true},
+		{Path: "c", Rev: 1, Depth: "empty"},
+	}; report == nil || report.Command != "update" || report.Depth != "infinity" || !slices.Equal(report.Paths, want) {
+		t.Errorf("update-report: got report %+v", report)
+	}
+}
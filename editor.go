@@ -0,0 +1,426 @@
+package svn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// An Editor receives a description of the changes between two trees,
+// as a sequence of calls.  It follows the "editor command set" of the
+// SVN protocol, used by the client to describe a commit and by the server
+// to describe an update.
+//
+// Directories and files are identified by tokens chosen by the driver
+// of the edit.  Paths are relative to the root of the edit.
+// Optional revisions are nil pointers, and optional strings
+// (checksums, copy sources) are empty.  A nil property value
+// means that the property is deleted.
+//
+// The contents of a file are sent as a svndiff delta against its previous
+// version: ApplyTextDelta returns a writer which receives the delta,
+// and it is closed once it has been sent completely.
+type Editor interface {
+	TargetRev(rev uint) error
+	OpenRoot(rev *uint, rootToken string) error
+	DeleteEntry(path string, rev *uint, dirToken string) error
+	AddDir(path string, parentToken string, childToken string, copyPath string, copyRev uint) error
+	OpenDir(path string, parentToken string, childToken string, rev *uint) error
+	ChangeDirProp(dirToken string, name string, value *string) error
+	CloseDir(dirToken string) error
+	AbsentDir(path string, parentToken string) error
+	AddFile(path string, dirToken string, fileToken string, copyPath string, copyRev uint) error
+	OpenFile(path string, dirToken string, fileToken string, rev *uint) error
+	ApplyTextDelta(fileToken string, baseChecksum string) (io.WriteCloser, error)
+	ChangeFileProp(fileToken string, name string, value *string) error
+	CloseFile(fileToken string, textChecksum string) error
+	AbsentFile(path string, parentToken string) error
+	CloseEdit() error
+	AbortEdit() error
+}
+
+// optString returns the representation of an optional string:
+// an empty list if s is empty, or a list with s otherwise.
+func optString(s string) []any {
+	if s == "" {
+		return []any{}
+	}
+	return []any{[]byte(s)}
+}
+
+// optValue returns the representation of an optional property value.
+func optValue(value *string) []any {
+	if value == nil {
+		return []any{}
+	}
+	return []any{[]byte(*value)}
+}
+
+// optCopy returns the representation of an optional copy source.
+func optCopy(copyPath string, copyRev uint) []any {
+	if copyPath == "" {
+		return []any{}
+	}
+	return []any{[]byte(copyPath), copyRev}
+}
+
+// editorSender is an Editor which sends every call
+// through a connection.
+type editorSender struct {
+	conn *conn
+}
+
+func (e editorSender) send(cmd string, params ...any) error {
+	if params == nil {
+		params = []any{}
+	}
+	return e.conn.Write([]any{cmd, params})
+}
+
+func (e editorSender) TargetRev(rev uint) error {
+	return e.send("target-rev", rev)
+}
+
+func (e editorSender) OpenRoot(rev *uint, rootToken string) error {
+	return e.send("open-root", []any{rev}, []byte(rootToken))
+}
+
+func (e editorSender) DeleteEntry(path string, rev *uint, dirToken string) error {
+	return e.send("delete-entry", []byte(path), []any{rev}, []byte(dirToken))
+}
+
+func (e editorSender) AddDir(path string, parentToken string, childToken string, copyPath string, copyRev uint) error {
+	return e.send("add-dir", []byte(path), []byte(parentToken), []byte(childToken), optCopy(copyPath, copyRev))
+}
+
+func (e editorSender) OpenDir(path string, parentToken string, childToken string, rev *uint) error {
+	return e.send("open-dir", []byte(path), []byte(parentToken), []byte(childToken), []any{rev})
+}
+
+func (e editorSender) ChangeDirProp(dirToken string, name string, value *string) error {
+	return e.send("change-dir-prop", []byte(dirToken), []byte(name), optValue(value))
+}
+
+func (e editorSender) CloseDir(dirToken string) error {
+	return e.send("close-dir", []byte(dirToken))
+}
+
+func (e editorSender) AbsentDir(path string, parentToken string) error {
+	return e.send("absent-dir", []byte(path), []byte(parentToken))
+}
+
+func (e editorSender) AddFile(path string, dirToken string, fileToken string, copyPath string, copyRev uint) error {
+	return e.send("add-file", []byte(path), []byte(dirToken), []byte(fileToken), optCopy(copyPath, copyRev))
+}
+
+func (e editorSender) OpenFile(path string, dirToken string, fileToken string, rev *uint) error {
+	return e.send("open-file", []byte(path), []byte(dirToken), []byte(fileToken), []any{rev})
+}
+
+func (e editorSender) ApplyTextDelta(fileToken string, baseChecksum string) (io.WriteCloser, error) {
+	err := e.send("apply-textdelta", []byte(fileToken), optString(baseChecksum))
+	if err != nil {
+		return nil, err
+	}
+	return textDeltaSender{conn: e.conn, token: fileToken}, nil
+}
+
+func (e editorSender) ChangeFileProp(fileToken string, name string, value *string) error {
+	return e.send("change-file-prop", []byte(fileToken), []byte(name), optValue(value))
+}
+
+func (e editorSender) CloseFile(fileToken string, textChecksum string) error {
+	return e.send("close-file", []byte(fileToken), optString(textChecksum))
+}
+
+func (e editorSender) AbsentFile(path string, parentToken string) error {
+	return e.send("absent-file", []byte(path), []byte(parentToken))
+}
+
+// CloseEdit sends "close-edit" and waits for its response.
+// If it is a failure, the receiver is waiting for an "abort-edit",
+// so it is sent before returning the error.
+func (e editorSender) CloseEdit() error {
+	if err := e.send("close-edit"); err != nil {
+		return err
+	}
+	var item Item
+	err := e.conn.ReadResponse(&item)
+	if _, ok := err.(Error); ok {
+		e.send("abort-edit")
+	}
+	return err
+}
+
+// AbortEdit sends "abort-edit" and waits for its response.
+// If the receiver had already failed, the response is that failure.
+func (e editorSender) AbortEdit() error {
+	if err := e.send("abort-edit"); err != nil {
+		return err
+	}
+	var item Item
+	return e.conn.ReadResponse(&item)
+}
+
+// textDeltaSender sends every Write as a "textdelta-chunk"
+// and "textdelta-end" on Close.
+type textDeltaSender struct {
+	conn  *conn
+	token string
+}
+
+func (t textDeltaSender) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	err := t.conn.Write([]any{"textdelta-chunk", []any{[]byte(t.token), p}})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t textDeltaSender) Close() error {
+	return t.conn.Write([]any{"textdelta-end", []any{[]byte(t.token)}})
+}
+
+// errEditAborted is returned by driveEditor when the edit
+// did not finish with a successful "close-edit".
+var errEditAborted = errors.New("edit aborted")
+
+// driveEditor reads editor commands from a connection and calls
+// the corresponding methods of e, until the edit is closed or aborted.
+//
+// The only commands with a response are "close-edit" and "abort-edit".
+// If any call fails, the error is sent to the other end, which is expected
+// to send "abort-edit"; every command until then is ignored.
+// driveEditor returns nil if the edit was closed successfully,
+// errEditAborted if it was aborted or failed (the other end has already
+// been notified), or any other error if the connection fails.
+func driveEditor(conn *conn, e Editor) error {
+	deltas := make(map[string]io.WriteCloser)
+	for {
+		var command struct {
+			Name   string
+			Params Item
+		}
+		if err := conn.Read(&command); err != nil {
+			return err
+		}
+		done, err := editorCommand(e, deltas, command.Name, command.Params)
+		if err != nil && command.Name == "abort-edit" {
+			if err := conn.WriteFailure(err); err != nil {
+				return err
+			}
+			return errEditAborted
+		}
+		if err != nil {
+			if command.Name != "close-edit" {
+				e.AbortEdit()
+			}
+			if err := conn.WriteFailure(err); err != nil {
+				return err
+			}
+			if err := drainEditor(conn); err != nil {
+				return err
+			}
+			return errEditAborted
+		}
+		if done {
+			if err := conn.WriteSuccess([]any{}); err != nil {
+				return err
+			}
+			if command.Name == "abort-edit" {
+				return errEditAborted
+			}
+			return nil
+		}
+	}
+}
+
+// drainEditor ignores all the editor commands until "abort-edit".
+func drainEditor(conn *conn) error {
+	for {
+		var command struct {
+			Name   string
+			Params Item
+		}
+		if err := conn.Read(&command); err != nil {
+			return err
+		}
+		if command.Name == "abort-edit" {
+			return nil
+		}
+	}
+}
+
+// editorCommand calls the method of e corresponding to an editor command.
+// It returns true if it was the last command of the edit.
+func editorCommand(e Editor, deltas map[string]io.WriteCloser, name string, params Item) (bool, error) {
+	var args struct {
+		A, B, C string
+		D       Item
+	}
+	neterr := Error{
+		AprErr:  210004,
+		Message: "Malformed network data",
+	}
+	var err error
+	switch name {
+	case "target-rev":
+		var rev uint
+		if Unmarshal(params, &rev) != nil {
+			return false, neterr
+		}
+		err = e.TargetRev(rev)
+	case "open-root":
+		var args struct {
+			Rev   *uint
+			Token string
+		}
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		err = e.OpenRoot(args.Rev, args.Token)
+	case "delete-entry":
+		var args struct {
+			Path  string
+			Rev   *uint
+			Token string
+		}
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		err = e.DeleteEntry(args.Path, args.Rev, args.Token)
+	case "add-dir", "add-file":
+		var copyFrom struct {
+			Path string
+			Rev  uint
+		}
+		if Unmarshal(params, &args) != nil || Unmarshal(args.D, &copyFrom) != nil {
+			return false, neterr
+		}
+		if name == "add-dir" {
+			err = e.AddDir(args.A, args.B, args.C, copyFrom.Path, copyFrom.Rev)
+		} else {
+			err = e.AddFile(args.A, args.B, args.C, copyFrom.Path, copyFrom.Rev)
+		}
+	case "open-dir", "open-file":
+		var rev *uint
+		if Unmarshal(params, &args) != nil || Unmarshal(args.D, &rev) != nil {
+			return false, neterr
+		}
+		if name == "open-dir" {
+			err = e.OpenDir(args.A, args.B, args.C, rev)
+		} else {
+			err = e.OpenFile(args.A, args.B, args.C, rev)
+		}
+	case "change-dir-prop", "change-file-prop":
+		var args struct {
+			Token string
+			Name  string
+			Value []string
+		}
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		var value *string
+		if len(args.Value) > 0 {
+			value = &args.Value[0]
+		}
+		if name == "change-dir-prop" {
+			err = e.ChangeDirProp(args.Token, args.Name, value)
+		} else {
+			err = e.ChangeFileProp(args.Token, args.Name, value)
+		}
+	case "close-dir":
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		err = e.CloseDir(args.A)
+	case "absent-dir", "absent-file":
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		if name == "absent-dir" {
+			err = e.AbsentDir(args.A, args.B)
+		} else {
+			err = e.AbsentFile(args.A, args.B)
+		}
+	case "apply-textdelta":
+		var args struct {
+			Token    string
+			Checksum []string
+		}
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		checksum := ""
+		if len(args.Checksum) > 0 {
+			checksum = args.Checksum[0]
+		}
+		var w io.WriteCloser
+		w, err = e.ApplyTextDelta(args.Token, checksum)
+		if err == nil {
+			deltas[args.Token] = w
+		}
+	case "textdelta-chunk":
+		var args struct {
+			Token string
+			Chunk []byte
+		}
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		w, ok := deltas[args.Token]
+		if !ok {
+			return false, Error{AprErr: 210004, Message: fmt.Sprintf("Invalid file token '%s'", args.Token)}
+		}
+		_, err = w.Write(args.Chunk)
+	case "textdelta-end":
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		w, ok := deltas[args.A]
+		if !ok {
+			return false, Error{AprErr: 210004, Message: fmt.Sprintf("Invalid file token '%s'", args.A)}
+		}
+		delete(deltas, args.A)
+		err = w.Close()
+	case "close-file":
+		var args struct {
+			Token    string
+			Checksum []string
+		}
+		if Unmarshal(params, &args) != nil {
+			return false, neterr
+		}
+		checksum := ""
+		if len(args.Checksum) > 0 {
+			checksum = args.Checksum[0]
+		}
+		err = e.CloseFile(args.Token, checksum)
+	case "close-edit":
+		return true, e.CloseEdit()
+	case "abort-edit":
+		return true, e.AbortEdit()
+	default:
+		return false, Error{
+			AprErr:  210001,
+			Message: fmt.Sprintf("Unknown editor command '%s'", name),
+		}
+	}
+	return false, err
+}
+
+// editorItems returns an Editor which appends every call to items,
+// as the command which would be sent through a connection
+// (for example, to compare them with the expected ones in a test).
+func editorItems(items *[]Item) Editor {
+	return editorSender{conn: &conn{
+		w: io.Discard,
+		trace: func(dir Direction, item Item) {
+			*items = append(*items, item)
+		},
+	}}
+}
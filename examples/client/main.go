@@ -36,10 +36,10 @@ func main() {
 	}
 	fmt.Printf("List: %+v\n", dirents)
 
-	props, content, err := c.GetFile("", nil, true, true)
+	file, err := c.GetFile("", nil, true, true, false)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("GetFile (proplist): %+v\n", props)
-	fmt.Printf("GetFile (content): %q\n", content)
+	fmt.Printf("GetFile (props): %+v\n", file.Props)
+	fmt.Printf("GetFile (content): %q\n", file.Contents)
 }
@@ -9,18 +9,18 @@ import (
 
 func main() {
 	var server svn.Server
-	lastRev := 1000
-	server.GetLatestRev = func() (int, error) {
+	lastRev := uint(1000)
+	server.GetLatestRev = func() (uint, error) {
 		return lastRev, nil
 	}
-	server.Stat = func(path string, rev *uint) (svn.Dirent, error) {
-		return svn.Dirent{
-			Kind:        "dir",
+	server.Stat = func(path string, rev *uint) (svn.Stat, error) {
+		return svn.Stat{
+			Kind:        svn.NodeDir,
 			CreatedDate: "2024-03-18T14:50:07.758412Z",
 		}, nil
 	}
-	server.CheckPath = func(path string, rev *uint) (string, error) {
-		return "dir", nil
+	server.CheckPath = func(path string, rev *uint) (svn.NodeKind, error) {
+		return svn.NodeDir, nil
 	}
 	err := server.Serve(os.Stdin, os.Stdout)
 	if err != nil {
@@ -0,0 +1,256 @@
+package svn
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS returns the tree of the session in revision rev as a file system,
+// which implements [fs.ReadDirFS], [fs.ReadFileFS] and [fs.StatFS] with the
+// "list", "stat" and "get-file" commands.  Its paths are relative to the
+// URL of the session.
+//
+// If rev is negative, the file system is pinned to the latest revision
+// at the time of its first use.
+//
+// The [fs.FileInfo] of every file has its CreatedDate as ModTime, and
+// its [Dirent] as Sys.  Files are read in memory when they are opened.
+//
+// The file system is safe for concurrent use: its commands are sent
+// one at a time.  The Client must not be used by anything else
+// at the same time (see [Client.OpenSession] and [Pool]).
+func (c *Client) FS(rev int) fs.FS {
+	return &clientFS{c: c, rev: rev}
+}
+
+// A clientFS is the file system returned by Client.FS.
+type clientFS struct {
+	// mu serializes the commands sent through c,
+	// and protects rev.
+	mu  sync.Mutex
+	c   *Client
+	rev int
+}
+
+var (
+	_ fs.ReadDirFS  = (*clientFS)(nil)
+	_ fs.ReadFileFS = (*clientFS)(nil)
+	_ fs.StatFS     = (*clientFS)(nil)
+)
+
+// do calls fn, which sends commands through the client, with the
+// revision of the file system, asking for the latest one the first
+// time if needed.  The calls are serialized.
+func (f *clientFS) do(fn func(rev *int) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rev < 0 {
+		rev, err := f.c.GetLatestRev()
+		if err != nil {
+			return err
+		}
+		f.rev = rev
+	}
+	rev := f.rev
+	return fn(&rev)
+}
+
+// svnPath returns the path in the session of a path in the file system,
+// or an error if it is not valid.
+func svnPath(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return "", nil
+	}
+	return name, nil
+}
+
+// fsError converts the errors of the server into the ones of package fs.
+func fsError(op string, name string, err error) error {
+	var svnErr Error
+	if errors.As(err, &svnErr) {
+		switch svnErr.AprErr {
+		case 160013: // SVN_ERR_FS_NOT_FOUND
+			err = fs.ErrNotExist
+		case 170001: // SVN_ERR_RA_NOT_AUTHORIZED
+			err = fs.ErrPermission
+		}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (f *clientFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := svnPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	var stat Stat
+	err = f.do(func(rev *int) (err error) {
+		stat, err = f.c.Stat(p, rev)
+		return err
+	})
+	if err != nil {
+		return nil, fsError("stat", name, err)
+	}
+	if stat.Kind == NodeNone {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fileInfo{Dirent{
+		Path:        path.Base(name),
+		Kind:        stat.Kind,
+		Size:        stat.Size,
+		HasProps:    stat.HasProps,
+		CreatedRev:  stat.CreatedRev,
+		CreatedDate: stat.CreatedDate,
+		LastAuthor:  stat.LastAuthor,
+	}}, nil
+}
+
+func (f *clientFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := svnPath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	var dirents []Dirent
+	err = f.do(func(rev *int) (err error) {
+		dirents, err = f.c.List(p, rev, "immediates", []string{"kind", "size", "has-props", "created-rev", "time", "last-author"})
+		return err
+	})
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	if len(dirents) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	// The directory itself is listed too,
+	// and its path is a prefix of the other ones.
+	self := slices.MinFunc(dirents, func(a, b Dirent) int { return len(a.Path) - len(b.Path) })
+	if self.Kind != NodeDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	var entries []fs.DirEntry
+	for _, dirent := range dirents {
+		if dirent.Path == self.Path {
+			continue
+		}
+		dirent.Path = path.Base(dirent.Path)
+		entries = append(entries, fileInfo{dirent})
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (f *clientFS) ReadFile(name string) ([]byte, error) {
+	p, err := svnPath("read", name)
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	err = f.do(func(rev *int) (err error) {
+		file, err = f.c.GetFile(p, rev, false, true, false)
+		return err
+	})
+	if err != nil {
+		return nil, fsError("read", name, err)
+	}
+	return file.Contents, nil
+}
+
+func (f *clientFS) Open(name string) (fs.File, error) {
+	info, err := f.Stat(name)
+	if err != nil {
+		err.(*fs.PathError).Op = "open"
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dirFile{fsys: f, name: name, info: info}, nil
+	}
+	contents, err := f.ReadFile(name)
+	if err != nil {
+		err.(*fs.PathError).Op = "open"
+		return nil, err
+	}
+	return &file{Reader: bytes.NewReader(contents), info: info}, nil
+}
+
+// fileInfo is the fs.FileInfo and fs.DirEntry of a Dirent
+// whose Path is its name.
+type fileInfo struct {
+	dirent Dirent
+}
+
+func (i fileInfo) Name() string { return i.dirent.Path }
+func (i fileInfo) Size() int64  { return int64(i.dirent.Size) }
+func (i fileInfo) IsDir() bool  { return i.dirent.Kind == NodeDir }
+func (i fileInfo) Sys() any     { return i.dirent }
+
+func (i fileInfo) Mode() fs.FileMode {
+	if i.IsDir() {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (i fileInfo) ModTime() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, i.dirent.CreatedDate)
+	return t
+}
+
+func (i fileInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i fileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// file is an open file of a clientFS.
+type file struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+// dirFile is an open directory of a clientFS.
+type dirFile struct {
+	fsys    *clientFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dirFile) Close() error               { return nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
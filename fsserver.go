@@ -0,0 +1,353 @@
+package svn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// fsAuthor and fsMessage are the author and the log message
+// of the revision served by NewFSServer.
+const (
+	fsAuthor  = "svn"
+	fsMessage = "Import of the file system"
+)
+
+// NewFSServer returns a Server which serves the tree of fsys (such as
+// an [os.DirFS], an [embed.FS] or a [zip.Reader]) to a single client,
+// using the repository returned by [NewFSRepository].
+// For example, to serve a directory with "svnserve -t" semantics:
+//
+//	s := svn.NewFSServer(os.DirFS("/srv/files"), "svn+ssh://example.com/files")
+//	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+//		log.Fatal(err)
+//	}
+func NewFSServer(fsys fs.FS, rootURL string) *Server {
+	return &Server{Repository: NewFSRepository(fsys, rootURL)}
+}
+
+// NewFSRepository returns a read-only repository with the tree of fsys,
+// in the only revision besides the empty revision 0.  Its author is "svn"
+// and its date is the modification time of the root of fsys (or the
+// current time, if it is unknown).  Executable files have the
+// "svn:executable" property.
+//
+// rootURL is the URL of the root of the repository: the sessions must
+// be opened in it or in one of its descendants.
+// The repository implements [ListRepository]; working copies are
+// updated with [TreeDelta].
+func NewFSRepository(fsys fs.FS, rootURL string) Repository {
+	f := &fsRepo{fsys: fsys, root: strings.TrimSuffix(rootURL, "/"), date: time.Now()}
+	if info, err := fs.Stat(fsys, "."); err == nil && !info.ModTime().IsZero() {
+		f.date = info.ModTime()
+	}
+	return f
+}
+
+// An fsRepo is the repository returned by NewFSRepository.
+type fsRepo struct {
+	fsys fs.FS
+	root string
+	date time.Time
+}
+
+var _ ListRepository = (*fsRepo)(nil)
+
+func (f *fsRepo) Open(sess *ServerSession) (ReposInfo, error) {
+	if _, ok := relativeURL(f.root, sess.URL); !ok {
+		return ReposInfo{}, Error{
+			AprErr:  170000, // SVN_ERR_RA_ILLEGAL_URL
+			Message: fmt.Sprintf("'%s' is not in the repository '%s'", sess.URL, f.root),
+		}
+	}
+	return ReposInfo{UUID: defaultUUID, URL: f.root, Capabilities: []string{}}, nil
+}
+
+func (f *fsRepo) LatestRev(sess *ServerSession) (uint, error) {
+	return 1, nil
+}
+
+func (f *fsRepo) Stat(sess *ServerSession, p string, rev *uint) (Stat, error) {
+	info, err := f.stat(p, rev)
+	if err != nil || info == nil {
+		return Stat{Kind: NodeNone}, err
+	}
+	d := f.dirent("", info)
+	if rev != nil && *rev == 0 {
+		d.CreatedRev, d.LastAuthor = 0, ""
+	}
+	return Stat{
+		Kind:        d.Kind,
+		Size:        d.Size,
+		HasProps:    d.HasProps,
+		CreatedRev:  d.CreatedRev,
+		CreatedDate: d.CreatedDate,
+		LastAuthor:  d.LastAuthor,
+	}, nil
+}
+
+func (f *fsRepo) CheckPath(sess *ServerSession, p string, rev *uint) (NodeKind, error) {
+	info, err := f.stat(p, rev)
+	if err != nil || info == nil {
+		return NodeNone, err
+	}
+	return f.dirent("", info).Kind, nil
+}
+
+// fsName returns the name in a fs.FS of a path in the repository.
+func fsName(p string) string {
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// checkRev returns an error if rev does not exist.
+// Revision 0 has only the empty root directory.
+func checkRev(rev uint) error {
+	if rev > 1 {
+		return Error{
+			AprErr:  160006, // SVN_ERR_FS_NO_SUCH_REVISION
+			Message: fmt.Sprintf("No such revision %d", rev),
+		}
+	}
+	return nil
+}
+
+// stat returns the information about a path in a revision,
+// or nil if it does not exist.
+func (f *fsRepo) stat(p string, rev *uint) (fs.FileInfo, error) {
+	if rev != nil {
+		if err := checkRev(*rev); err != nil {
+			return nil, err
+		}
+		if *rev == 0 && p != "" {
+			return nil, nil
+		}
+	}
+	info, err := fs.Stat(f.fsys, fsName(p))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || !fs.ValidPath(fsName(p)) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return info, nil
+}
+
+// lookup returns the information about an existing path,
+// or an error if it does not exist.
+func (f *fsRepo) lookup(p string, rev *uint) (fs.FileInfo, error) {
+	info, err := f.stat(p, rev)
+	if err == nil && info == nil {
+		err = Error{
+			AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+			Message: fmt.Sprintf("Path '/%s' not found", p),
+		}
+	}
+	return info, err
+}
+
+// dirent returns the Dirent of a node of the file system.
+func (f *fsRepo) dirent(p string, info fs.FileInfo) Dirent {
+	d := Dirent{
+		Path:        p,
+		Kind:        NodeFile,
+		Size:        uint64(info.Size()),
+		HasProps:    len(fsProps(info)) > 0,
+		CreatedRev:  1,
+		CreatedDate: f.date.UTC().Format(DateFormat),
+		LastAuthor:  fsAuthor,
+	}
+	if info.IsDir() {
+		d.Kind = NodeDir
+		d.Size = 0
+	}
+	return d
+}
+
+// fsProps returns the properties of a node of the file system.
+func fsProps(info fs.FileInfo) Props {
+	props := Props{}
+	if !info.IsDir() && info.Mode()&0o111 != 0 {
+		props[PropExecutable] = "*"
+	}
+	return props
+}
+
+// entries returns the entries of a directory in a revision.
+func (f *fsRepo) entries(p string, rev *uint) ([]fs.DirEntry, error) {
+	if rev != nil && *rev == 0 {
+		return nil, nil
+	}
+	return fs.ReadDir(f.fsys, fsName(p))
+}
+
+// List returns the entries in a path, up to the given depth, including
+// the path itself.  The path of every entry is absolute.  If patterns
+// are given, only the entries whose names match one of them are returned.
+func (f *fsRepo) List(sess *ServerSession, p string, rev *uint, depth string, fields []string, patterns []string) ([]Dirent, error) {
+	info, err := f.lookup(p, rev)
+	if err != nil {
+		return nil, err
+	}
+	var dirents []Dirent
+	var walk func(p string, info fs.FileInfo, depth string) error
+	walk = func(p string, info fs.FileInfo, depth string) error {
+		if fsMatch(patterns, path.Base("/"+p)) {
+			dirents = append(dirents, f.dirent("/"+p, info))
+		}
+		if !info.IsDir() || depth == "empty" {
+			return nil
+		}
+		entries, err := f.entries(p, rev)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if depth == "files" && entry.IsDir() {
+				continue
+			}
+			childInfo, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			childDepth := "empty"
+			if depth == "infinity" {
+				childDepth = depth
+			}
+			if err = walk(path.Join(p, entry.Name()), childInfo, childDepth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return dirents, walk(p, info, depth)
+}
+
+// fsMatch reports whether name matches any of the patterns,
+// or true if there are none.
+func fsMatch(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fsRepo) GetFile(sess *ServerSession, p string, rev *uint, wantProps bool, wantContents bool) (File, io.Reader, error) {
+	info, err := f.lookup(p, rev)
+	if err != nil {
+		return File{}, nil, err
+	}
+	if info.IsDir() {
+		return File{}, nil, Error{
+			AprErr:  160017, // SVN_ERR_FS_NOT_FILE
+			Message: fmt.Sprintf("Path '/%s' is not a file", p),
+		}
+	}
+	file := File{Rev: 1, Size: uint64(info.Size()), Props: fsProps(info)}
+	if !wantContents {
+		return file, nil, nil
+	}
+	r, err := f.fsys.Open(p)
+	if err != nil {
+		return File{}, nil, err
+	}
+	return file, r, nil
+}
+
+func (f *fsRepo) GetDir(sess *ServerSession, p string, rev *uint, wantProps bool, wantContents bool, fields []string) (Dir, error) {
+	info, err := f.lookup(p, rev)
+	if err != nil {
+		return Dir{}, err
+	}
+	if !info.IsDir() {
+		return Dir{}, Error{
+			AprErr:  160016, // SVN_ERR_FS_NOT_DIRECTORY
+			Message: fmt.Sprintf("Path '/%s' not a directory", p),
+		}
+	}
+	dir := Dir{Rev: 1, Props: Props{}}
+	if rev != nil {
+		dir.Rev = *rev
+	}
+	if !wantContents {
+		return dir, nil
+	}
+	entries, err := f.entries(p, rev)
+	if err != nil {
+		return Dir{}, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return Dir{}, err
+		}
+		dir.Entries = append(dir.Entries, f.dirent(entry.Name(), info))
+	}
+	return dir, nil
+}
+
+// Log returns the revisions between startRev and endRev (in that order)
+// which changed any of the given paths: revision 0 and, if they exist,
+// revision 1, which added every node.
+func (f *fsRepo) Log(sess *ServerSession, paths []string, startRev uint, endRev uint, changedPaths bool) ([]LogEntry, error) {
+	for _, rev := range []uint{startRev, endRev} {
+		if err := checkRev(rev); err != nil {
+			return nil, err
+		}
+	}
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	var entries []LogEntry
+	for _, rev := range []uint{startRev, endRev} {
+		if len(entries) > 0 && entries[0].Rev == rev {
+			break
+		}
+		entry := LogEntry{Rev: rev, Date: f.date.UTC().Format(DateFormat)}
+		if rev == 1 {
+			entry.Author = fsAuthor
+			entry.Message = fsMessage
+			var touched bool
+			for _, p := range paths {
+				info, err := f.stat(p, &rev)
+				if err != nil {
+					return nil, err
+				}
+				touched = touched || info != nil
+			}
+			if !touched {
+				continue
+			}
+			if changedPaths {
+				err := fs.WalkDir(f.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+					if err != nil {
+						return err
+					}
+					if p != "." {
+						entry.Changed = append(entry.Changed, struct {
+							Path string
+							Mode string
+						}{"/" + p, "A"})
+					}
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,136 @@
+package svn
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSServer(t *testing.T) {
+	fsys := fstest.MapFS{
+		"trunk/a.txt":      {Data: []byte("hello\n")},
+		"trunk/run.sh":     {Data: []byte("#!/bin/sh\n"), Mode: 0o755},
+		"trunk/doc/b.txt":  {Data: []byte("bye\n")},
+		"branches/.keep":   {},
+		"tags/v1/README":   {Data: []byte("v1\n")},
+		"tags/v1/.hidden":  {},
+		"tags/v1/sub/file": {Data: []byte("x")},
+	}
+	s := NewFSServer(fsys, "svn://example.com/repo")
+	client, server := net.Pipe()
+	go s.Serve(server, server)
+	c, err := NewClient(client, "svn://example.com/repo/trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Info.URL != "svn://example.com/repo" {
+		t.Errorf("repos-info: %+v", c.Info)
+	}
+	if !c.HasCapability("list") || c.HasCapability("commit-revprops") || c.HasCapability("mergeinfo") {
+		t.Errorf("capabilities: %q", c.Info.Capabilities)
+	}
+	if err := fstest.TestFS(c.FS(1), "a.txt", "run.sh", "doc/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	file, err := c.GetFile("run.sh", nil, true, true, false)
+	if err != nil || string(file.Contents) != "#!/bin/sh\n" || !file.Props.IsExecutable() || file.Rev != 1 {
+		t.Errorf("get-file run.sh: %+v, %v", file, err)
+	}
+	rev0 := 0
+	if kind, err := c.CheckPath("a.txt", &rev0); err != nil || kind != NodeNone {
+		t.Errorf("check-path a.txt@0: %s, %v", kind, err)
+	}
+	logs, err := c.Log([]string{"a.txt"}, nil, nil, false)
+	if err != nil || len(logs) != 2 || logs[0].Rev != 1 || logs[0].Author != fsAuthor {
+		t.Errorf("log: %+v, %v", logs, err)
+	}
+
+	// a checkout of trunk/doc, and an update of an up-to-date working copy:
+	sess := &ServerSession{URL: "svn://example.com/repo/trunk", Root: "svn://example.com/repo", UUID: defaultUUID}
+	var commands []string
+	edit := func(target string, rev uint, startEmpty bool) {
+		commands = nil
+		var items []Item
+		report := &Report{
+			Command:    "update",
+			Target:     target,
+			Depth:      "infinity",
+			TextDeltas: true,
+			Paths:      []ReportedPath{{Rev: rev, StartEmpty: startEmpty, Depth: "infinity"}},
+		}
+		if err := TreeDelta(s.Repository, sess, report, editorItems(&items)); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items {
+			commands = append(commands, item.List[0].Text)
+		}
+	}
+	edit("doc", 0, true)
+	want := []string{"target-rev", "open-root", "add-dir", "change-dir-prop", "change-dir-prop", "change-dir-prop", "change-dir-prop",
+		"add-file", "change-file-prop", "change-file-prop", "change-file-prop", "change-file-prop",
+		"apply-textdelta", "textdelta-chunk", "textdelta-end", "close-file", "close-dir", "close-dir"}
+	if !slices.Equal(commands, want) {
+		t.Errorf("checkout of doc:\n got %q\nwant %q", commands, want)
+	}
+	edit("", 1, false)
+	if want := []string{"target-rev", "open-root", "close-dir"}; !slices.Equal(commands, want) {
+		t.Errorf("update of trunk:\n got %q\nwant %q", commands, want)
+	}
+}
+
+func TestFSServerNotFound(t *testing.T) {
+	s := NewFSServer(fstest.MapFS{"trunk/a.txt": {Data: []byte("hello\n")}}, "svn://example.com/repo")
+	c := serverClient(t, s, "svn://example.com/repo/typo")
+	if c.Info.URL != "svn://example.com/repo" {
+		t.Errorf("repos-info: %+v", c.Info)
+	}
+	var svnErr Error
+	if entries, err := c.List("", nil, "infinity", nil); !errors.As(err, &svnErr) || svnErr.AprErr != 160013 {
+		t.Errorf("list of a missing path: %+v, %v", entries, err)
+	}
+	if kind, err := c.CheckPath("", nil); err != nil || kind != NodeNone {
+		t.Errorf("check-path of a missing path: %s, %v", kind, err)
+	}
+
+	client, server := net.Pipe()
+	go s.Serve(server, server)
+	if _, err := NewClient(client, "svn://example.com/other"); !errors.As(err, &svnErr) || svnErr.AprErr != 170000 {
+		t.Errorf("URL outside the repository: %v", err)
+	}
+}
+
+func TestClientFSConcurrent(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := range 20 {
+		fsys[fmt.Sprintf("trunk/%d.txt", i)] = &fstest.MapFile{Data: []byte(fmt.Sprintln(i))}
+	}
+	c := serverClient(t, NewFSServer(fsys, "svn://example.com/repo"), "svn://example.com/repo/trunk")
+	cfs := c.FS(-1)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(fsys))
+	for i := range len(fsys) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("%d.txt", i)
+			data, err := fs.ReadFile(cfs, name)
+			if err == nil && string(data) != fmt.Sprintln(i) {
+				err = fmt.Errorf("%s: %q", name, data)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
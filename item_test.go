@@ -133,3 +133,15 @@ func TestItemizer(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatItem(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	item, err := NewItemizer(strings.NewReader(`( success ( 2:ok ( word 42 ) 100:` + long + ` ) ) `)).Item()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `( success ( "ok" ( word 42 ) "` + long[:64] + `"...(100 bytes) ) )`
+	if got := formatItem(item); got != want {
+		t.Errorf("formatItem:\nwant %s\ngot  %s", want, got)
+	}
+}
@@ -0,0 +1,242 @@
+package svn
+
+import (
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DateFormat is the format of the dates used in the protocol.
+const DateFormat = "2006-01-02T15:04:05.000000Z"
+
+// A LockStore keeps the locks of a repository, and can be used by the
+// backends of a [Server] to implement its Lock, Unlock, GetLock, GetLocks
+// and Commit functions.  It is safe for concurrent use, and its
+// zero value is ready to use.
+//
+// Paths are absolute paths in the repository, with a leading "/".
+type LockStore struct {
+	// Timeout is the duration of the new locks.
+	// If zero, locks do not expire.
+	Timeout time.Duration
+
+	// Now returns the current time.  If nil, time.Now is used.
+	Now func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]Lock
+}
+
+func (ls *LockStore) now() time.Time {
+	if ls.Now != nil {
+		return ls.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// get returns the lock on path, removing it if it has expired.
+// ls.mu must be held.
+func (ls *LockStore) get(path string) (Lock, bool) {
+	lock, ok := ls.locks[path]
+	if !ok {
+		return Lock{}, false
+	}
+	if lock.Expires != "" {
+		expires, err := time.Parse(DateFormat, lock.Expires)
+		if err == nil && !ls.now().Before(expires) {
+			delete(ls.locks, path)
+			return Lock{}, false
+		}
+	}
+	return lock, true
+}
+
+// Lock locks path on behalf of user.  If the path is already locked,
+// it fails, unless steal is true, in which case the old lock is replaced.
+//
+// Checking that the path exists and is not out of date is up to the caller.
+func (ls *LockStore) Lock(path string, user string, comment string, steal bool) (Lock, error) {
+	if user == "" {
+		return Lock{}, Error{
+			AprErr:  160034, // SVN_ERR_FS_NO_USER
+			Message: fmt.Sprintf("Cannot lock path '%s', no authenticated username available.", path),
+		}
+	}
+	token, err := newLockToken()
+	if err != nil {
+		return Lock{}, err
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if old, ok := ls.get(path); ok && !steal {
+		return Lock{}, Error{
+			AprErr:  160035, // SVN_ERR_FS_PATH_ALREADY_LOCKED
+			Message: fmt.Sprintf("Path '%s' is already locked by user '%s'", path, old.Owner),
+		}
+	}
+	now := ls.now()
+	lock := Lock{
+		Path:    path,
+		Token:   token,
+		Owner:   user,
+		Comment: comment,
+		Created: now.Format(DateFormat),
+	}
+	if ls.Timeout > 0 {
+		lock.Expires = now.Add(ls.Timeout).Format(DateFormat)
+	}
+	if ls.locks == nil {
+		ls.locks = make(map[string]Lock)
+	}
+	ls.locks[path] = lock
+	return lock, nil
+}
+
+// Unlock removes the lock on path.  Unless breakLock is true,
+// token must be the one of the lock, and user its owner.
+func (ls *LockStore) Unlock(path string, user string, token string, breakLock bool) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	lock, ok := ls.get(path)
+	if !ok {
+		return Error{
+			AprErr:  160040, // SVN_ERR_FS_NO_SUCH_LOCK
+			Message: fmt.Sprintf("No lock on path '%s'", path),
+		}
+	}
+	if !breakLock {
+		if err := checkLock(lock, user, token); err != nil {
+			return err
+		}
+	}
+	delete(ls.locks, path)
+	return nil
+}
+
+// checkLock checks that a lock can be used by user, who has a token.
+func checkLock(lock Lock, user string, token string) error {
+	if user == "" {
+		return Error{
+			AprErr:  160034, // SVN_ERR_FS_NO_USER
+			Message: fmt.Sprintf("Cannot verify lock on path '%s'; no username available", lock.Path),
+		}
+	}
+	if token != lock.Token {
+		return Error{
+			AprErr:  160037, // SVN_ERR_FS_BAD_LOCK_TOKEN
+			Message: fmt.Sprintf("Cannot verify lock on path '%s'; no matching lock-token available", lock.Path),
+		}
+	}
+	if user != lock.Owner {
+		return Error{
+			AprErr:  160039, // SVN_ERR_FS_LOCK_OWNER_MISMATCH
+			Message: fmt.Sprintf("User '%s' does not own lock on path '%s' (currently locked by %s)", user, lock.Path, lock.Owner),
+		}
+	}
+	return nil
+}
+
+// GetLock returns the lock on path, or nil if it is not locked.
+func (ls *LockStore) GetLock(path string) *Lock {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	lock, ok := ls.get(path)
+	if !ok {
+		return nil
+	}
+	return &lock
+}
+
+// GetLocks returns the locks on path and its descendants, sorted by path,
+// up to the given depth: "empty" (only path), "files" or "immediates"
+// (path and its children) or "infinity".
+func (ls *LockStore) GetLocks(path string, depth string) []Lock {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	var locks []Lock
+	for p := range ls.locks {
+		rel, ok := childPath(path, p)
+		if !ok {
+			continue
+		}
+		switch depth {
+		case "empty":
+			if rel != "" {
+				continue
+			}
+		case "files", "immediates":
+			if strings.Contains(rel, "/") {
+				continue
+			}
+		}
+		if lock, ok := ls.get(p); ok {
+			locks = append(locks, lock)
+		}
+	}
+	slices.SortFunc(locks, func(a, b Lock) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return locks
+}
+
+// CheckCommit checks that user can commit changes to paths, given the
+// lock tokens sent with the commit.  Every locked path which is, or is
+// inside, one of the changed paths must have the right token and be
+// owned by user.
+func (ls *LockStore) CheckCommit(user string, paths []string, lockTokens map[string]string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for p := range ls.locks {
+		for _, changed := range paths {
+			if _, ok := childPath(changed, p); !ok {
+				continue
+			}
+			lock, ok := ls.get(p)
+			if !ok {
+				break
+			}
+			if err := checkLock(lock, user, lockTokens[p]); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// Release removes the locks given to a successful commit,
+// ignoring the ones that are not valid.
+func (ls *LockStore) Release(user string, lockTokens map[string]string) {
+	for path, token := range lockTokens {
+		ls.Unlock(path, user, token, false)
+	}
+}
+
+// childPath reports whether p is parent or one of its descendants,
+// and returns the path of p relative to parent.
+func childPath(parent string, p string) (string, bool) {
+	parent = strings.TrimSuffix(parent, "/")
+	if p == parent {
+		return "", true
+	}
+	if rel, ok := strings.CutPrefix(p, parent+"/"); ok {
+		return rel, true
+	}
+	return "", false
+}
+
+// newLockToken returns a new random lock token,
+// with the form "opaquelocktoken:" followed by an UUID.
+func newLockToken() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+	return fmt.Sprintf("opaquelocktoken:%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]), nil
+}
@@ -0,0 +1,85 @@
+package svn
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockStore(t *testing.T) {
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	ls := LockStore{
+		Timeout: time.Hour,
+		Now:     func() time.Time { return now },
+	}
+	aprErr := func(err error) int {
+		if e, ok := err.(Error); ok {
+			return e.AprErr
+		}
+		return 0
+	}
+
+	if _, err := ls.Lock("/a", "", "", false); aprErr(err) != 160034 {
+		t.Errorf("anonymous lock: got %v", err)
+	}
+	lock, err := ls.Lock("/a", "alice", "editing", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(lock.Token, "opaquelocktoken:") || len(lock.Token) != len("opaquelocktoken:")+36 {
+		t.Errorf("bad token %q", lock.Token)
+	}
+	if lock.Expires != "2024-04-01T13:00:00.000000Z" {
+		t.Errorf("bad expiration %q", lock.Expires)
+	}
+	if _, err := ls.Lock("/a", "bob", "", false); aprErr(err) != 160035 {
+		t.Errorf("lock again: got %v", err)
+	}
+	if err := ls.Unlock("/a", "bob", lock.Token, false); aprErr(err) != 160039 {
+		t.Errorf("unlock by other user: got %v", err)
+	}
+	if err := ls.Unlock("/a", "alice", "opaquelocktoken:x", false); aprErr(err) != 160037 {
+		t.Errorf("unlock with bad token: got %v", err)
+	}
+
+	// commits:
+	ls.Lock("/dir/b", "alice", "", false)
+	tokens := map[string]string{"/a": lock.Token}
+	if err := ls.CheckCommit("alice", []string{"/a", "/c"}, tokens); err != nil {
+		t.Errorf("commit with token: %v", err)
+	}
+	if err := ls.CheckCommit("alice", []string{"/a"}, nil); aprErr(err) != 160037 {
+		t.Errorf("commit without token: got %v", err)
+	}
+	if err := ls.CheckCommit("bob", []string{"/a"}, tokens); aprErr(err) != 160039 {
+		t.Errorf("commit by other user: got %v", err)
+	}
+	if err := ls.CheckCommit("alice", []string{"/dir"}, tokens); aprErr(err) != 160037 {
+		t.Errorf("commit of parent directory: got %v", err)
+	}
+	if got := len(ls.GetLocks("/", "infinity")); got != 2 {
+		t.Errorf("GetLocks(infinity): got %d locks", got)
+	}
+	if got := len(ls.GetLocks("/", "immediates")); got != 1 {
+		t.Errorf("GetLocks(immediates): got %d locks", got)
+	}
+
+	// steal, break and expiration:
+	stolen, err := ls.Lock("/a", "bob", "", true)
+	if err != nil || stolen.Owner != "bob" || stolen.Token == lock.Token {
+		t.Errorf("steal: got %+v, %v", stolen, err)
+	}
+	if err := ls.Unlock("/a", "alice", "", true); err != nil {
+		t.Errorf("break: %v", err)
+	}
+	if ls.GetLock("/a") != nil {
+		t.Errorf("lock still present after break")
+	}
+	now = now.Add(2 * time.Hour)
+	if ls.GetLock("/dir/b") != nil {
+		t.Errorf("lock did not expire")
+	}
+	if err := ls.Unlock("/dir/b", "alice", "", true); aprErr(err) != 160040 {
+		t.Errorf("unlock expired lock: got %v", err)
+	}
+}
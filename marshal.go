@@ -11,6 +11,20 @@ import (
 //	StringType
 //	ListType
 
+// Marshaler is the interface implemented by types that
+// can marshal themselves into an Item.
+type Marshaler interface {
+	MarshalItem() (Item, error)
+}
+
+// Unmarshaler is the interface implemented by types that
+// can unmarshal an Item of themselves.
+type Unmarshaler interface {
+	UnmarshalItem(Item) error
+}
+
+var unmarshalerType = reflect.TypeFor[Unmarshaler]()
+
 // Marshal converts v into an Item.
 //
 // Marshal traverses the value v recursively.
@@ -23,10 +37,18 @@ import (
 //
 // Array, slice and struct values encode as lists, except that []byte
 // values encode as strings.
+//
+// If v implements [Marshaler], Marshal calls its MarshalItem method.
 func Marshal(v any) (Item, error) {
 	if i, ok := v.(Item); ok {
 		return i, nil
 	}
+	if m, ok := v.(Marshaler); ok {
+		// a nil pointer is marshaled as an absent value, below.
+		if rv := reflect.ValueOf(v); rv.Kind() != reflect.Pointer || !rv.IsNil() {
+			return m.MarshalItem()
+		}
+	}
 	switch v := reflect.ValueOf(v); v.Kind() {
 	case reflect.Bool:
 		return Item{
@@ -124,7 +146,8 @@ func Marshal(v any) (Item, error) {
 //
 // To unmarshal a list Item into a struct, Unmarshal matches the values
 // in the same order as they are declared in the struct.  If there are extra
-// fields in the struct, they are ignored.
+// fields in the struct, they are ignored.  As a special case, a struct
+// whose only field is an exported struct is unmarshaled as that field.
 //
 // To unmarshal an Item into an interface value, Unmarshal stores one of these
 // in the interface value:
@@ -137,6 +160,9 @@ func Marshal(v any) (Item, error) {
 // to zero and then appends each element to the slice. As a special case,
 // to unmarshal an empty list into a slice, Unmarshal replaces the slice
 // with a new empty slice.
+//
+// If the destination implements [Unmarshaler], Unmarshal calls its
+// UnmarshalItem method.
 func Unmarshal(item Item, v any) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Pointer || rv.IsNil() {
@@ -151,6 +177,9 @@ func unmarshal(item Item, v reflect.Value) error {
 		v.Set(reflect.ValueOf(item))
 		return nil
 	}
+	if v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		return v.Addr().Interface().(Unmarshaler).UnmarshalItem(item)
+	}
 
 	switch item.Type {
 	case WordType:
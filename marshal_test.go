@@ -0,0 +1,54 @@
+package svn
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalResponses(t *testing.T) {
+	parse := func(s string) Item {
+		item, err := NewItemizer(strings.NewReader(s)).Item()
+		if err != nil {
+			t.Fatalf("parsing %q: %v", s, err)
+		}
+		return item
+	}
+
+	_, err := ParseResponse(parse("( failure ( ( 160035 11:Path locked 6:lock.c 42 ) ( 1 0: 0: 0 ) ) ) "))
+	want := Error{AprErr: 160035, Message: "Path locked", File: "lock.c", Line: 42}
+	if err != want {
+		t.Errorf("failure: want %#v got %#v", want, err)
+	}
+
+	var stat struct{ Entry []Stat }
+	if err := Unmarshal(parse("( ( ( file 5 true 3 ( 4:date ) ( 2:me ) ) ) ) "), &stat); err != nil {
+		t.Fatal(err)
+	}
+	if len(stat.Entry) != 1 || stat.Entry[0] != (Stat{Kind: NodeFile, Size: 5, HasProps: true, CreatedRev: 3, CreatedDate: "date", LastAuthor: "me"}) {
+		t.Errorf("stat: got %+v", stat)
+	}
+
+	// a struct whose only field is a struct is unmarshaled as that field:
+	var wrapped struct{ Stat Stat }
+	if err := Unmarshal(parse("( file 5 true 3 ( 4:date ) ( 2:me ) ) "), &wrapped); err != nil {
+		t.Fatal(err)
+	}
+	if wrapped.Stat != (Stat{Kind: NodeFile, Size: 5, HasProps: true, CreatedRev: 3, CreatedDate: "date", LastAuthor: "me"}) {
+		t.Errorf("wrapped stat: got %+v", wrapped)
+	}
+
+	var locks []Lock
+	if err := Unmarshal(parse("( ( 2:/a 5:token 5:alice ( ) 4:date ( ) ) ) "), &locks); err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 1 || locks[0] != (Lock{Path: "/a", Token: "token", Owner: "alice", Created: "date"}) {
+		t.Errorf("lock: got %+v", locks)
+	}
+	again, err := Marshal(struct{ Lock Lock }{locks[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.String(); got != "( ( 2:/a 5:token 5:alice ( ) 4:date ( ) ) )" {
+		t.Errorf("lock: marshaled as %s", got)
+	}
+}
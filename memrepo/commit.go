@@ -0,0 +1,553 @@
+package memrepo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cespedes/svn"
+	"github.com/cespedes/svn/svndiff"
+)
+
+// Commit creates a new revision on behalf of user, calling fn to
+// describe the changes with an [svn.Editor], starting with OpenRoot,
+// as in [svn.Client.Commit].  The revision is created when the editor
+// is closed; if fn does not close it, it is closed when fn returns.
+//
+// lockTokens has the tokens of the locks on the changed paths,
+// indexed by absolute path.  Unless keepLocks is true, those locks
+// are released after the commit.
+func (r *Repo) Commit(user string, revProps svn.Props, lockTokens map[string]string, keepLocks bool, fn func(svn.Editor) error) (svn.CommitInfo, error) {
+	return r.commit("", "", user, revProps, lockTokens, keepLocks, fn)
+}
+
+// commit is like Commit, with the URL of the root of the repository,
+// used to find the sources of copies sent by the clients,
+// and the path of the root of the edit.
+func (r *Repo) commit(rootURL string, editRoot string, user string, revProps svn.Props, lockTokens map[string]string, keepLocks bool, fn func(svn.Editor) error) (svn.CommitInfo, error) {
+	r.mu.RLock()
+	base := r.revs[len(r.revs)-1]
+	r.mu.RUnlock()
+	root := base.root.clone()
+	t := &txn{
+		repo:       r,
+		rootURL:    rootURL,
+		editRoot:   editRoot,
+		user:       user,
+		revProps:   revProps,
+		lockTokens: lockTokens,
+		keepLocks:  keepLocks,
+		base:       base,
+		root:       root,
+		owned:      map[*node]bool{root: true},
+		dirs:       make(map[string]string),
+		dirRevs:    make(map[string]*uint),
+		files:      make(map[string]string),
+		changed:    make(map[string]string),
+		copies:     make(map[string]changedPath),
+	}
+	if err := fn(t); err != nil {
+		return svn.CommitInfo{}, err
+	}
+	if t.aborted {
+		return svn.CommitInfo{}, errors.New("memrepo: commit aborted")
+	}
+	if !t.closed {
+		if err := t.CloseEdit(); err != nil {
+			return svn.CommitInfo{}, err
+		}
+	}
+	return t.info, nil
+}
+
+// A txn is a transaction: an [svn.Editor] which builds a new revision,
+// modifying a copy of the latest one.
+type txn struct {
+	repo       *Repo
+	rootURL    string
+	editRoot   string
+	user       string
+	revProps   svn.Props
+	lockTokens map[string]string
+	keepLocks  bool
+
+	base    *revision
+	root    *node
+	owned   map[*node]bool         // nodes created or cloned in this transaction
+	dirs    map[string]string      // directory tokens to paths
+	dirRevs map[string]*uint       // directory tokens to base revisions
+	files   map[string]string      // file tokens to paths
+	changed map[string]string      // absolute paths to kind of change
+	copies  map[string]changedPath // absolute paths to their copy sources
+
+	closed  bool
+	aborted bool
+	info    svn.CommitInfo
+}
+
+func outOfDate(p string) error {
+	return svn.Error{
+		AprErr:  160028, // SVN_ERR_FS_TXN_OUT_OF_DATE
+		Message: fmt.Sprintf("Item '%s' is out of date", absPath(p)),
+	}
+}
+
+func notFound(p string) error {
+	return svn.Error{
+		AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+		Message: fmt.Sprintf("Path '%s' not present", absPath(p)),
+	}
+}
+
+func badToken(token string) error {
+	return svn.Error{
+		AprErr:  210004, // SVN_ERR_RA_SVN_MALFORMED_DATA
+		Message: fmt.Sprintf("Invalid token '%s'", token),
+	}
+}
+
+// mutable returns the node in a path in the transaction,
+// cloning it and its parents if they are shared with other revisions.
+func (t *txn) mutable(p string) (*node, error) {
+	n := t.root
+	for _, name := range splitPath(p) {
+		if n.kind != svn.NodeDir || n.entries[name] == nil {
+			return nil, notFound(p)
+		}
+		child := n.entries[name]
+		if !t.owned[child] {
+			child = child.clone()
+			t.owned[child] = true
+			n.entries[name] = child
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// mark records a change in a path.
+func (t *txn) mark(p string, mode string) {
+	p = absPath(p)
+	old := t.changed[p]
+	switch {
+	case mode == "M" && old != "":
+		return
+	case mode == "A" && old == "D":
+		mode = "R"
+	}
+	if mode == "D" {
+		// the changes inside a deleted node are gone:
+		for c := range t.changed {
+			if strings.HasPrefix(c, p+"/") {
+				delete(t.changed, c)
+				delete(t.copies, c)
+			}
+		}
+		delete(t.copies, p)
+		if old == "A" {
+			delete(t.changed, p)
+			return
+		}
+	}
+	t.changed[p] = mode
+}
+
+// copySource returns a copy of the node in copyPath@copyRev,
+// which can be a path or an URL inside the repository,
+// and its absolute path.
+func (t *txn) copySource(copyPath string, copyRev uint) (*node, string, error) {
+	if strings.Contains(copyPath, "://") {
+		rel, ok := strings.CutPrefix(copyPath, strings.TrimSuffix(t.rootURL, "/"))
+		if t.rootURL == "" || !ok {
+			return nil, "", svn.Error{
+				AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+				Message: fmt.Sprintf("Source url '%s' is from different repository", copyPath),
+			}
+		}
+		var err error
+		if copyPath, err = url.PathUnescape(rel); err != nil {
+			return nil, "", err
+		}
+	}
+	_, n, err := t.repo.lookup(copyPath, &copyRev)
+	if err != nil {
+		return nil, "", err
+	}
+	return n.clone(), absPath(copyPath), nil
+}
+
+// add adds a new node in a path, which can be a copy of copyPath@copyRev.
+func (t *txn) add(p string, n *node, copyPath string, copyRev uint) error {
+	parent, err := t.mutable(path.Dir(absPath(p)))
+	if err != nil {
+		return err
+	}
+	name := path.Base(absPath(p))
+	if parent.entries[name] != nil {
+		return svn.Error{
+			AprErr:  160020, // SVN_ERR_FS_ALREADY_EXISTS
+			Message: fmt.Sprintf("Path '%s' already exists", absPath(p)),
+		}
+	}
+	t.owned[n] = true
+	parent.entries[name] = n
+	t.mark(p, "A")
+	if copyPath != "" {
+		t.copies[absPath(p)] = changedPath{copyPath: copyPath, copyRev: copyRev}
+	}
+	return nil
+}
+
+func (t *txn) TargetRev(rev uint) error {
+	return nil
+}
+
+func (t *txn) OpenRoot(rev *uint, rootToken string) error {
+	t.dirs[rootToken] = t.editRoot
+	t.dirRevs[rootToken] = rev
+	return nil
+}
+
+func (t *txn) DeleteEntry(p string, rev *uint, dirToken string) error {
+	p = path.Join(t.editRoot, p)
+	n := t.root.lookup(p)
+	if n == nil {
+		return notFound(p)
+	}
+	if rev != nil && !t.owned[n] && n.createdRev > *rev {
+		return outOfDate(p)
+	}
+	parent, err := t.mutable(path.Dir(absPath(p)))
+	if err != nil {
+		return err
+	}
+	delete(parent.entries, path.Base(absPath(p)))
+	t.mark(p, "D")
+	return nil
+}
+
+func (t *txn) AddDir(p string, parentToken string, childToken string, copyPath string, copyRev uint) error {
+	p = path.Join(t.editRoot, p)
+	n := newDir()
+	if copyPath != "" {
+		var err error
+		if n, copyPath, err = t.copySource(copyPath, copyRev); err != nil {
+			return err
+		}
+	}
+	if err := t.add(p, n, copyPath, copyRev); err != nil {
+		return err
+	}
+	t.dirs[childToken] = p
+	return nil
+}
+
+func (t *txn) OpenDir(p string, parentToken string, childToken string, rev *uint) error {
+	p = path.Join(t.editRoot, p)
+	n := t.root.lookup(p)
+	if n == nil || n.kind != svn.NodeDir {
+		return notFound(p)
+	}
+	t.dirs[childToken] = p
+	t.dirRevs[childToken] = rev
+	return nil
+}
+
+func (t *txn) ChangeDirProp(dirToken string, name string, value *string) error {
+	p, ok := t.dirs[dirToken]
+	if !ok {
+		return badToken(dirToken)
+	}
+	// changing the properties of an out-of-date directory
+	// would lose the changes made after its revision.
+	if rev := t.dirRevs[dirToken]; rev != nil {
+		if n := t.base.root.lookup(p); n != nil && n.createdRev > *rev {
+			return outOfDate(p)
+		}
+	}
+	n, err := t.mutable(p)
+	if err != nil {
+		return err
+	}
+	n.props = svn.PropDelta{name: value}.Apply(n.props)
+	t.mark(p, "M")
+	return nil
+}
+
+func (t *txn) CloseDir(dirToken string) error {
+	if _, ok := t.dirs[dirToken]; !ok {
+		return badToken(dirToken)
+	}
+	delete(t.dirs, dirToken)
+	delete(t.dirRevs, dirToken)
+	return nil
+}
+
+func (t *txn) AbsentDir(p string, parentToken string) error {
+	return nil
+}
+
+func (t *txn) AddFile(p string, dirToken string, fileToken string, copyPath string, copyRev uint) error {
+	p = path.Join(t.editRoot, p)
+	n := &node{kind: svn.NodeFile, props: svn.Props{}}
+	if copyPath != "" {
+		var err error
+		if n, copyPath, err = t.copySource(copyPath, copyRev); err != nil {
+			return err
+		}
+	}
+	if err := t.add(p, n, copyPath, copyRev); err != nil {
+		return err
+	}
+	t.files[fileToken] = p
+	return nil
+}
+
+func (t *txn) OpenFile(p string, dirToken string, fileToken string, rev *uint) error {
+	p = path.Join(t.editRoot, p)
+	n := t.root.lookup(p)
+	if n == nil || n.kind != svn.NodeFile {
+		return notFound(p)
+	}
+	if rev != nil && !t.owned[n] && n.createdRev > *rev {
+		return outOfDate(p)
+	}
+	t.files[fileToken] = p
+	return nil
+}
+
+func (t *txn) file(fileToken string) (string, *node, error) {
+	p, ok := t.files[fileToken]
+	if !ok {
+		return "", nil, badToken(fileToken)
+	}
+	n, err := t.mutable(p)
+	return p, n, err
+}
+
+func (t *txn) ApplyTextDelta(fileToken string, baseChecksum string) (io.WriteCloser, error) {
+	p, n, err := t.file(fileToken)
+	if err != nil {
+		return nil, err
+	}
+	if baseChecksum != "" && baseChecksum != md5sum(n.contents) {
+		return nil, svn.Error{
+			AprErr:  200014, // SVN_ERR_CHECKSUM_MISMATCH
+			Message: fmt.Sprintf("Checksum mismatch for '%s': expected %s, actual %s", absPath(p), baseChecksum, md5sum(n.contents)),
+		}
+	}
+	t.mark(p, "M")
+	w := &deltaWriter{node: n}
+	w.dec = svndiff.NewDecoder(&w.buf, bytes.NewReader(n.contents))
+	return w, nil
+}
+
+// deltaWriter applies a svndiff delta to the contents of a file.
+type deltaWriter struct {
+	node *node
+	dec  *svndiff.Decoder
+	buf  bytes.Buffer
+}
+
+func (w *deltaWriter) Write(p []byte) (int, error) {
+	return w.dec.Write(p)
+}
+
+func (w *deltaWriter) Close() error {
+	if err := w.dec.Close(); err != nil {
+		return err
+	}
+	w.node.contents = w.buf.Bytes()
+	return nil
+}
+
+func (t *txn) ChangeFileProp(fileToken string, name string, value *string) error {
+	p, n, err := t.file(fileToken)
+	if err != nil {
+		return err
+	}
+	n.props = svn.PropDelta{name: value}.Apply(n.props)
+	t.mark(p, "M")
+	return nil
+}
+
+func (t *txn) CloseFile(fileToken string, textChecksum string) error {
+	p, n, err := t.file(fileToken)
+	if err != nil {
+		return err
+	}
+	if textChecksum != "" && textChecksum != md5sum(n.contents) {
+		return svn.Error{
+			AprErr:  200014, // SVN_ERR_CHECKSUM_MISMATCH
+			Message: fmt.Sprintf("Checksum mismatch for '%s': expected %s, actual %s", absPath(p), textChecksum, md5sum(n.contents)),
+		}
+	}
+	delete(t.files, fileToken)
+	return nil
+}
+
+func (t *txn) AbsentFile(p string, parentToken string) error {
+	return nil
+}
+
+func (t *txn) AbortEdit() error {
+	t.aborted = true
+	return nil
+}
+
+// CloseEdit creates the new revision, applying the changes on top
+// of the latest revision (which may be newer than the one the
+// transaction started from, if they do not conflict).
+func (t *txn) CloseEdit() error {
+	r := t.repo
+	paths := make([]string, 0, len(t.changed))
+	for p := range t.changed {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+
+	// the locks are checked with the repository locked, as Lock does,
+	// so that no path can be locked before the commit is done.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.Locks.CheckCommit(t.user, paths, t.lockTokens); err != nil {
+		return err
+	}
+	if r.EnforceNeedsLock {
+		for _, p := range paths {
+			n := t.base.root.lookup(p)
+			if t.changed[p] == "A" || n == nil || !n.props.NeedsLock() {
+				continue
+			}
+			if r.Locks.GetLock(p) == nil {
+				return svn.Error{
+					AprErr:  160036, // SVN_ERR_FS_PATH_NOT_LOCKED
+					Message: fmt.Sprintf("Cannot modify '%s': it has the svn:needs-lock property, and it is not locked", p),
+				}
+			}
+		}
+	}
+
+	head := r.revs[len(r.revs)-1]
+	newRev := uint(len(r.revs))
+	root := head.root.clone()
+	mine := map[*node]bool{root: true}
+	parentOf := func(p string) (*node, error) {
+		n := root
+		for _, name := range splitPath(path.Dir(p)) {
+			child := n.entries[name]
+			if child == nil || child.kind != svn.NodeDir {
+				return nil, outOfDate(p)
+			}
+			if !mine[child] {
+				child = child.clone()
+				mine[child] = true
+				n.entries[name] = child
+			}
+			n = child
+		}
+		return n, nil
+	}
+
+	var changed []changedPath
+	for _, p := range paths {
+		mode := t.changed[p]
+		c := t.copies[p]
+		c.path, c.mode = p, mode
+		changed = append(changed, c)
+		if t.insideAdded(p) {
+			// already copied with its parent
+			continue
+		}
+		headNode := head.root.lookup(p)
+		baseNode := t.base.root.lookup(p)
+		txnNode := t.root.lookup(p)
+		switch {
+		case mode == "A" && headNode != nil:
+			return outOfDate(p)
+		case mode == "D" || mode == "R":
+			if headNode != baseNode {
+				return outOfDate(p)
+			}
+		case mode == "M" && txnNode.kind == svn.NodeFile:
+			if headNode != baseNode {
+				return outOfDate(p)
+			}
+		case mode == "M":
+			if headNode == nil {
+				return outOfDate(p)
+			}
+		}
+		if p == "/" {
+			root.props = txnNode.props
+			continue
+		}
+		parent, err := parentOf(p)
+		if err != nil {
+			return err
+		}
+		name := path.Base(p)
+		switch {
+		case mode == "D":
+			delete(parent.entries, name)
+		case mode == "M" && txnNode.kind == svn.NodeDir:
+			n := parent.entries[name]
+			if !mine[n] {
+				n = n.clone()
+				mine[n] = true
+				parent.entries[name] = n
+			}
+			n.props = txnNode.props
+		default:
+			parent.entries[name] = txnNode
+		}
+	}
+	for n := range mine {
+		n.createdRev = newRev
+	}
+	for n := range t.owned {
+		n.createdRev = newRev
+	}
+
+	props := svn.Props{}
+	for name, value := range t.revProps {
+		props[name] = value
+	}
+	if t.user != "" {
+		props[svn.PropRevAuthor] = t.user
+	}
+	props[svn.PropRevDate] = time.Now().UTC().Format(svn.DateFormat)
+	r.revs = append(r.revs, &revision{
+		root:    root,
+		props:   props,
+		changed: changed,
+	})
+	t.closed = true
+	t.info = svn.CommitInfo{
+		Rev:    newRev,
+		Date:   props[svn.PropRevDate],
+		Author: t.user,
+	}
+	if !t.keepLocks {
+		r.Locks.Release(t.user, t.lockTokens)
+	}
+	return nil
+}
+
+// insideAdded reports whether p is inside a node added
+// or replaced in the transaction.
+func (t *txn) insideAdded(p string) bool {
+	for p != "/" {
+		p = path.Dir(p)
+		if mode := t.changed[p]; mode == "A" || mode == "R" {
+			return true
+		}
+	}
+	return false
+}
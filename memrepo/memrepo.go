@@ -0,0 +1,730 @@
+// Package memrepo implements a SVN repository kept in memory,
+// which can be used as a backend for [svn.Server], mostly for tests.
+//
+// Every revision is an immutable tree; commits share the nodes
+// which did not change with the previous revisions.
+package memrepo
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespedes/svn"
+	"github.com/cespedes/svn/mergeinfo"
+)
+
+// A Repo is a SVN repository kept in memory.
+// It is safe for concurrent use.
+type Repo struct {
+	UUID string
+
+	// Locks has the locks of the paths in the repository.
+	Locks svn.LockStore
+
+	// If EnforceNeedsLock is true, commits changing or deleting a
+	// file with the "svn:needs-lock" property fail unless the file
+	// is locked by the committer.
+	EnforceNeedsLock bool
+
+	mu   sync.RWMutex
+	revs []*revision
+}
+
+// A node is a file or a directory in a revision.
+// Nodes are never modified once they are part of a revision.
+type node struct {
+	kind       svn.NodeKind
+	props      svn.Props
+	contents   []byte
+	entries    map[string]*node
+	createdRev uint
+}
+
+func newDir() *node {
+	return &node{
+		kind:    svn.NodeDir,
+		props:   svn.Props{},
+		entries: make(map[string]*node),
+	}
+}
+
+// clone returns a shallow copy of n.
+func (n *node) clone() *node {
+	c := *n
+	c.props = svn.Props{}
+	for name, value := range n.props {
+		c.props[name] = value
+	}
+	if n.entries != nil {
+		c.entries = make(map[string]*node, len(n.entries))
+		for name, child := range n.entries {
+			c.entries[name] = child
+		}
+	}
+	return &c
+}
+
+// lookup returns the node in a path relative to n, or nil.
+func (n *node) lookup(p string) *node {
+	for _, name := range splitPath(p) {
+		if n.kind != svn.NodeDir {
+			return nil
+		}
+		n = n.entries[name]
+		if n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+type revision struct {
+	root    *node
+	props   svn.Props
+	changed []changedPath
+}
+
+type changedPath struct {
+	path     string // absolute path, with a leading "/"
+	mode     string // "A", "D", "R" or "M"
+	copyPath string // for added nodes, the source of the copy (if any)
+	copyRev  uint
+}
+
+// New returns a new repository, with only revision 0
+// (an empty root directory) and a random UUID.
+func New() *Repo {
+	return &Repo{
+		UUID: newUUID(),
+		revs: []*revision{{
+			root:  newDir(),
+			props: svn.Props{svn.PropRevDate: time.Now().UTC().Format(svn.DateFormat)},
+		}},
+	}
+}
+
+// Youngest returns the number of the latest revision.
+func (r *Repo) Youngest() uint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return uint(len(r.revs) - 1)
+}
+
+// revision returns a revision (or the latest one, if rev is nil).
+func (r *Repo) revision(rev *uint) (uint, *revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := uint(len(r.revs) - 1)
+	if rev != nil {
+		n = *rev
+	}
+	if n >= uint(len(r.revs)) {
+		return 0, nil, svn.Error{
+			AprErr:  160006, // SVN_ERR_FS_NO_SUCH_REVISION
+			Message: fmt.Sprintf("No such revision %d", n),
+		}
+	}
+	return n, r.revs[n], nil
+}
+
+// RevProps returns the properties of a revision.
+func (r *Repo) RevProps(rev uint) (svn.Props, error) {
+	_, revision, err := r.revision(&rev)
+	if err != nil {
+		return nil, err
+	}
+	return revision.props, nil
+}
+
+// lookup returns the node in a path in a revision, failing if it does not exist.
+func (r *Repo) lookup(p string, rev *uint) (uint, *node, error) {
+	n, revision, err := r.revision(rev)
+	if err != nil {
+		return 0, nil, err
+	}
+	node := revision.root.lookup(p)
+	if node == nil {
+		return 0, nil, svn.Error{
+			AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+			Message: fmt.Sprintf("File not found: revision %d, path '%s'", n, absPath(p)),
+		}
+	}
+	return n, node, nil
+}
+
+// Stat returns the status of a path, with a Kind of [svn.NodeNone]
+// if it does not exist.
+func (r *Repo) Stat(p string, rev *uint) (svn.Dirent, error) {
+	_, revision, err := r.revision(rev)
+	if err != nil {
+		return svn.Dirent{}, err
+	}
+	node := revision.root.lookup(p)
+	if node == nil {
+		return svn.Dirent{Kind: svn.NodeNone}, nil
+	}
+	return r.dirent(absPath(p), node), nil
+}
+
+// dirent returns the description of a node.
+func (r *Repo) dirent(p string, n *node) svn.Dirent {
+	props, _ := r.RevProps(n.createdRev)
+	return svn.Dirent{
+		Path:        p,
+		Kind:        n.kind,
+		Size:        uint64(len(n.contents)),
+		HasProps:    len(n.props) > 0,
+		CreatedRev:  n.createdRev,
+		CreatedDate: props[svn.PropRevDate],
+		LastAuthor:  props[svn.PropRevAuthor],
+	}
+}
+
+// CheckPath returns the kind of a path.
+func (r *Repo) CheckPath(p string, rev *uint) (svn.NodeKind, error) {
+	stat, err := r.Stat(p, rev)
+	return stat.Kind, err
+}
+
+// GetFile returns the revision, properties and contents of a file.
+func (r *Repo) GetFile(p string, rev *uint) (uint, svn.Props, []byte, error) {
+	n, node, err := r.lookup(p, rev)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if node.kind != svn.NodeFile {
+		return 0, nil, nil, svn.Error{
+			AprErr:  160017, // SVN_ERR_FS_NOT_FILE
+			Message: fmt.Sprintf("Attempted to get textual contents of a *non*-file node '%s'", absPath(p)),
+		}
+	}
+	return n, node.props, node.contents, nil
+}
+
+// GetDir returns the revision, properties and entries of a directory.
+// The path of every entry is its name.
+func (r *Repo) GetDir(p string, rev *uint) (svn.Dir, error) {
+	n, node, err := r.lookup(p, rev)
+	if err != nil {
+		return svn.Dir{}, err
+	}
+	if node.kind != svn.NodeDir {
+		return svn.Dir{}, svn.Error{
+			AprErr:  160016, // SVN_ERR_FS_NOT_DIRECTORY
+			Message: fmt.Sprintf("Can't get entries of non-directory '%s'", absPath(p)),
+		}
+	}
+	dir := svn.Dir{Rev: n, Props: node.props}
+	for _, name := range sortedNames(node) {
+		dir.Entries = append(dir.Entries, r.dirent(name, node.entries[name]))
+	}
+	return dir, nil
+}
+
+// List returns the entries in a path, up to the given depth, including
+// the path itself.  The path of every entry is absolute.  If patterns
+// are given, only the entries whose names match one of them are returned.
+func (r *Repo) List(p string, rev *uint, depth string, patterns []string) ([]svn.Dirent, error) {
+	_, root, err := r.lookup(p, rev)
+	if err != nil {
+		return nil, err
+	}
+	var dirents []svn.Dirent
+	var walk func(p string, n *node, depth string)
+	walk = func(p string, n *node, depth string) {
+		if matchAny(patterns, path.Base(p)) {
+			dirents = append(dirents, r.dirent(p, n))
+		}
+		if n.kind != svn.NodeDir || depth == "empty" {
+			return
+		}
+		for _, name := range sortedNames(n) {
+			child := n.entries[name]
+			if depth == "files" && child.kind != svn.NodeFile {
+				continue
+			}
+			childDepth := "empty"
+			if depth == "infinity" {
+				childDepth = depth
+			}
+			walk(path.Join(p, name), child, childDepth)
+		}
+	}
+	walk(absPath(p), root, depth)
+	return dirents, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GetIProps returns the properties inherited by a path from its parents.
+func (r *Repo) GetIProps(p string, rev *uint) (svn.InheritedProps, error) {
+	_, revision, err := r.revision(rev)
+	if err != nil {
+		return nil, err
+	}
+	var iprops svn.InheritedProps
+	names := splitPath(p)
+	n := revision.root
+	for i := range names {
+		if len(n.props) > 0 {
+			iprops = append(iprops, struct {
+				Path  string
+				Props svn.Props
+			}{strings.Join(names[:i], "/"), n.props})
+		}
+		if n = n.entries[names[i]]; n == nil {
+			return nil, svn.Error{
+				AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+				Message: fmt.Sprintf("Path '%s' not found", absPath(p)),
+			}
+		}
+	}
+	return iprops, nil
+}
+
+// GetLocationSegments returns the ranges of revisions, from startRev
+// back to endRev, in which the node in p@pegRev had the same path,
+// following its copies.  pegRev defaults to HEAD, startRev to pegRev
+// and endRev to 0.
+func (r *Repo) GetLocationSegments(p string, pegRev *uint, startRev *uint, endRev *uint) ([]svn.LocationSegment, error) {
+	peg, revision, err := r.revision(pegRev)
+	if err != nil {
+		return nil, err
+	}
+	start, end := peg, uint(0)
+	if startRev != nil {
+		start = *startRev
+	}
+	if endRev != nil {
+		end = *endRev
+	}
+	if start > peg || end > start {
+		return nil, svn.Error{
+			AprErr:  200004, // SVN_ERR_INCORRECT_PARAMS
+			Message: fmt.Sprintf("Invalid revisions: peg %d, start %d, end %d", peg, start, end),
+		}
+	}
+	if revision.root.lookup(p) == nil {
+		return nil, svn.Error{
+			AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+			Message: fmt.Sprintf("Path '%s' doesn't exist in revision %d", absPath(p), peg),
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var segments []svn.LocationSegment
+	cur, rev := absPath(p), peg
+	for {
+		// look for the revision in which the node was added:
+		born, from, found := rev, changedPath{}, false
+		for {
+			if from, found = addedAt(r.revs[born].changed, cur); found || born == 0 {
+				break
+			}
+			born--
+		}
+		segments = append(segments, svn.LocationSegment{RangeStart: born, RangeEnd: rev, Path: cur})
+		if !found || from.copyPath == "" {
+			break
+		}
+		if from.copyRev+1 < born {
+			// the revisions between the copy and its source are not in the history
+			segments = append(segments, svn.LocationSegment{RangeStart: from.copyRev + 1, RangeEnd: born - 1})
+		}
+		cur, rev = from.copyPath, from.copyRev
+	}
+
+	var result []svn.LocationSegment
+	for _, seg := range segments {
+		if seg.RangeEnd < end || seg.RangeStart > start {
+			continue
+		}
+		seg.RangeStart = max(seg.RangeStart, end)
+		seg.RangeEnd = min(seg.RangeEnd, start)
+		seg.Path = strings.TrimPrefix(seg.Path, "/")
+		result = append(result, seg)
+	}
+	return result, nil
+}
+
+// GetLocations returns the absolute paths that the node in p@pegRev
+// had in each of the revisions in revs.  The revisions after pegRev,
+// or in which the node did not exist, are left out.
+func (r *Repo) GetLocations(p string, pegRev uint, revs []uint) (map[uint]string, error) {
+	segments, err := r.GetLocationSegments(p, &pegRev, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	locations := make(map[uint]string)
+	for _, rev := range revs {
+		for _, seg := range segments {
+			if seg.Path != "" && seg.RangeStart <= rev && rev <= seg.RangeEnd {
+				locations[rev] = absPath(seg.Path)
+			}
+		}
+	}
+	return locations, nil
+}
+
+// GetDeletedRev returns the revision in which the node in p@pegRev
+// was deleted (or replaced), between pegRev and endRev, or nil if it
+// was not.
+func (r *Repo) GetDeletedRev(p string, pegRev uint, endRev uint) (*uint, error) {
+	if _, _, err := r.lookup(p, &pegRev); err != nil {
+		return nil, err
+	}
+	if _, _, err := r.revision(&endRev); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p = absPath(p)
+	for rev := pegRev + 1; rev <= endRev; rev++ {
+		for _, c := range r.revs[rev].changed {
+			if (c.mode == "D" || c.mode == "R") && (c.path == p || strings.HasPrefix(p, c.path+"/")) {
+				return &rev, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// GetFileRevs returns the revisions in which the file in p changed
+// between startRev and endRev (in reverse order if startRev > endRev),
+// following its copies, with its full contents in each of them.  The
+// first one is the revision in which it last changed before them.
+// The file is the one in p in the latest of them; startRev defaults
+// to 0 and endRev to the latest revision.
+func (r *Repo) GetFileRevs(p string, startRev *uint, endRev *uint) ([]svn.FileRev, error) {
+	lo, hi := uint(0), r.Youngest()
+	if startRev != nil {
+		lo = *startRev
+	}
+	if endRev != nil {
+		hi = *endRev
+	}
+	reverse := lo > hi
+	if reverse {
+		lo, hi = hi, lo
+	}
+	_, file, err := r.lookup(p, &hi)
+	if err != nil {
+		return nil, err
+	}
+	if file.kind != svn.NodeFile {
+		return nil, svn.Error{
+			AprErr:  160017, // SVN_ERR_FS_NOT_FILE
+			Message: fmt.Sprintf("'%s' is not a file", absPath(p)),
+		}
+	}
+	segments, err := r.GetLocationSegments(p, &hi, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	// the revisions, from the latest one, and the nodes in them:
+	var revs []svn.FileRev
+	var nodes []*node
+collect:
+	for _, seg := range segments {
+		if seg.Path == "" {
+			continue
+		}
+		for rev := seg.RangeEnd; ; rev-- {
+			n := r.revs[rev].root.lookup(seg.Path)
+			if n != nil && n.createdRev == rev {
+				revs = append(revs, svn.FileRev{
+					Path:     absPath(seg.Path),
+					Rev:      rev,
+					RevProps: maps.Clone(r.revs[rev].props),
+					Contents: n.contents,
+				})
+				nodes = append(nodes, n)
+				if rev <= lo {
+					break collect
+				}
+			}
+			if rev == seg.RangeStart {
+				break
+			}
+		}
+	}
+	if !reverse {
+		slices.Reverse(revs)
+		slices.Reverse(nodes)
+	}
+	prev := svn.Props{}
+	for i := range revs {
+		revs[i].PropDelta = propDelta(prev, nodes[i].props)
+		prev = nodes[i].props
+	}
+	return revs, nil
+}
+
+// propDelta returns the changes from the properties from to the ones in to.
+func propDelta(from svn.Props, to svn.Props) svn.PropDelta {
+	delta := svn.PropDelta{}
+	for name, value := range to {
+		if old, ok := from[name]; !ok || old != value {
+			delta[name] = &value
+		}
+	}
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			delta[name] = nil
+		}
+	}
+	return delta
+}
+
+// addedAt reports whether the node in p was added in one of the changes,
+// by itself or with one of its parents.  If it was copied, it
+// returns the path and revision of the source of the copy.
+func addedAt(changed []changedPath, p string) (changedPath, bool) {
+	for _, c := range changed {
+		if c.mode != "A" && c.mode != "R" {
+			continue
+		}
+		if c.path != p && !strings.HasPrefix(p, strings.TrimSuffix(c.path, "/")+"/") {
+			continue
+		}
+		if c.copyPath != "" {
+			c.copyPath = path.Join(c.copyPath, strings.TrimPrefix(p, c.path))
+		}
+		return c, true
+	}
+	return changedPath{}, false
+}
+
+// GetMergeinfo returns the mergeinfo of some paths, as described
+// in [svn.Client.GetMergeinfo].
+func (r *Repo) GetMergeinfo(paths []string, rev *uint, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error) {
+	_, revision, err := r.revision(rev)
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(map[string]mergeinfo.Mergeinfo)
+	add := func(p string, value string) error {
+		m, err := mergeinfo.Parse(value)
+		if err != nil {
+			return err
+		}
+		catalog[p] = m
+		return nil
+	}
+	for _, p := range paths {
+		n := revision.root.lookup(p)
+		if n == nil {
+			return nil, svn.Error{
+				AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+				Message: fmt.Sprintf("Path '%s' not found", absPath(p)),
+			}
+		}
+		value, explicit := n.props[svn.PropMergeinfo]
+		if explicit && inherit != "nearest-ancestor" {
+			if err := add(p, value); err != nil {
+				return nil, err
+			}
+		} else if inherit != "explicit" {
+			// look for the nearest parent with mergeinfo:
+			names := splitPath(p)
+			for i := len(names) - 1; i >= 0; i-- {
+				parent := revision.root.lookup(strings.Join(names[:i], "/"))
+				if value, ok := parent.props[svn.PropMergeinfo]; ok {
+					m, err := mergeinfo.Parse(value)
+					if err != nil {
+						return nil, err
+					}
+					if m = m.Inherit(strings.Join(names[i:], "/")); len(m) > 0 {
+						catalog[p] = m
+					}
+					break
+				}
+			}
+		}
+		if !includeDescendants || n.kind != svn.NodeDir {
+			continue
+		}
+		var walk func(p string, n *node) error
+		walk = func(p string, n *node) error {
+			for name, child := range n.entries {
+				childPath := path.Join(p, name)
+				if value, ok := child.props[svn.PropMergeinfo]; ok {
+					if err := add(childPath, value); err != nil {
+						return err
+					}
+				}
+				if err := walk(childPath, child); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := walk(p, n); err != nil {
+			return nil, err
+		}
+	}
+	return catalog, nil
+}
+
+// Log returns the revisions between startRev and endRev (in that order)
+// which changed any of the given paths or their descendants.
+func (r *Repo) Log(paths []string, startRev uint, endRev uint, changedPaths bool) ([]svn.LogEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	youngest := uint(len(r.revs) - 1)
+	if startRev > youngest || endRev > youngest {
+		return nil, svn.Error{
+			AprErr:  160006, // SVN_ERR_FS_NO_SUCH_REVISION
+			Message: fmt.Sprintf("No such revision %d", max(startRev, endRev)),
+		}
+	}
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	step := 1
+	if startRev > endRev {
+		step = -1
+	}
+	var entries []svn.LogEntry
+	for rev := int(startRev); ; rev += step {
+		revision := r.revs[rev]
+		if rev == 0 || touches(revision.changed, paths) {
+			entry := svn.LogEntry{
+				Rev:     uint(rev),
+				Author:  revision.props[svn.PropRevAuthor],
+				Date:    revision.props[svn.PropRevDate],
+				Message: revision.props[svn.PropRevLog],
+			}
+			if changedPaths {
+				for _, c := range revision.changed {
+					entry.Changed = append(entry.Changed, struct {
+						Path string
+						Mode string
+					}{c.path, c.mode})
+				}
+			}
+			entries = append(entries, entry)
+		}
+		if rev == int(endRev) {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// touches reports whether any of the changes is in one of the paths
+// or their descendants.
+func touches(changed []changedPath, paths []string) bool {
+	for _, c := range changed {
+		for _, p := range paths {
+			p = absPath(p)
+			if p == "/" || c.path == p || strings.HasPrefix(c.path, p+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Lock locks a file, on behalf of user.
+func (r *Repo) Lock(p string, user string, comment string, steal bool, currentRev *uint) (svn.Lock, error) {
+	// no commit can be done between checking HEAD and locking.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := uint(len(r.revs) - 1)
+	node := r.revs[n].root.lookup(p)
+	if node == nil {
+		return svn.Lock{}, svn.Error{
+			AprErr:  160013, // SVN_ERR_FS_NOT_FOUND
+			Message: fmt.Sprintf("Path '%s' doesn't exist in HEAD revision", absPath(p)),
+		}
+	}
+	if node.kind != svn.NodeFile {
+		return svn.Lock{}, svn.Error{
+			AprErr:  160017, // SVN_ERR_FS_NOT_FILE
+			Message: fmt.Sprintf("Lock failed: '%s' is not a file", absPath(p)),
+		}
+	}
+	if currentRev != nil && (*currentRev > n || node.createdRev > *currentRev) {
+		return svn.Lock{}, svn.Error{
+			AprErr:  160042, // SVN_ERR_FS_OUT_OF_DATE
+			Message: fmt.Sprintf("Lock failed: newer version of '%s' exists", absPath(p)),
+		}
+	}
+	return r.Locks.Lock(absPath(p), user, comment, steal)
+}
+
+// Driver returns a [svn.Driver] which serves the repository in-process,
+// with its root in rootURL, so that it can be used with [svn.Open].
+// For example:
+//
+//	svn.RegisterScheme("file", repo.Driver("file:///srv/repo"))
+func (r *Repo) Driver(rootURL string) svn.Driver {
+	return svn.ServerDriver(func(*url.URL) (*svn.Server, error) {
+		return r.NewServer(rootURL), nil
+	})
+}
+
+// NewServer returns a [svn.Server] which serves the repository
+// returned by [Repo.Repository], with its root in rootURL.
+func (r *Repo) NewServer(rootURL string) *svn.Server {
+	return &svn.Server{Repository: r.Repository(rootURL)}
+}
+
+// splitPath returns the names of the components of a relative path.
+func splitPath(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// absPath converts a path relative to the root of the repository
+// into an absolute one.
+func absPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+func sortedNames(n *node) []string {
+	names := make([]string, 0, len(n.entries))
+	for name := range n.entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func md5sum(b []byte) string {
+	return fmt.Sprintf("%x", md5.Sum(b))
+}
+
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,434 @@
+package memrepo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cespedes/svn"
+	"github.com/cespedes/svn/svndiff"
+)
+
+// putFile returns a commit function which sets the contents
+// of a file in the root directory, adding it if needed.
+func putFile(name string, base uint, add bool, contents string, props svn.Props) func(svn.Editor) error {
+	return func(e svn.Editor) error {
+		if err := e.OpenRoot(&base, "d"); err != nil {
+			return err
+		}
+		var err error
+		if add {
+			err = e.AddFile(name, "d", "f", "", 0)
+		} else {
+			err = e.OpenFile(name, "d", "f", &base)
+		}
+		if err != nil {
+			return err
+		}
+		for prop, value := range props {
+			if err := e.ChangeFileProp("f", prop, &value); err != nil {
+				return err
+			}
+		}
+		w, err := e.ApplyTextDelta("f", "")
+		if err != nil {
+			return err
+		}
+		w.Write(svndiff.Diff(nil, []byte(contents), 0))
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if err := e.CloseFile("f", ""); err != nil {
+			return err
+		}
+		return e.CloseDir("d")
+	}
+}
+
+func TestCommit(t *testing.T) {
+	r := New()
+	info, err := r.Commit("alice", svn.Props{svn.PropRevLog: "first"}, nil, false,
+		func(e svn.Editor) error {
+			e.OpenRoot(nil, "d0")
+			e.AddDir("dir", "d0", "d1", "", 0)
+			e.CloseDir("d1")
+			return putFile("a.txt", 0, true, "hello\n", nil)(e)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Rev != 1 || info.Author != "alice" {
+		t.Errorf("commit info: %+v", info)
+	}
+	if _, err := r.Commit("alice", nil, nil, false, putFile("a.txt", 1, false, "hello, world\n", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	rev1 := uint(1)
+	_, _, contents, err := r.GetFile("a.txt", &rev1)
+	if err != nil || string(contents) != "hello\n" {
+		t.Errorf("a.txt@1: %q, %v", contents, err)
+	}
+	stat, _ := r.Stat("a.txt", nil)
+	if stat.CreatedRev != 2 || stat.Size != 13 || stat.LastAuthor != "alice" {
+		t.Errorf("stat a.txt: %+v", stat)
+	}
+	if stat, _ := r.Stat("dir", nil); stat.CreatedRev != 1 {
+		t.Errorf("stat dir: %+v", stat)
+	}
+	if kind, _ := r.CheckPath("nothing", nil); kind != svn.NodeNone {
+		t.Errorf("check-path nothing: %s", kind)
+	}
+
+	// a change based on an old revision is out of date:
+	_, err = r.Commit("bob", nil, nil, false, putFile("a.txt", 1, false, "bye\n", nil))
+	if e, ok := err.(svn.Error); !ok || e.AprErr != 160028 {
+		t.Errorf("out of date commit: got %v", err)
+	}
+
+	entries, err := r.Log(nil, 2, 0, true)
+	if err != nil || len(entries) != 3 || entries[0].Changed[0].Path != "/a.txt" || entries[0].Changed[0].Mode != "M" {
+		t.Errorf("log: %+v, %v", entries, err)
+	}
+}
+
+func TestCommitLocks(t *testing.T) {
+	r := New()
+	r.EnforceNeedsLock = true
+	_, err := r.Commit("alice", nil, nil, false, putFile("art.psd", 0, true, "v1", svn.Props{svn.PropNeedsLock: "*"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Commit("alice", nil, nil, false, putFile("art.psd", 1, false, "v2", nil))
+	if e, ok := err.(svn.Error); !ok || e.AprErr != 160036 {
+		t.Errorf("commit without lock: got %v", err)
+	}
+
+	lock, err := r.Lock("art.psd", "alice", "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Commit("bob", nil, map[string]string{"/art.psd": lock.Token}, false, putFile("art.psd", 1, false, "v2", nil))
+	if e, ok := err.(svn.Error); !ok || e.AprErr != 160039 {
+		t.Errorf("commit with another user's lock: got %v", err)
+	}
+	_, err = r.Commit("alice", nil, map[string]string{"/art.psd": lock.Token}, false, putFile("art.psd", 1, false, "v2", nil))
+	if err != nil {
+		t.Errorf("commit with lock: %v", err)
+	}
+	if r.Locks.GetLock("/art.psd") != nil {
+		t.Errorf("lock not released after commit")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	r := New()
+	steps := []func(svn.Editor) error{
+		func(e svn.Editor) error {
+			e.OpenRoot(nil, "r")
+			e.AddDir("trunk", "r", "t", "", 0)
+			e.AddDir("branches", "r", "b", "", 0)
+			return nil
+		},
+		putFile("trunk/a.txt", 1, true, "one\n", nil),
+		func(e svn.Editor) error {
+			e.OpenRoot(nil, "r")
+			e.OpenDir("branches", "r", "b", nil)
+			return e.AddDir("branches/b1", "b", "b1", "/trunk", 2)
+		},
+		putFile("trunk/a.txt", 3, false, "two\n", nil),
+		func(e svn.Editor) error {
+			e.OpenRoot(nil, "r")
+			e.OpenDir("branches", "r", "b", nil)
+			e.OpenDir("branches/b1", "b", "b1", nil)
+			value := "/trunk:4"
+			return e.ChangeDirProp("b1", svn.PropMergeinfo, &value)
+		},
+	}
+	for i, step := range steps {
+		if info, err := r.Commit("alice", nil, nil, false, step); err != nil || info.Rev != uint(i+1) {
+			t.Fatalf("commit %d: %+v %v", i+1, info, err)
+		}
+	}
+
+	segments, err := r.GetLocationSegments("branches/b1/a.txt", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []svn.LocationSegment{
+		{RangeStart: 3, RangeEnd: 5, Path: "branches/b1/a.txt"},
+		{RangeStart: 2, RangeEnd: 2, Path: "trunk/a.txt"},
+	}
+	if !slices.Equal(segments, want) {
+		t.Errorf("segments: want %v got %v", want, segments)
+	}
+	segments, _ = r.GetLocationSegments("branches/b1", nil, nil, nil)
+	want = []svn.LocationSegment{
+		{RangeStart: 3, RangeEnd: 5, Path: "branches/b1"},
+		{RangeStart: 1, RangeEnd: 2, Path: "trunk"},
+	}
+	if !slices.Equal(segments, want) {
+		t.Errorf("segments: want %v got %v", want, segments)
+	}
+
+	catalog, err := r.GetMergeinfo([]string{"branches/b1/a.txt"}, nil, "inherited", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := catalog["branches/b1/a.txt"].String(); got != "/trunk/a.txt:4" {
+		t.Errorf("inherited mergeinfo: got %q", got)
+	}
+	catalog, _ = r.GetMergeinfo([]string{"branches"}, nil, "explicit", true)
+	if len(catalog) != 1 || catalog["branches/b1"].String() != "/trunk:4" {
+		t.Errorf("descendants mergeinfo: got %v", catalog)
+	}
+
+	// the same history, through a client:
+	c := connect(t, r, "svn://example.com/repo/branches")
+	locations, err := c.GetLocations("b1/a.txt", 5, []int{1, 2, 3, 5})
+	if err != nil || len(locations) != 3 || locations[2] != "/trunk/a.txt" || locations[5] != "/branches/b1/a.txt" {
+		t.Errorf("locations: %v, %v", locations, err)
+	}
+	var revs []string
+	err = c.GetFileRevs("b1/a.txt", nil, nil, false, func(rev svn.FileRev) error {
+		revs = append(revs, fmt.Sprintf("%s@%d %q", rev.Path, rev.Rev, rev.Contents))
+		return nil
+	})
+	if want := []string{`/trunk/a.txt@2 "one\n"`}; err != nil || !slices.Equal(revs, want) {
+		t.Errorf("file revs: %q, %v", revs, err)
+	}
+	if rev, err := c.GetDeletedRev("b1", 3, 5); err != nil || rev != svn.InvalidRevision {
+		t.Errorf("deleted rev of a live path: %d, %v", rev, err)
+	}
+	r.Commit("alice", nil, nil, false, func(e svn.Editor) error {
+		e.OpenRoot(nil, "r")
+		return e.DeleteEntry("branches/b1", nil, "r")
+	})
+	if rev, err := c.GetDeletedRev("b1/a.txt", 3, 6); err != nil || rev != 6 {
+		t.Errorf("deleted rev: %d, %v", rev, err)
+	}
+}
+
+// connect serves r in-process and returns a client for a session in url.
+func connect(t *testing.T, r *Repo, url string) *svn.Client {
+	client, server := net.Pipe()
+	go r.NewServer("svn://example.com/repo").Serve(server, server)
+	c, err := svn.NewClient(client, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientServer(t *testing.T) {
+	r := New()
+	r.Commit("alice", nil, nil, false, func(e svn.Editor) error {
+		e.OpenRoot(nil, "r")
+		return e.AddDir("trunk", "r", "t", "", 0)
+	})
+	r.Commit("alice", nil, nil, false, putFile("trunk/a.txt", 1, true, "hello\n", nil))
+
+	c := connect(t, r, "svn://example.com/repo/trunk")
+	if c.Info.URL != "svn://example.com/repo" || c.Info.UUID != r.UUID {
+		t.Errorf("repos-info: %+v", c.Info)
+	}
+	if !c.HasCapability("mergeinfo") || !c.HasCapability("list") {
+		t.Errorf("capabilities: %q", c.Info.Capabilities)
+	}
+	file, err := c.GetFile("a.txt", nil, false, true, false)
+	if err != nil || string(file.Contents) != "hello\n" || file.Rev != 2 {
+		t.Errorf("get-file: %+v, %v", file, err)
+	}
+
+	// commit from a session in the root:
+	root, err := c.OpenSession(c.Info.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := root.Commit("second", nil, nil, false, putFile("trunk/a.txt", 2, false, "bye\n", nil))
+	if err != nil || info.Rev != 3 || info.Author == "" {
+		t.Errorf("commit: %+v, %v", info, err)
+	}
+	entries, err := c.List("", nil, "immediates", []string{"kind", "created-rev"})
+	if err != nil || len(entries) != 2 || entries[1].Path != "/trunk/a.txt" || entries[1].CreatedRev != 3 {
+		t.Errorf("list: %+v, %v", entries, err)
+	}
+	logs, err := c.Log([]string{"a.txt"}, nil, nil, true)
+	if err != nil || len(logs) < 2 || logs[0].Message != "second" || logs[1].Rev != 2 {
+		t.Errorf("log: %+v, %v", logs, err)
+	}
+}
+
+func TestDriver(t *testing.T) {
+	r := New()
+	r.Commit("alice", nil, nil, false, putFile("a.txt", 0, true, "hello\n", nil))
+	svn.RegisterScheme("memrepo", r.Driver("memrepo:///srv/repo"))
+
+	s, err := svn.Open("memrepo:///srv/repo/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.ReposInfo().URL != "memrepo:///srv/repo" {
+		t.Errorf("repository root: %q", s.ReposInfo().URL)
+	}
+	if kind, err := s.CheckPath("", nil); err != nil || kind != svn.NodeFile {
+		t.Errorf("check-path: %s, %v", kind, err)
+	}
+	if _, err = svn.Open("nothing:///srv/repo"); err == nil {
+		t.Errorf("Open with an unknown scheme: expected error")
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	r := New()
+	r.Commit("alice", svn.Props{svn.PropRevLog: "first"}, nil, false, func(e svn.Editor) error {
+		e.OpenRoot(nil, "r")
+		return e.AddDir("trunk", "r", "t", "", 0)
+	})
+	r.Commit("alice", svn.Props{svn.PropRevLog: "second"}, nil, false,
+		putFile("trunk/a b.txt", 1, true, "hello\n", svn.Props{"svn:mime-type": "text/x-test"}))
+	r.Commit("bob", svn.Props{svn.PropRevLog: "third"}, nil, false, putFile("trunk/a b.txt", 2, false, "bye\n", nil))
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(&svn.HTTPHandler{
+		Root:      "/repo",
+		NewServer: func(*http.Request) (*svn.Server, error) { return r.NewServer(ts.URL + "/repo"), nil },
+	})
+	defer ts.Close()
+
+	s, err := svn.Open(ts.URL + "/repo/trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if info := s.ReposInfo(); info.URL != ts.URL+"/repo" || info.UUID != r.UUID {
+		t.Errorf("repos-info: %+v", info)
+	}
+	if rev, err := s.GetLatestRev(); err != nil || rev != 3 {
+		t.Errorf("get-latest-rev: %d, %v", rev, err)
+	}
+	if kind, err := s.CheckPath("nothing", nil); err != nil || kind != svn.NodeNone {
+		t.Errorf("check-path nothing: %s, %v", kind, err)
+	}
+	entries, err := s.List("", nil, "immediates", nil)
+	if err != nil || len(entries) != 2 || entries[1].Path != "/trunk/a b.txt" || entries[1].CreatedRev != 3 {
+		t.Errorf("list: %+v, %v", entries, err)
+	}
+	rev2 := 2
+	file, err := s.GetFile("a b.txt", &rev2, true, true, false)
+	if err != nil || string(file.Contents) != "hello\n" || file.Props["svn:mime-type"] != "text/x-test" {
+		t.Errorf("get-file: %+v, %v", file, err)
+	}
+	logs, err := s.Log([]string{"a b.txt"}, nil, nil, true)
+	if err != nil || len(logs) < 2 || logs[0].Message != "third" || logs[0].Author != "bob" || logs[1].Rev != 2 {
+		t.Errorf("log: %+v, %v", logs, err)
+	}
+
+	// browsers:
+	resp, err := http.Get(ts.URL + "/repo/trunk/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `<a href="a%20b.txt">a b.txt</a>`) {
+		t.Errorf("listing of trunk:\n%s", body)
+	}
+	resp, err = http.Get(ts.URL + "/repo/!svn/rvr/2/trunk/a%20b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello\n" || resp.Header.Get("Content-Type") != "text/x-test" {
+		t.Errorf("GET a b.txt@2: %q (%s)", body, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestClientFS(t *testing.T) {
+	r := New()
+	r.Commit("alice", nil, nil, false, func(e svn.Editor) error {
+		e.OpenRoot(nil, "r")
+		e.AddDir("trunk", "r", "t", "", 0)
+		return e.AddDir("trunk/doc", "t", "d", "", 0)
+	})
+	r.Commit("alice", nil, nil, false, putFile("trunk/a.txt", 1, true, "hello\n", nil))
+	r.Commit("alice", nil, nil, false, putFile("trunk/a.txt", 2, false, "bye\n", nil))
+
+	c := connect(t, r, "svn://example.com/repo/trunk")
+	fsys := c.FS(2)
+	if err := fstest.TestFS(fsys, "a.txt", "doc"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fs.ReadFile(fsys, "a.txt")
+	if err != nil || string(data) != "hello\n" {
+		t.Errorf("a.txt@2: %q, %v", data, err)
+	}
+	info, err := fs.Stat(fsys, "a.txt")
+	if err != nil || info.Size() != 6 || info.ModTime().IsZero() || info.Sys().(svn.Dirent).CreatedRev != 2 {
+		t.Errorf("stat a.txt@2: %+v, %v", info, err)
+	}
+	if _, err := fs.Stat(fsys, "nothing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("stat nothing: %v", err)
+	}
+	if data, _ := fs.ReadFile(c.FS(-1), "a.txt"); string(data) != "bye\n" {
+		t.Errorf("a.txt@HEAD: %q", data)
+	}
+}
+
+func TestSessionNotYetExisting(t *testing.T) {
+	r := New()
+	r.Commit("alice", nil, nil, false, func(e svn.Editor) error {
+		e.OpenRoot(nil, "r")
+		return e.AddDir("trunk", "r", "t", "", 0)
+	})
+
+	c := connect(t, r, "svn://example.com/repo/trunk/newdir")
+	if c.Info.URL != "svn://example.com/repo" {
+		t.Errorf("repos-info: %+v", c.Info)
+	}
+	if kind, err := c.CheckPath("", nil); err != nil || kind != svn.NodeNone {
+		t.Errorf("check-path: %s, %v", kind, err)
+	}
+
+	// create the directory from its parent, as "svn mkdir" does:
+	if err := c.Reparent("svn://example.com/repo/trunk"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Commit("mkdir", nil, nil, false, func(e svn.Editor) error {
+		e.OpenRoot(nil, "r")
+		return e.AddDir("newdir", "r", "d", "", 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reparent("svn://example.com/repo/trunk/newdir"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Commit("in newdir", nil, nil, false, putFile("a.txt", 2, true, "hello\n", nil)); err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]svn.NodeKind{"trunk/newdir/a.txt": svn.NodeFile, "a.txt": svn.NodeNone, "newdir": svn.NodeNone} {
+		if kind, err := r.CheckPath(p, nil); err != nil || kind != want {
+			t.Errorf("%s: want %s, got %s, %v", p, want, kind, err)
+		}
+	}
+
+	client, server := net.Pipe()
+	go r.NewServer("svn://example.com/repo").Serve(server, server)
+	_, err = svn.NewClient(client, "svn://example.com/other")
+	if e := (svn.Error{}); !errors.As(err, &e) || e.AprErr != 170000 {
+		t.Errorf("URL outside the repository: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package memrepo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cespedes/svn"
+	"github.com/cespedes/svn/mergeinfo"
+)
+
+// Repository returns the repository as an [svn.Repository], which can
+// be served by an [svn.Server], an [svn.MultiServer] or an
+// [svn.HTTPHandler].  It implements all the optional interfaces except
+// [svn.ReplayRepository] and [svn.UpdateRepository]: the replays and
+// updates are computed with [svn.TreeDelta].
+//
+// rootURL is the URL of the root of the repository: the sessions
+// must be opened in it or in one of its descendants, which need not
+// exist yet.
+func (r *Repo) Repository(rootURL string) svn.Repository {
+	return repository{r, strings.TrimSuffix(rootURL, "/")}
+}
+
+// repository is the svn.Repository returned by Repo.Repository.
+type repository struct {
+	r    *Repo
+	root string
+}
+
+var (
+	_ svn.ListRepository           = repository{}
+	_ svn.InheritedPropsRepository = repository{}
+	_ svn.HistoryRepository        = repository{}
+	_ svn.MergeinfoRepository      = repository{}
+	_ svn.WriteRepository          = repository{}
+	_ svn.LockRepository           = repository{}
+)
+
+func (repo repository) Open(sess *svn.ServerSession) (svn.ReposInfo, error) {
+	u := strings.TrimSuffix(sess.URL, "/")
+	if u != repo.root && !strings.HasPrefix(u, repo.root+"/") {
+		return svn.ReposInfo{}, svn.Error{
+			AprErr:  170000, // SVN_ERR_RA_ILLEGAL_URL
+			Message: fmt.Sprintf("'%s' is not in the repository '%s'", sess.URL, repo.root),
+		}
+	}
+	return svn.ReposInfo{
+		UUID:         repo.r.UUID,
+		URL:          repo.root,
+		Capabilities: []string{},
+	}, nil
+}
+
+func (repo repository) LatestRev(sess *svn.ServerSession) (uint, error) {
+	return repo.r.Youngest(), nil
+}
+
+func (repo repository) Stat(sess *svn.ServerSession, p string, rev *uint) (svn.Stat, error) {
+	d, err := repo.r.Stat(p, rev)
+	return svn.Stat{
+		Kind:        d.Kind,
+		Size:        d.Size,
+		HasProps:    d.HasProps,
+		CreatedRev:  d.CreatedRev,
+		CreatedDate: d.CreatedDate,
+		LastAuthor:  d.LastAuthor,
+	}, err
+}
+
+func (repo repository) CheckPath(sess *svn.ServerSession, p string, rev *uint) (svn.NodeKind, error) {
+	return repo.r.CheckPath(p, rev)
+}
+
+func (repo repository) GetFile(sess *svn.ServerSession, p string, rev *uint, wantProps bool, wantContents bool) (svn.File, io.Reader, error) {
+	n, props, contents, err := repo.r.GetFile(p, rev)
+	if err != nil {
+		return svn.File{}, nil, err
+	}
+	file := svn.File{
+		Rev:      n,
+		Checksum: md5sum(contents),
+		Size:     uint64(len(contents)),
+		Props:    props,
+	}
+	return file, bytes.NewReader(contents), nil
+}
+
+func (repo repository) GetDir(sess *svn.ServerSession, p string, rev *uint, wantProps bool, wantContents bool, fields []string) (svn.Dir, error) {
+	return repo.r.GetDir(p, rev)
+}
+
+func (repo repository) Log(sess *svn.ServerSession, paths []string, startRev uint, endRev uint, changedPaths bool) ([]svn.LogEntry, error) {
+	return repo.r.Log(paths, startRev, endRev, changedPaths)
+}
+
+func (repo repository) List(sess *svn.ServerSession, p string, rev *uint, depth string, fields []string, patterns []string) ([]svn.Dirent, error) {
+	return repo.r.List(p, rev, depth, patterns)
+}
+
+func (repo repository) GetIProps(sess *svn.ServerSession, p string, rev *uint) (svn.InheritedProps, error) {
+	return repo.r.GetIProps(p, rev)
+}
+
+func (repo repository) GetDeletedRev(sess *svn.ServerSession, p string, pegRev uint, endRev uint) (*uint, error) {
+	return repo.r.GetDeletedRev(p, pegRev, endRev)
+}
+
+func (repo repository) GetLocations(sess *svn.ServerSession, p string, pegRev uint, revs []uint) (map[uint]string, error) {
+	return repo.r.GetLocations(p, pegRev, revs)
+}
+
+func (repo repository) GetLocationSegments(sess *svn.ServerSession, p string, pegRev *uint, startRev *uint, endRev *uint) ([]svn.LocationSegment, error) {
+	return repo.r.GetLocationSegments(p, pegRev, startRev, endRev)
+}
+
+// GetFileRevs ignores includeMerged, as the merges are not tracked.
+func (repo repository) GetFileRevs(sess *svn.ServerSession, p string, startRev *uint, endRev *uint, includeMerged bool) ([]svn.FileRev, error) {
+	return repo.r.GetFileRevs(p, startRev, endRev)
+}
+
+func (repo repository) GetMergeinfo(sess *svn.ServerSession, paths []string, rev *uint, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error) {
+	return repo.r.GetMergeinfo(paths, rev, inherit, includeDescendants)
+}
+
+func (repo repository) Commit(sess *svn.ServerSession, revProps svn.Props, lockTokens map[string]string, keepLocks bool, edit func(svn.Editor) error) (svn.CommitInfo, error) {
+	tokens := make(map[string]string, len(lockTokens))
+	for p, token := range lockTokens {
+		tokens[absPath(p)] = token
+	}
+	return repo.r.commit(sess.Root, sess.Path(""), sess.User, revProps, tokens, keepLocks, edit)
+}
+
+func (repo repository) Lock(sess *svn.ServerSession, p string, comment string, steal bool, currentRev *uint) (svn.Lock, error) {
+	return repo.r.Lock(p, sess.User, comment, steal, currentRev)
+}
+
+func (repo repository) Unlock(sess *svn.ServerSession, p string, token string, breakLock bool) error {
+	return repo.r.Locks.Unlock(absPath(p), sess.User, token, breakLock)
+}
+
+func (repo repository) GetLock(sess *svn.ServerSession, p string) (*svn.Lock, error) {
+	return repo.r.Locks.GetLock(absPath(p)), nil
+}
+
+func (repo repository) GetLocks(sess *svn.ServerSession, p string, depth string) ([]svn.Lock, error) {
+	return repo.r.Locks.GetLocks(absPath(p), depth), nil
+}
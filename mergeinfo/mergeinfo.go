@@ -0,0 +1,317 @@
+// Package mergeinfo parses, formats and operates on the values
+// of the "svn:mergeinfo" property, which record the revisions
+// merged into a path.
+//
+// A mergeinfo value has one line for every merge source,
+// with its path and a list of revision ranges:
+//
+//	/trunk:3-7,9,12-15*
+//	/branches/foo:20
+//
+// A "*" marks a non-inheritable range: it applies to the path
+// itself, but not to its children.
+package mergeinfo
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Range is a range of revisions, from Start to End (both included).
+type Range struct {
+	Start          uint
+	End            uint
+	NonInheritable bool
+}
+
+func (r Range) String() string {
+	s := strconv.FormatUint(uint64(r.Start), 10)
+	if r.End != r.Start {
+		s += "-" + strconv.FormatUint(uint64(r.End), 10)
+	}
+	if r.NonInheritable {
+		s += "*"
+	}
+	return s
+}
+
+// RangeList is a sorted list of non-overlapping revision ranges.
+// The functions in this package always return normalized lists, in which
+// adjacent ranges with the same inheritability are joined.
+type RangeList []Range
+
+func (rl RangeList) String() string {
+	parts := make([]string, len(rl))
+	for i, r := range rl {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Contains reports whether rev is in one of the ranges.
+func (rl RangeList) Contains(rev uint) bool {
+	for _, r := range rl {
+		if r.Start <= rev && rev <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Revisions returns all the revisions in the ranges, in order.
+func (rl RangeList) Revisions() []uint {
+	var revs []uint
+	for _, r := range rl {
+		for rev := r.Start; rev <= r.End; rev++ {
+			revs = append(revs, rev)
+		}
+	}
+	return revs
+}
+
+// Inheritable returns the inheritable ranges of rl.
+func (rl RangeList) Inheritable() RangeList {
+	var result RangeList
+	for _, r := range rl {
+		if !r.NonInheritable {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// ParseRangeList parses a comma-separated list of revision ranges,
+// such as "3-7,9,12-15*".
+func ParseRangeList(s string) (RangeList, error) {
+	var rl RangeList
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		var r Range
+		part, r.NonInheritable = strings.CutSuffix(part, "*")
+		start, end, isRange := strings.Cut(part, "-")
+		first, err := strconv.ParseUint(start, 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("mergeinfo: invalid revision range %q", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.ParseUint(end, 10, 0); err != nil {
+				return nil, fmt.Errorf("mergeinfo: invalid revision range %q", part)
+			}
+		}
+		if first == 0 || last < first {
+			return nil, fmt.Errorf("mergeinfo: invalid revision range %q", part)
+		}
+		r.Start, r.End = uint(first), uint(last)
+		rl = append(rl, r)
+	}
+	slices.SortFunc(rl, func(a, b Range) int {
+		return int(a.Start) - int(b.Start)
+	})
+	for i := 1; i < len(rl); i++ {
+		if rl[i].Start <= rl[i-1].End {
+			return nil, fmt.Errorf("mergeinfo: overlapping revision ranges %s and %s", rl[i-1], rl[i])
+		}
+	}
+	return normalize(rl), nil
+}
+
+// normalize joins the adjacent ranges with the same inheritability
+// in a sorted list of non-overlapping ranges.
+func normalize(rl RangeList) RangeList {
+	var result RangeList
+	for _, r := range rl {
+		if n := len(result); n > 0 && result[n-1].End+1 == r.Start && result[n-1].NonInheritable == r.NonInheritable {
+			result[n-1].End = r.End
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// combine computes a new list of ranges from two lists: for every
+// revision, keep reports if it is in the result, given the ranges
+// of a and b which contain it (or nil).
+func combine(a RangeList, b RangeList, keep func(ra *Range, rb *Range) (bool, bool)) RangeList {
+	// the boundaries of the ranges split the revisions in segments
+	// in which all the revisions are in the same ranges.
+	var bounds []uint
+	for _, r := range append(slices.Clone(a), b...) {
+		bounds = append(bounds, r.Start, r.End+1)
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+	find := func(rl RangeList, rev uint) *Range {
+		for i := range rl {
+			if rl[i].Start <= rev && rev <= rl[i].End {
+				return &rl[i]
+			}
+		}
+		return nil
+	}
+	var result RangeList
+	for i := 0; i+1 < len(bounds); i++ {
+		start := bounds[i]
+		ok, nonInheritable := keep(find(a, start), find(b, start))
+		if ok {
+			result = append(result, Range{Start: start, End: bounds[i+1] - 1, NonInheritable: nonInheritable})
+		}
+	}
+	return normalize(result)
+}
+
+// Union returns the revisions in a or b.  A revision is
+// inheritable if it is inheritable in any of them.
+func (a RangeList) Union(b RangeList) RangeList {
+	return combine(a, b, func(ra *Range, rb *Range) (bool, bool) {
+		switch {
+		case ra != nil && rb != nil:
+			return true, ra.NonInheritable && rb.NonInheritable
+		case ra != nil:
+			return true, ra.NonInheritable
+		case rb != nil:
+			return true, rb.NonInheritable
+		}
+		return false, false
+	})
+}
+
+// Intersect returns the revisions both in a and b.  A revision is
+// inheritable if it is inheritable in both of them.
+func (a RangeList) Intersect(b RangeList) RangeList {
+	return combine(a, b, func(ra *Range, rb *Range) (bool, bool) {
+		if ra == nil || rb == nil {
+			return false, false
+		}
+		return true, ra.NonInheritable || rb.NonInheritable
+	})
+}
+
+// Difference returns the revisions in a which are not in b.
+func (a RangeList) Difference(b RangeList) RangeList {
+	return combine(a, b, func(ra *Range, rb *Range) (bool, bool) {
+		if ra == nil || rb != nil {
+			return false, false
+		}
+		return true, ra.NonInheritable
+	})
+}
+
+// Mergeinfo is the list of revisions merged from every source path.
+type Mergeinfo map[string]RangeList
+
+// Parse parses the value of a "svn:mergeinfo" property.
+func Parse(s string) (Mergeinfo, error) {
+	m := Mergeinfo{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		i := strings.LastIndexByte(line, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("mergeinfo: missing source path in %q", line)
+		}
+		rl, err := ParseRangeList(line[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		path := line[:i]
+		m[path] = m[path].Union(rl)
+	}
+	return m, nil
+}
+
+// Paths returns the source paths, sorted.
+func (m Mergeinfo) Paths() []string {
+	paths := make([]string, 0, len(m))
+	for path, rl := range m {
+		if len(rl) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// String returns the mergeinfo as the value of a "svn:mergeinfo" property.
+func (m Mergeinfo) String() string {
+	var b strings.Builder
+	for i, path := range m.Paths() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(path)
+		b.WriteByte(':')
+		b.WriteString(m[path].String())
+	}
+	return b.String()
+}
+
+// Equal reports whether a and b have the same ranges.
+func (a Mergeinfo) Equal(b Mergeinfo) bool {
+	return a.String() == b.String()
+}
+
+// apply returns the result of applying an operation to the ranges
+// of every path in a or b.
+func apply(a Mergeinfo, b Mergeinfo, op func(RangeList, RangeList) RangeList) Mergeinfo {
+	result := Mergeinfo{}
+	for path := range a {
+		if rl := op(a[path], b[path]); len(rl) > 0 {
+			result[path] = rl
+		}
+	}
+	for path := range b {
+		if _, ok := a[path]; ok {
+			continue
+		}
+		if rl := op(nil, b[path]); len(rl) > 0 {
+			result[path] = rl
+		}
+	}
+	return result
+}
+
+// Union returns the revisions merged in a or b.
+func (a Mergeinfo) Union(b Mergeinfo) Mergeinfo {
+	return apply(a, b, RangeList.Union)
+}
+
+// Intersect returns the revisions merged both in a and b.
+func (a Mergeinfo) Intersect(b Mergeinfo) Mergeinfo {
+	return apply(a, b, RangeList.Intersect)
+}
+
+// Difference returns the revisions merged in a but not in b.
+func (a Mergeinfo) Difference(b Mergeinfo) Mergeinfo {
+	return apply(a, b, RangeList.Difference)
+}
+
+// Inherit returns the mergeinfo that a child of a path, at relPath,
+// inherits from it: the inheritable ranges, with relPath appended
+// to the source paths.
+func (m Mergeinfo) Inherit(relPath string) Mergeinfo {
+	relPath = strings.Trim(relPath, "/")
+	result := Mergeinfo{}
+	for path, rl := range m {
+		if rl = rl.Inheritable(); len(rl) == 0 {
+			continue
+		}
+		if relPath != "" {
+			path = strings.TrimSuffix(path, "/") + "/" + relPath
+		}
+		result[path] = rl
+	}
+	return result
+}
+
+// Elides reports whether the mergeinfo of a child, at relPath from its
+// parent, is redundant: that is, whether it is the same that it would
+// inherit from the parent, so it can be removed.
+func Elides(child Mergeinfo, parent Mergeinfo, relPath string) bool {
+	return child.Equal(parent.Inherit(relPath))
+}
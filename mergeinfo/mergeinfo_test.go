@@ -0,0 +1,82 @@
+package mergeinfo
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{"/trunk:3-7,9,12-15*", "/trunk:3-7,9,12-15*", false},
+		{"/trunk:9,3-5,6-7\n/branches/foo:20\n", "/branches/foo:20\n/trunk:3-7,9", false},
+		{"/trunk:5-6*,7*", "/trunk:5-7*", false},
+		{"/trunk:5,6*", "/trunk:5,6*", false},
+		{"", "", false},
+		{"/trunk:3-7,5", "", true},
+		{"/trunk:7-3", "", true},
+		{"/trunk:0", "", true},
+		{"/trunk:", "", true},
+		{"3-7", "", true},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Parse(%q): expected error, got %s", tt.in, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := m.String(); got != tt.want {
+			t.Errorf("Parse(%q): want %q got %q", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestRangeListOps(t *testing.T) {
+	tests := []struct {
+		a, b                            string
+		union, intersection, difference string
+	}{
+		{"1-10", "5-15", "1-15", "5-10", "1-4"},
+		{"1-3,8-9", "4-7", "1-9", "", "1-3,8-9"},
+		{"1-10*", "5-6", "1-4*,5-6,7-10*", "5-6*", "1-4*,7-10*"},
+		{"5", "5*", "5", "5*", ""},
+		{"2-4,6-8", "1,3,7,9", "1-4,6-9", "3,7", "2,4,6,8"},
+	}
+	for _, tt := range tests {
+		a, _ := ParseRangeList(tt.a)
+		b, _ := ParseRangeList(tt.b)
+		if got := a.Union(b).String(); got != tt.union {
+			t.Errorf("%s union %s: want %q got %q", tt.a, tt.b, tt.union, got)
+		}
+		if got := a.Intersect(b).String(); got != tt.intersection {
+			t.Errorf("%s intersect %s: want %q got %q", tt.a, tt.b, tt.intersection, got)
+		}
+		if got := a.Difference(b).String(); got != tt.difference {
+			t.Errorf("%s minus %s: want %q got %q", tt.a, tt.b, tt.difference, got)
+		}
+	}
+}
+
+func TestElides(t *testing.T) {
+	parent, _ := Parse("/trunk:1-10\n/branches/x:4*")
+	child, _ := Parse("/trunk/lib:1-10")
+	if !Elides(child, parent, "lib") {
+		t.Errorf("%q should elide to %q", child, parent)
+	}
+	child, _ = Parse("/trunk/lib:1-11")
+	if Elides(child, parent, "lib") {
+		t.Errorf("%q should not elide to %q", child, parent)
+	}
+
+	m, _ := Parse("/trunk:1-10\n/branches/x:4")
+	merged, _ := Parse("/trunk:3-5")
+	if got := m.Difference(merged).String(); got != "/branches/x:4\n/trunk:1-2,6-10" {
+		t.Errorf("difference: got %q", got)
+	}
+}
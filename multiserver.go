@@ -0,0 +1,100 @@
+package svn
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// A MultiServer serves several repositories, as "svnserve -r": the
+// repository of every connection is chosen with the URL sent by the
+// client, which can also reparent its session to another repository
+// (see [Client.Reparent]).
+//
+// Every repository is served by its own [Server], so the authentication
+// and authorization of each one are independent: the client is
+// authenticated by the Authenticate function of the Server of the
+// repository it connects to, and again, with the same "auth-response",
+// by the one of every repository it reparents to, which can reject it
+// or give it another user.  Greet and the Open method of the Repository
+// are also called on every reparent, and can reject the client as well.
+//
+// As the repository is not known when the server greets the client,
+// the capabilities which depend on it are sent with the information
+// about the repository.
+type MultiServer struct {
+	// NewServer returns a new Server for the repository whose root
+	// has the given path in the URLs (such as "project" for
+	// "svn://host/project/trunk"), or nil if there is none.  It is
+	// called with the path of the URL sent by the client, and then
+	// with its parents until it returns a Server.
+	//
+	// If the Server has neither Greet nor Repository, the URL of the
+	// root of the repository is the one of that path.
+	NewServer func(name string) (*Server, error)
+	// Trace, if not nil, is called with every Item sent or received
+	// by the server (see [TraceWriter]).
+	Trace func(dir Direction, item Item)
+}
+
+// Serve serves a connection, as [Server.Serve].
+func (m *MultiServer) Serve(r io.Reader, w io.Writer) error {
+	conn := &conn{
+		r:     r,
+		w:     w,
+		trace: m.Trace,
+	}
+	capabilities := baseCapabilities()
+	g, err := readGreeting(conn, capabilities)
+	if err != nil {
+		return err
+	}
+	s, err := m.server(g.URL)
+	if err != nil {
+		conn.WriteFailure(err)
+		return err
+	}
+	if err = s.start(conn, g, capabilities); err != nil {
+		return err
+	}
+	return serveCommands(conn, s)
+}
+
+// server returns the Server for a connection to the repository of a URL
+// (see Server.connection), or an error if there is none.
+func (m *MultiServer) server(rawURL string) (*Server, error) {
+	notFound := Error{
+		AprErr:  210005, // SVN_ERR_RA_SVN_REPOS_NOT_FOUND
+		Message: fmt.Sprintf("No repository found in '%s'", rawURL),
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, notFound
+	}
+	name := strings.Trim(path.Clean("/"+u.Path), "/")
+	for {
+		s, err := m.NewServer(name)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			c := s.connection()
+			if c.Greet == nil && c.ReposInfo.URL == "" {
+				root := *u
+				root.Path, root.RawPath = "/"+name, ""
+				c.ReposInfo.URL = strings.TrimSuffix(root.String(), "/")
+			}
+			c.resolve = m.server
+			return c, nil
+		}
+		if name == "" {
+			return nil, notFound
+		}
+		name = path.Dir(name)
+		if name == "." {
+			name = ""
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package svn
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMultiServer(t *testing.T) {
+	var names []string
+	m := &MultiServer{
+		NewServer: func(name string) (*Server, error) {
+			names = append(names, name)
+			switch name {
+			case "a":
+				return NewFSServer(fstest.MapFS{"trunk/a.txt": {Data: []byte("a\n")}}, "svn://example.com/a"), nil
+			case "group/b":
+				return &Server{GetLatestRev: func() (uint, error) { return 7, nil }}, nil
+			}
+			return nil, nil
+		},
+	}
+	connect := func(url string) (*Client, error) {
+		client, server := net.Pipe()
+		go m.Serve(server, server)
+		return NewClient(client, url)
+	}
+
+	var svnErr Error
+	if _, err := connect("svn://example.com/group/c/trunk"); !errors.As(err, &svnErr) || svnErr.AprErr != 210005 {
+		t.Errorf("unknown repository: got %v", err)
+	}
+
+	names = nil
+	c, err := connect("svn://example.com/group/b/trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Info.URL != "svn://example.com/group/b" || len(names) != 2 || names[1] != "group/b" {
+		t.Errorf("repository of group/b/trunk: %q, tried %q", c.Info.URL, names)
+	}
+	if rev, err := c.GetLatestRev(); err != nil || rev != 7 {
+		t.Errorf("get-latest-rev: %d, %v", rev, err)
+	}
+
+	// a reparent to another repository:
+	if err = c.Reparent("svn://example.com/a/trunk"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Info.URL != "svn://example.com/a" || !c.HasCapability("list") || c.URL() != "svn://example.com/a/trunk" {
+		t.Errorf("after reparent to a/trunk: %+v in %s", c.Info, c.URL())
+	}
+	if file, err := c.GetFile("a.txt", nil, false, true, false); err != nil || string(file.Contents) != "a\n" {
+		t.Errorf("get-file after reparent: %+v, %v", file, err)
+	}
+	if err := c.Reparent("svn://example.com/nothing"); !errors.As(err, &svnErr) || svnErr.AprErr != 210005 {
+		t.Errorf("reparent to an unknown repository: got %v", err)
+	}
+	if err := c.Reparent("svn://example.com/group/b"); err != nil || c.Info.URL != "svn://example.com/group/b" {
+		t.Errorf("reparent back to group/b: %+v, %v", c.Info, err)
+	}
+	if rev, err := c.GetLatestRev(); err != nil || rev != 7 {
+		t.Errorf("get-latest-rev after reparent back: %d, %v", rev, err)
+	}
+
+	// a Server with a single repository rejects the others:
+	c3 := serverClient(t, NewFSServer(fstest.MapFS{}, "svn://example.com/repo"), "svn://example.com/repo")
+	if err := c3.Reparent("svn://example.com/other"); !errors.As(err, &svnErr) || svnErr.AprErr != 170000 {
+		t.Errorf("reparent to another repository in a Server: got %v", err)
+	}
+
+	// the capabilities of the repository are sent after the greeting:
+	c2, err := connect("svn://example.com/a/trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if c2.Info.URL != "svn://example.com/a" || !c2.HasCapability("list") || !c2.HasCapability("depth") {
+		t.Errorf("repository a: %+v", c2.Info)
+	}
+}
+
+// rootRepo is a pathRepo whose root is in another URL.
+type rootRepo struct {
+	*pathRepo
+	root string
+}
+
+func (r rootRepo) Open(sess *ServerSession) (ReposInfo, error) {
+	r.pathRepo.Open(sess)
+	return ReposInfo{UUID: defaultUUID, URL: r.root}, nil
+}
+
+func TestMultiServerAuthenticate(t *testing.T) {
+	public, private := &pathRepo{}, &pathRepo{}
+	m := &MultiServer{
+		NewServer: func(name string) (*Server, error) {
+			switch name {
+			case "public":
+				return &Server{
+					Repository:   rootRepo{public, "svn://example.com/public"},
+					Authenticate: func(mech, token string) (string, error) { return "", nil },
+				}, nil
+			case "private":
+				return &Server{
+					Repository: rootRepo{private, "svn://example.com/private"},
+					Authenticate: func(mech, token string) (string, error) {
+						if mech != "EXTERNAL" {
+							return "", Error{AprErr: 170001, Message: "Authentication required"}
+						}
+						return "alice", nil
+					},
+				}, nil
+			case "closed":
+				return &Server{
+					Repository: rootRepo{&pathRepo{}, "svn://example.com/closed"},
+					Authenticate: func(mech, token string) (string, error) {
+						return "", Error{AprErr: 170001, Message: "Not authorized"}
+					},
+				}, nil
+			}
+			return nil, nil
+		},
+	}
+	client, server := net.Pipe()
+	go m.Serve(server, server)
+	c, err := NewClient(client, "svn://example.com/public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err = c.GetLatestRev(); err != nil || public.sess.User != "" {
+		t.Errorf("user in public: %q, %v", public.sess.User, err)
+	}
+
+	// every repository authenticates the client again:
+	if err = c.Reparent("svn://example.com/private/trunk"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.GetLatestRev(); err != nil || private.sess.User != "alice" {
+		t.Errorf("user in private: %q, %v", private.sess.User, err)
+	}
+	var svnErr Error
+	if err = c.Reparent("svn://example.com/closed"); !errors.As(err, &svnErr) || svnErr.AprErr != 170001 {
+		t.Errorf("reparent to a closed repository: %v", err)
+	}
+	if _, err = c.GetLatestRev(); err != nil || c.Info.URL != "svn://example.com/private" {
+		t.Errorf("after a rejected reparent: %+v, %v", c.Info, err)
+	}
+}
@@ -0,0 +1,338 @@
+package svn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/cespedes/svn/mergeinfo"
+)
+
+// A Pool is a set of sessions to the same URL, which can be used
+// concurrently by several goroutines.  Every call to one of its methods
+// uses one of the sessions, connecting a new one if none is available,
+// up to the size of the pool; after that, calls wait for a session
+// to be available.
+//
+// The methods of a Pool are the same as those of a [Client]
+// (except the ones which change or open sessions).
+// Sessions which fail with network or protocol errors are closed
+// and discarded; errors sent by the server (of type [Error])
+// do not affect the sessions.
+type Pool struct {
+	*pool
+	ctx context.Context
+}
+
+type pool struct {
+	url  string
+	sem  chan struct{}
+	opts []Option
+	dial func(ctx context.Context, address string, opts ...Option) (*Client, error)
+
+	mu     sync.Mutex
+	idle   []*Client
+	closed bool
+}
+
+// NewPool returns a pool of at most size sessions to address,
+// connected with the given options.  No connection is made until needed.
+func NewPool(address string, size int, opts ...Option) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{pool: &pool{
+		url:  address,
+		sem:  make(chan struct{}, size),
+		opts: opts,
+		dial: ConnectContext,
+	}}
+}
+
+// WithContext returns a copy of the pool which uses ctx for all
+// its operations, including waiting for a session and connecting it.
+// See [Client.WithContext].
+func (p *Pool) WithContext(ctx context.Context) *Pool {
+	if ctx == nil {
+		panic("svn: nil context")
+	}
+	return &Pool{pool: p.pool, ctx: ctx}
+}
+
+// Close closes all the idle sessions.  The sessions in use are closed
+// when their calls finish.  The pool cannot be used after calling Close.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	var err error
+	for _, c := range idle {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (p *Pool) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// get waits for a session to be available and returns it.
+func (p *Pool) get() (*Client, error) {
+	ctx := p.context()
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.sem
+		return nil, ErrClosed
+	}
+	if n := len(p.idle); n > 0 {
+		c := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return c, nil
+	}
+	p.mu.Unlock()
+	c, err := p.dial(ctx, p.url, p.opts...)
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+	return c, nil
+}
+
+// put returns a session to the pool after a call which returned err,
+// closing it if it is no longer usable.
+func (p *Pool) put(c *Client, err error) {
+	defer func() { <-p.sem }()
+	if err != nil && (c.failed() != nil || !errors.As(err, new(Error))) {
+		c.Close()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		c.Close()
+		return
+	}
+	p.idle = append(p.idle, c)
+}
+
+// poolDo runs fn with a session from the pool.
+func poolDo[T any](p *Pool, fn func(c *Client) (T, error)) (T, error) {
+	c, err := p.get()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	session := c
+	if p.ctx != nil {
+		session = c.WithContext(p.ctx)
+	}
+	result, err := fn(session)
+	p.put(c, err)
+	return result, err
+}
+
+// poolDo0 is like poolDo, for functions which return just an error.
+func poolDo0(p *Pool, fn func(c *Client) error) error {
+	_, err := poolDo(p, func(c *Client) (struct{}, error) {
+		return struct{}{}, fn(c)
+	})
+	return err
+}
+
+// URL returns the URL of the sessions in the pool.
+func (p *Pool) URL() string {
+	return p.url
+}
+
+// HasCapability reports whether the server or the repository
+// announced the given capability.  It returns false if it cannot
+// connect to the server.
+func (p *Pool) HasCapability(capability string) bool {
+	ok, _ := poolDo(p, func(c *Client) (bool, error) {
+		return c.HasCapability(capability), nil
+	})
+	return ok
+}
+
+// GetLatestRev calls [Client.GetLatestRev] using a session from the pool.
+func (p *Pool) GetLatestRev() (int, error) {
+	return poolDo(p, func(c *Client) (int, error) {
+		return c.GetLatestRev()
+	})
+}
+
+// Stat calls [Client.Stat] using a session from the pool.
+func (p *Pool) Stat(path string, rev *int) (Stat, error) {
+	return poolDo(p, func(c *Client) (Stat, error) {
+		return c.Stat(path, rev)
+	})
+}
+
+// CheckPath calls [Client.CheckPath] using a session from the pool.
+func (p *Pool) CheckPath(path string, rev *int) (NodeKind, error) {
+	return poolDo(p, func(c *Client) (NodeKind, error) {
+		return c.CheckPath(path, rev)
+	})
+}
+
+// GetDeletedRev calls [Client.GetDeletedRev] using a session from the pool.
+func (p *Pool) GetDeletedRev(path string, pegRev int, endRev int) (int, error) {
+	return poolDo(p, func(c *Client) (int, error) {
+		return c.GetDeletedRev(path, pegRev, endRev)
+	})
+}
+
+// List calls [Client.List] using a session from the pool.
+func (p *Pool) List(path string, rev *int, depth string, fields []string) ([]Dirent, error) {
+	return poolDo(p, func(c *Client) ([]Dirent, error) {
+		return c.List(path, rev, depth, fields)
+	})
+}
+
+// GetFile calls [Client.GetFile] using a session from the pool.
+func (p *Pool) GetFile(path string, rev *int, wantProps bool, wantContent bool, wantIProps bool) (File, error) {
+	return poolDo(p, func(c *Client) (File, error) {
+		return c.GetFile(path, rev, wantProps, wantContent, wantIProps)
+	})
+}
+
+// GetFileTo calls [Client.GetFileTo] using a session from the pool.
+func (p *Pool) GetFileTo(path string, rev *int, w io.Writer) (File, error) {
+	return poolDo(p, func(c *Client) (File, error) {
+		return c.GetFileTo(path, rev, w)
+	})
+}
+
+// GetDir calls [Client.GetDir] using a session from the pool.
+func (p *Pool) GetDir(path string, rev *int, wantProps bool, wantContents bool, fields []string, wantIProps bool) (Dir, error) {
+	return poolDo(p, func(c *Client) (Dir, error) {
+		return c.GetDir(path, rev, wantProps, wantContents, fields, wantIProps)
+	})
+}
+
+// GetIProps calls [Client.GetIProps] using a session from the pool.
+func (p *Pool) GetIProps(path string, rev *int) (InheritedProps, error) {
+	return poolDo(p, func(c *Client) (InheritedProps, error) {
+		return c.GetIProps(path, rev)
+	})
+}
+
+// GetMergeinfo calls [Client.GetMergeinfo] using a session from the pool.
+func (p *Pool) GetMergeinfo(paths []string, rev *int, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error) {
+	return poolDo(p, func(c *Client) (map[string]mergeinfo.Mergeinfo, error) {
+		return c.GetMergeinfo(paths, rev, inherit, includeDescendants)
+	})
+}
+
+// Log calls [Client.Log] using a session from the pool.
+func (p *Pool) Log(paths []string, startRev *int, endRev *int, changedPaths bool) ([]LogEntry, error) {
+	return poolDo(p, func(c *Client) ([]LogEntry, error) {
+		return c.Log(paths, startRev, endRev, changedPaths)
+	})
+}
+
+// GetLocations calls [Client.GetLocations] using a session from the pool.
+func (p *Pool) GetLocations(path string, pegRev int, revs []int) (map[int]string, error) {
+	return poolDo(p, func(c *Client) (map[int]string, error) {
+		return c.GetLocations(path, pegRev, revs)
+	})
+}
+
+// GetLocationSegments calls [Client.GetLocationSegments] using a session from the pool.
+func (p *Pool) GetLocationSegments(path string, pegRev *int, startRev *int, endRev *int) ([]LocationSegment, error) {
+	return poolDo(p, func(c *Client) ([]LocationSegment, error) {
+		return c.GetLocationSegments(path, pegRev, startRev, endRev)
+	})
+}
+
+// ResolvePeg calls [Client.ResolvePeg] using a session from the pool.
+func (p *Pool) ResolvePeg(path string, pegRev int, opRev int) (string, error) {
+	return poolDo(p, func(c *Client) (string, error) {
+		return c.ResolvePeg(path, pegRev, opRev)
+	})
+}
+
+// GetFileRevs calls [Client.GetFileRevs] using a session from the pool.
+func (p *Pool) GetFileRevs(path string, startRev *int, endRev *int, includeMerged bool, fn func(FileRev) error) error {
+	return poolDo0(p, func(c *Client) error {
+		return c.GetFileRevs(path, startRev, endRev, includeMerged, fn)
+	})
+}
+
+// Blame calls [Client.Blame] using a session from the pool.
+func (p *Pool) Blame(path string, startRev *int, endRev *int) ([]BlameLine, error) {
+	return poolDo(p, func(c *Client) ([]BlameLine, error) {
+		return c.Blame(path, startRev, endRev)
+	})
+}
+
+// Lock calls [Client.Lock] using a session from the pool.
+func (p *Pool) Lock(path string, comment string, steal bool, currentRev *int) (Lock, error) {
+	return poolDo(p, func(c *Client) (Lock, error) {
+		return c.Lock(path, comment, steal, currentRev)
+	})
+}
+
+// Unlock calls [Client.Unlock] using a session from the pool.
+func (p *Pool) Unlock(path string, token string, breakLock bool) error {
+	return poolDo0(p, func(c *Client) error {
+		return c.Unlock(path, token, breakLock)
+	})
+}
+
+// GetLock calls [Client.GetLock] using a session from the pool.
+func (p *Pool) GetLock(path string) (*Lock, error) {
+	return poolDo(p, func(c *Client) (*Lock, error) {
+		return c.GetLock(path)
+	})
+}
+
+// GetLocks calls [Client.GetLocks] using a session from the pool.
+func (p *Pool) GetLocks(path string, depth string) ([]Lock, error) {
+	return poolDo(p, func(c *Client) ([]Lock, error) {
+		return c.GetLocks(path, depth)
+	})
+}
+
+// LockMany calls [Client.LockMany] using a session from the pool.
+func (p *Pool) LockMany(comment string, steal bool, paths map[string]*int) ([]LockResult, error) {
+	return poolDo(p, func(c *Client) ([]LockResult, error) {
+		return c.LockMany(comment, steal, paths)
+	})
+}
+
+// UnlockMany calls [Client.UnlockMany] using a session from the pool.
+func (p *Pool) UnlockMany(breakLock bool, tokens map[string]string) ([]LockResult, error) {
+	return poolDo(p, func(c *Client) ([]LockResult, error) {
+		return c.UnlockMany(breakLock, tokens)
+	})
+}
+
+// Commit calls [Client.Commit] using a session from the pool.
+func (p *Pool) Commit(message string, revProps Props, lockTokens map[string]string, keepLocks bool, fn func(Editor) error) (CommitInfo, error) {
+	return poolDo(p, func(c *Client) (CommitInfo, error) {
+		return c.Commit(message, revProps, lockTokens, keepLocks, fn)
+	})
+}
+
+// Update calls [Client.Update] using a session from the pool.
+func (p *Pool) Update(report *Report, e Editor) error {
+	return poolDo0(p, func(c *Client) error {
+		return c.Update(report, e)
+	})
+}
@@ -0,0 +1,92 @@
+package svn
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// serveTest answers "get-latest-rev" with revision 7,
+// "check-path" with an error, and drops the connection on "stat".
+func serveTest(s *conn) {
+	for {
+		var command struct {
+			Name   string
+			Params Item
+		}
+		if err := s.Read(&command); err != nil {
+			return
+		}
+		switch command.Name {
+		case "get-latest-rev":
+			s.WriteSuccess([]any{[]any{}, []byte{}})
+			s.WriteSuccess([]any{7})
+		case "check-path":
+			s.WriteFailure(Error{AprErr: 160013, Message: "not found"})
+		default:
+			s.w.(io.Closer).Close()
+			return
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	var dials atomic.Int32
+	p := NewPool("svn://test/repo", 3)
+	p.dial = func(ctx context.Context, address string, opts ...Option) (*Client, error) {
+		dials.Add(1)
+		c, s := pipeClient(address)
+		go serveTest(s)
+		return c, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rev, err := p.GetLatestRev(); err != nil || rev != 7 {
+				t.Errorf("GetLatestRev: %d, %v", rev, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := dials.Load(); n < 1 || n > 3 {
+		t.Errorf("%d sessions opened in a pool of 3", n)
+	}
+
+	// errors from the server do not discard the session:
+	p = NewPool("svn://test/repo", 1)
+	p.dial = func(ctx context.Context, address string, opts ...Option) (*Client, error) {
+		dials.Add(1)
+		c, s := pipeClient(address)
+		go serveTest(s)
+		return c, nil
+	}
+	dials.Store(0)
+	if _, err := p.CheckPath("nothing", nil); err == nil {
+		t.Errorf("CheckPath: expected error")
+	}
+	p.GetLatestRev()
+	if n := dials.Load(); n != 1 {
+		t.Errorf("session discarded after a server error: %d dials", n)
+	}
+	// but network errors do:
+	if _, err := p.Stat("", nil); err == nil {
+		t.Errorf("Stat: expected error")
+	}
+	if rev, err := p.GetLatestRev(); err != nil || rev != 7 {
+		t.Errorf("GetLatestRev after network error: %d, %v", rev, err)
+	}
+	if n := dials.Load(); n != 2 {
+		t.Errorf("%d dials after a network error, want 2", n)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, err := p.GetLatestRev(); err != ErrClosed {
+		t.Errorf("GetLatestRev after Close: %v", err)
+	}
+}
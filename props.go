@@ -0,0 +1,299 @@
+package svn
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Names of the properties with a special meaning in Subversion.
+const (
+	PropMimeType      = "svn:mime-type"
+	PropIgnore        = "svn:ignore"
+	PropGlobalIgnores = "svn:global-ignores"
+	PropAutoProps     = "svn:auto-props"
+	PropEOLStyle      = "svn:eol-style"
+	PropKeywords      = "svn:keywords"
+	PropExecutable    = "svn:executable"
+	PropSpecial       = "svn:special"
+	PropNeedsLock     = "svn:needs-lock"
+	PropExternals     = "svn:externals"
+	PropMergeinfo     = "svn:mergeinfo"
+
+	PropRevAuthor = "svn:author"
+	PropRevDate   = "svn:date"
+	PropRevLog    = "svn:log"
+)
+
+// Props is a set of properties of a node (or of a revision),
+// indexed by name.
+//
+// In the SVN protocol it is sent as a "proplist":
+// a list of ( name:string value:string ) tuples.
+type Props map[string]string
+
+// MarshalItem converts the properties into a proplist, sorted by name.
+func (p Props) MarshalItem() (Item, error) {
+	item := Item{Type: ListType, List: []Item{}}
+	for _, name := range p.Names() {
+		item.List = append(item.List, Item{
+			Type: ListType,
+			List: []Item{
+				{Type: StringType, Text: name},
+				{Type: StringType, Text: p[name]},
+			},
+		})
+	}
+	return item, nil
+}
+
+// UnmarshalItem fills the properties from a proplist.
+func (p *Props) UnmarshalItem(item Item) error {
+	if item.Type != ListType {
+		return fmt.Errorf("cannot unmarshal %s into a proplist", item)
+	}
+	var props []PropList
+	if err := Unmarshal(item, &props); err != nil {
+		return err
+	}
+	*p = make(Props, len(props))
+	for _, prop := range props {
+		(*p)[prop.Name] = prop.Value
+	}
+	return nil
+}
+
+// Names returns the names of all the properties, sorted.
+func (p Props) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// MimeType returns the value of "svn:mime-type", or an empty string.
+func (p Props) MimeType() string {
+	return p[PropMimeType]
+}
+
+// IsBinary reports whether "svn:mime-type" marks the node as binary,
+// using the same rules as Subversion: any type not starting with "text/"
+// is binary, except for a few well-known textual ones.
+func (p Props) IsBinary() bool {
+	mimeType, _, _ := strings.Cut(p.MimeType(), ";")
+	mimeType = strings.TrimSpace(mimeType)
+	switch {
+	case mimeType == "":
+		return false
+	case strings.HasPrefix(mimeType, "text/"):
+		return false
+	case mimeType == "image/x-xbitmap", mimeType == "image/x-xpixmap":
+		return false
+	}
+	return true
+}
+
+// IsExecutable reports whether "svn:executable" is set.
+func (p Props) IsExecutable() bool {
+	_, ok := p[PropExecutable]
+	return ok
+}
+
+// IsSpecial reports whether "svn:special" is set
+// (the node is a symbolic link).
+func (p Props) IsSpecial() bool {
+	_, ok := p[PropSpecial]
+	return ok
+}
+
+// NeedsLock reports whether "svn:needs-lock" is set.
+func (p Props) NeedsLock() bool {
+	_, ok := p[PropNeedsLock]
+	return ok
+}
+
+// EOLStyle returns the value of "svn:eol-style", or an empty string.
+func (p Props) EOLStyle() string {
+	return strings.TrimSpace(p[PropEOLStyle])
+}
+
+// Keywords returns the list of keywords in "svn:keywords".
+func (p Props) Keywords() []string {
+	return strings.Fields(p[PropKeywords])
+}
+
+// Ignore returns the patterns in "svn:ignore", one per line.
+func (p Props) Ignore() []string {
+	return propLines(p[PropIgnore])
+}
+
+// GlobalIgnores returns the patterns in "svn:global-ignores".
+func (p Props) GlobalIgnores() []string {
+	return strings.Fields(p[PropGlobalIgnores])
+}
+
+// AutoProps returns the rules in "svn:auto-props", indexed by
+// file pattern.  Every rule is a list of properties with its values.
+func (p Props) AutoProps() map[string]Props {
+	rules := make(map[string]Props)
+	for _, line := range propLines(p[PropAutoProps]) {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, values, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		pattern = strings.TrimSpace(pattern)
+		props := rules[pattern]
+		if props == nil {
+			props = make(Props)
+			rules[pattern] = props
+		}
+		// properties are separated by ';', and ";;" is a literal ';'
+		for _, prop := range strings.Split(strings.ReplaceAll(values, ";;", "\x00"), ";") {
+			prop = strings.ReplaceAll(prop, "\x00", ";")
+			name, value, _ := strings.Cut(prop, "=")
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			props[name] = strings.TrimSpace(value)
+		}
+	}
+	return rules
+}
+
+// Externals returns the definitions in "svn:externals", one per line,
+// skipping comments.
+func (p Props) Externals() []string {
+	var externals []string
+	for _, line := range propLines(p[PropExternals]) {
+		if !strings.HasPrefix(line, "#") {
+			externals = append(externals, line)
+		}
+	}
+	return externals
+}
+
+// InheritedProps is the list of properties inherited by a node from
+// its parent directories, ordered from the repository root down.
+//
+// In the SVN protocol it is sent as an "iproplist":
+// a list of ( path:string props:proplist ) tuples.
+type InheritedProps []struct {
+	Path  string
+	Props Props
+}
+
+// MarshalItem converts the inherited properties into an iproplist.
+func (ip InheritedProps) MarshalItem() (Item, error) {
+	item := Item{Type: ListType, List: []Item{}}
+	for _, p := range ip {
+		props, err := p.Props.MarshalItem()
+		if err != nil {
+			return Item{}, err
+		}
+		item.List = append(item.List, Item{
+			Type: ListType,
+			List: []Item{
+				{Type: StringType, Text: p.Path},
+				props,
+			},
+		})
+	}
+	return item, nil
+}
+
+// Lookup returns the value of the property "name" in the
+// nearest parent which has it, and the path of that parent.
+func (ip InheritedProps) Lookup(name string) (value string, path string, ok bool) {
+	for i := len(ip) - 1; i >= 0; i-- {
+		if value, ok := ip[i].Props[name]; ok {
+			return value, ip[i].Path, true
+		}
+	}
+	return "", "", false
+}
+
+// PropDelta is a set of changes in the properties of a node,
+// indexed by name: the new value of every property, or nil
+// if it was deleted.
+//
+// In the SVN protocol it is sent as a "propdelta":
+// a list of ( name:string [ value:string ] ) tuples.
+type PropDelta map[string]*string
+
+// MarshalItem converts the changes into a propdelta, sorted by name.
+func (d PropDelta) MarshalItem() (Item, error) {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	item := Item{Type: ListType, List: []Item{}}
+	for _, name := range names {
+		value := Item{Type: ListType}
+		if d[name] != nil {
+			value.List = []Item{{Type: StringType, Text: *d[name]}}
+		}
+		item.List = append(item.List, Item{
+			Type: ListType,
+			List: []Item{{Type: StringType, Text: name}, value},
+		})
+	}
+	return item, nil
+}
+
+// UnmarshalItem fills the changes from a propdelta.
+func (d *PropDelta) UnmarshalItem(item Item) error {
+	if item.Type != ListType {
+		return fmt.Errorf("cannot unmarshal %s into a propdelta", item)
+	}
+	var changes []struct {
+		Name  string
+		Value []string
+	}
+	if err := Unmarshal(item, &changes); err != nil {
+		return err
+	}
+	*d = make(PropDelta, len(changes))
+	for _, change := range changes {
+		(*d)[change.Name] = nil
+		if len(change.Value) > 0 {
+			(*d)[change.Name] = &change.Value[0]
+		}
+	}
+	return nil
+}
+
+// Apply returns a copy of props with the changes applied.
+func (d PropDelta) Apply(props Props) Props {
+	result := make(Props, len(props)+len(d))
+	for name, value := range props {
+		result[name] = value
+	}
+	for name, value := range d {
+		if value == nil {
+			delete(result, name)
+		} else {
+			result[name] = *value
+		}
+	}
+	return result
+}
+
+// propLines splits a multi-line property value into its
+// non-empty lines.
+func propLines(value string) []string {
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
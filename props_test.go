@@ -0,0 +1,83 @@
+package svn
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestPropsItem(t *testing.T) {
+	input := "( ( 10:svn:ignore 9:*.o\n*.so\n ) ( 14:svn:executable 1:* ) ) "
+	item, err := NewItemizer(strings.NewReader(input)).Item()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var props Props
+	if err := Unmarshal(item, &props); err != nil {
+		t.Fatal(err)
+	}
+	want := Props{
+		PropIgnore:     "*.o\n*.so\n",
+		PropExecutable: "*",
+	}
+	if !maps.Equal(props, want) {
+		t.Errorf("Unmarshal: want %v got %v", want, props)
+	}
+	if !props.IsExecutable() {
+		t.Errorf("IsExecutable: want true")
+	}
+	if got := props.Ignore(); len(got) != 2 || got[0] != "*.o" || got[1] != "*.so" {
+		t.Errorf("Ignore: got %q", got)
+	}
+
+	again, err := Marshal(props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := "( ( 14:svn:executable 1:* ) ( 10:svn:ignore 9:*.o\n*.so\n ) )"
+	if again.String() != golden {
+		t.Errorf("Marshal: want %q got %q", golden, again.String())
+	}
+}
+
+func TestPropsAutoProps(t *testing.T) {
+	props := Props{
+		PropAutoProps: "# comment\n*.sh = svn:eol-style=LF;svn:executable\n*.txt = svn:mime-type=text/plain;;charset=utf-8\n",
+	}
+	rules := props.AutoProps()
+	if len(rules) != 2 {
+		t.Fatalf("want 2 rules, got %v", rules)
+	}
+	if got := rules["*.sh"]; got[PropEOLStyle] != "LF" || !got.IsExecutable() {
+		t.Errorf("*.sh: got %v", got)
+	}
+	if got := rules["*.txt"].MimeType(); got != "text/plain;charset=utf-8" {
+		t.Errorf("*.txt: got %q", got)
+	}
+}
+
+func TestInheritedProps(t *testing.T) {
+	input := "( ( 0: ( ( 18:svn:global-ignores 5:*.tmp ) ) ) ( 5:trunk ( ( 18:svn:global-ignores 5:*.bak ) ) ) ) "
+	item, err := NewItemizer(strings.NewReader(input)).Item()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var iprops InheritedProps
+	if err := Unmarshal(item, &iprops); err != nil {
+		t.Fatal(err)
+	}
+	if len(iprops) != 2 || iprops[1].Path != "trunk" {
+		t.Fatalf("Unmarshal: got %v", iprops)
+	}
+	value, path, ok := iprops.Lookup(PropGlobalIgnores)
+	if !ok || value != "*.bak" || path != "trunk" {
+		t.Errorf("Lookup: got %q %q %v", value, path, ok)
+	}
+	again, err := Marshal(iprops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String()+" " != input {
+		t.Errorf("Marshal: want %q got %q", input, again.String())
+	}
+}
@@ -0,0 +1,162 @@
+package svn
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// A RecordedItem is an Item sent or received by a client.
+type RecordedItem struct {
+	Dir  Direction
+	Item Item
+}
+
+// RecordWriter returns a trace function which saves every Item to w,
+// so that the conversation can be replayed with a [ReplayServer].
+// Every Item is written in a line, in the same format used in the
+// protocol, inside a list with its direction:
+//
+//	( sent ( get-latest-rev ( ) ) )
+//	( received ( success ( 42 ) ) )
+func RecordWriter(w io.Writer) func(dir Direction, item Item) {
+	var mu sync.Mutex
+	return func(dir Direction, item Item) {
+		word := "sent"
+		if dir == Received {
+			word = "received"
+		}
+		record := Item{Type: ListType, List: []Item{{Type: WordType, Text: word}, item}}
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, record.String()+"\n")
+	}
+}
+
+// WithRecording returns an Option which saves every Item sent
+// or received by the client to w, as done by [RecordWriter].
+func WithRecording(w io.Writer) Option {
+	return WithTraceFunc(RecordWriter(w))
+}
+
+// ReadRecording reads a conversation saved by [RecordWriter].
+func ReadRecording(r io.Reader) ([]RecordedItem, error) {
+	var records []RecordedItem
+	items := NewItemizer(r)
+	for {
+		item, err := items.Item()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading recording: %w", err)
+		}
+		var record struct {
+			Dir  string
+			Item Item
+		}
+		if err = Unmarshal(item, &record); err != nil {
+			return nil, fmt.Errorf("reading recording: %w", err)
+		}
+		switch record.Dir {
+		case "sent":
+			records = append(records, RecordedItem{Sent, record.Item})
+		case "received":
+			records = append(records, RecordedItem{Received, record.Item})
+		default:
+			return nil, fmt.Errorf("reading recording: invalid direction %q", record.Dir)
+		}
+	}
+}
+
+// A ReplayServer plays the role of the server in a recorded
+// conversation: it sends to the client the Items it received in the
+// recording, and checks that the ones it receives are those that
+// were sent.
+type ReplayServer struct {
+	Recording []RecordedItem
+}
+
+// NewReplayServer returns a ReplayServer for a conversation
+// saved by [RecordWriter].
+func NewReplayServer(r io.Reader) (*ReplayServer, error) {
+	records, err := ReadRecording(r)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayServer{Recording: records}, nil
+}
+
+// A ReplayError is returned by [ReplayServer.Serve] when the client
+// sends an Item which is not the one in the recording.
+type ReplayError struct {
+	// Index is the position of the Item in the recording.
+	Index int
+	Want  Item
+	Got   Item
+	// Diff has a line for every difference between Want and Got.
+	Diff []string
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("replay: item %d does not match the recording:\n\t%s", e.Index, strings.Join(e.Diff, "\n\t"))
+}
+
+// Serve replays the recording to a client, reading its requests from r
+// and writing the responses to w.  It returns a [*ReplayError] if the
+// client does not send what was recorded, and nil after the end
+// of the recording.
+func (s *ReplayServer) Serve(r io.Reader, w io.Writer) error {
+	conn := conn{r: r, w: w}
+	for i, record := range s.Recording {
+		if record.Dir == Received {
+			if err := conn.Write(record.Item); err != nil {
+				return fmt.Errorf("replay: item %d: %w", i, err)
+			}
+			continue
+		}
+		var item Item
+		if err := conn.Read(&item); err != nil {
+			return fmt.Errorf("replay: item %d: %w", i, err)
+		}
+		if diff := diffItems("", record.Item, item); len(diff) > 0 {
+			return &ReplayError{Index: i, Want: record.Item, Got: item, Diff: diff}
+		}
+	}
+	return nil
+}
+
+// diffItems returns the differences between two Items, one per line,
+// with the path of the element which differs:
+//
+//	[1][0]: want "trunk", got "branches"
+func diffItems(path string, want Item, got Item) []string {
+	if want.Type != ListType || got.Type != ListType {
+		if want.Type != got.Type || want.Number != got.Number || want.Text != got.Text {
+			return []string{fmt.Sprintf("%s: want %s, got %s", diffPath(path), formatItem(want), formatItem(got))}
+		}
+		return nil
+	}
+	var diff []string
+	for i := 0; i < max(len(want.List), len(got.List)); i++ {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(got.List):
+			diff = append(diff, fmt.Sprintf("%s: missing %s", p, formatItem(want.List[i])))
+		case i >= len(want.List):
+			diff = append(diff, fmt.Sprintf("%s: unexpected %s", p, formatItem(got.List[i])))
+		default:
+			diff = append(diff, diffItems(p, want.List[i], got.List[i])...)
+		}
+	}
+	return diff
+}
+
+func diffPath(path string) string {
+	if path == "" {
+		return "item"
+	}
+	return path
+}
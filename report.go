@@ -0,0 +1,266 @@
+package svn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A Report is a request to update a working copy: the operation requested
+// by the client ("update", "switch", "status" or "diff") and the state of
+// its working copy, described with the report command set.
+//
+// The edit is anchored at the URL of the session: the paths in the Editor
+// are relative to it, and Target is the entry of the session which is
+// updated, or "" for the session itself.
+type Report struct {
+	// Command is "update", "switch", "status" or "diff".
+	Command string
+	// Rev is the revision to update to, or nil for the latest one.
+	Rev *uint
+	// Target is the entry of the session to update, or "" for the
+	// session itself.
+	Target string
+	// Depth is the depth of the operation: "empty", "files",
+	// "immediates", "infinity" or "unknown" (to keep the depth
+	// of every path in the working copy).
+	Depth string
+	// DstPath, in "switch" and "diff", is the path (relative to the root
+	// of the repository, without a leading slash) which the target
+	// is switched to or compared with.
+	DstPath string
+	// SendCopyFrom asks for the adds of copied paths to include the
+	// source of the copy, and IgnoreAncestry to treat a node replaced
+	// by an unrelated one as a modification.
+	SendCopyFrom   bool
+	IgnoreAncestry bool
+	// TextDeltas is false if the contents of the files are not needed
+	// (as in "status"), in which case the text deltas are empty.
+	TextDeltas bool
+	// Paths are the paths reported by the client, relative to the
+	// target, in the order in which they were reported.
+	Paths []ReportedPath
+}
+
+// A ReportedPath is the state of a path in a working copy.
+type ReportedPath struct {
+	// Path is relative to the target of the report.
+	Path string
+	// Rev is the revision of the path in the working copy.
+	Rev uint
+	// StartEmpty is true if the working copy has none of the entries
+	// of the directory (they are described by other ReportedPaths).
+	StartEmpty bool
+	// LockToken is the token of the lock of the path, if any.
+	LockToken string
+	// Depth is the depth of the path in the working copy.
+	Depth string
+	// Deleted is true if the path is missing in the working copy.
+	Deleted bool
+	// LinkPath, if not empty, is the path in the repository (relative
+	// to its root) to which the path is switched in the working copy.
+	LinkPath string
+}
+
+// errReportAborted is returned by readReport when the client
+// sends "abort-report".
+var errReportAborted = errors.New("report aborted")
+
+// newReport parses the parameters of the commands which start a report:
+//
+//	update
+//	  params:   ( [ rev:number ] target:string recurse:bool
+//	              ? depth:word send_copyfrom_args:bool ? ignore_ancestry:bool )
+//	switch
+//	  params:   ( [ rev:number ] target:string recurse:bool url:string
+//	              ? depth:word ? send_copyfrom_args:bool ignore_ancestry:bool )
+//	status
+//	  params:   ( target:string recurse:bool ? [ rev:number ] ? depth:word )
+//	diff
+//	  params:   ( [ rev:number ] target:string recurse:bool ignore-ancestry:bool
+//	              url:string ? text-deltas:bool ? depth:word )
+//
+// It returns the Report and the URL, in "switch" and "diff".
+func newReport(command string, params Item) (*Report, string, error) {
+	report := &Report{Command: command, TextDeltas: true}
+	var recurse bool
+	var url string
+	var err error
+	switch command {
+	case "update":
+		var args struct {
+			Rev            *uint
+			Target         string
+			Recurse        bool
+			Depth          string
+			SendCopyFrom   bool
+			IgnoreAncestry bool
+		}
+		err = Unmarshal(params, &args)
+		report.Rev, report.Target, recurse, report.Depth = args.Rev, args.Target, args.Recurse, args.Depth
+		report.SendCopyFrom, report.IgnoreAncestry = args.SendCopyFrom, args.IgnoreAncestry
+	case "switch":
+		var args struct {
+			Rev            *uint
+			Target         string
+			Recurse        bool
+			URL            string
+			Depth          string
+			SendCopyFrom   bool
+			IgnoreAncestry bool
+		}
+		err = Unmarshal(params, &args)
+		report.Rev, report.Target, recurse, report.Depth = args.Rev, args.Target, args.Recurse, args.Depth
+		report.SendCopyFrom, report.IgnoreAncestry = args.SendCopyFrom, args.IgnoreAncestry
+		url = args.URL
+	case "status":
+		var args struct {
+			Target  string
+			Recurse bool
+			Rev     *uint
+			Depth   string
+		}
+		err = Unmarshal(params, &args)
+		report.Rev, report.Target, recurse, report.Depth = args.Rev, args.Target, args.Recurse, args.Depth
+		report.TextDeltas = false
+	case "diff":
+		var args struct {
+			Rev            *uint
+			Target         string
+			Recurse        bool
+			IgnoreAncestry bool
+			URL            string
+			TextDeltas     *bool
+			Depth          string
+		}
+		err = Unmarshal(params, &args)
+		report.Rev, report.Target, recurse, report.Depth = args.Rev, args.Target, args.Recurse, args.Depth
+		report.IgnoreAncestry = args.IgnoreAncestry
+		report.TextDeltas = args.TextDeltas == nil || *args.TextDeltas
+		url = args.URL
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if report.Depth == "" {
+		// old clients only send "recurse":
+		report.Depth = "files"
+		if recurse {
+			report.Depth = "infinity"
+		}
+	}
+	return report, url, nil
+}
+
+// readReport reads the commands of the report command set, adding the
+// paths described by them to report, until "finish-report" or
+// "abort-report".  The URLs of "link-path" must be under root.
+//
+//	set-path:
+//	  params: ( path:string rev:number start-empty:bool
+//	            ? [ lock-token:string ] ? depth:word )
+//	delete-path:
+//	  params: ( path:string )
+//	link-path:
+//	  params: ( path:string url:string rev:number start-empty:bool
+//	            ? [ lock-token:string ] ? depth:word )
+//	finish-report:
+//	  params: ( )
+//	abort-report
+//	  params: ( )
+//
+// None of them has a response: if any command is malformed, the rest of
+// the report is read and then the error (an [Error]) is returned.
+// It returns errReportAborted if the report was aborted, or any other
+// error if the connection fails.
+func readReport(conn *conn, root string, report *Report) error {
+	var reportErr error
+	fail := func(err error) {
+		if reportErr == nil {
+			reportErr = err
+		}
+	}
+	neterr := Error{
+		AprErr:  210004,
+		Message: "Malformed network data",
+	}
+	for {
+		var command struct {
+			Name   string
+			Params Item
+		}
+		if err := conn.Read(&command); err != nil {
+			return err
+		}
+		switch command.Name {
+		case "set-path", "link-path":
+			var args struct {
+				Path       string
+				URL        string
+				Rev        uint
+				StartEmpty bool
+				LockToken  []string
+				Depth      string
+			}
+			var err error
+			if command.Name == "set-path" {
+				var setPath struct {
+					Path       string
+					Rev        uint
+					StartEmpty bool
+					LockToken  []string
+					Depth      string
+				}
+				err = Unmarshal(command.Params, &setPath)
+				args.Path, args.Rev, args.StartEmpty, args.LockToken, args.Depth =
+					setPath.Path, setPath.Rev, setPath.StartEmpty, setPath.LockToken, setPath.Depth
+			} else {
+				err = Unmarshal(command.Params, &args)
+			}
+			if err != nil {
+				fail(neterr)
+				continue
+			}
+			if args.Depth == "" {
+				args.Depth = "infinity"
+			}
+			reported := ReportedPath{
+				Path:       strings.Trim(args.Path, "/"),
+				Rev:        args.Rev,
+				StartEmpty: args.StartEmpty,
+				LockToken:  firstString(args.LockToken),
+				Depth:      args.Depth,
+			}
+			if command.Name == "link-path" {
+				p, ok := relativeURL(root, args.URL)
+				if !ok {
+					fail(Error{
+						AprErr:  170000, // SVN_ERR_RA_ILLEGAL_URL
+						Message: fmt.Sprintf("'%s' is not the same repository as '%s'", args.URL, root),
+					})
+					continue
+				}
+				reported.LinkPath = p
+			}
+			report.Paths = append(report.Paths, reported)
+		case "delete-path":
+			var args struct {
+				Path string
+			}
+			if err := Unmarshal(command.Params, &args); err != nil {
+				fail(neterr)
+				continue
+			}
+			report.Paths = append(report.Paths, ReportedPath{Path: strings.Trim(args.Path, "/"), Deleted: true})
+		case "finish-report":
+			return reportErr
+		case "abort-report":
+			return errReportAborted
+		default:
+			fail(Error{
+				AprErr:  210001,
+				Message: fmt.Sprintf("Unknown command '%s'", command.Name),
+			})
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package svn
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestServerReport(t *testing.T) {
+	var report *Report
+	s := &Server{
+		GetLatestRev: func() (uint, error) { return 3, nil },
+		Update: func(r *Report, e Editor) error {
+			report = r
+			if r.Command == "diff" {
+				return Error{AprErr: 200007, Message: "no diffs"}
+			}
+			rev := uint(2)
+			if err := e.TargetRev(3); err != nil {
+				return err
+			}
+			if err := e.OpenRoot(&rev, "d"); err != nil {
+				return err
+			}
+			return e.CloseDir("d")
+		},
+	}
+	client, server := net.Pipe()
+	go s.Serve(server, server)
+	c, err := NewClient(client, "svn://example.com/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// run sends a command starting a report and the commands of the
+	// report, and returns the editor commands sent by the server.
+	run := func(cmd string, params []any, reportCommands ...[]any) ([]string, error) {
+		if err := c.writeCommand(cmd, params); err != nil {
+			return nil, err
+		}
+		if err := c.handleAuth(); err != nil {
+			return nil, err
+		}
+		for _, rc := range reportCommands {
+			if err := c.conn.Write(rc); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.handleAuth(); err != nil {
+			return nil, err
+		}
+		var names []string
+		for len(names) == 0 || (names[len(names)-1] != "close-edit" && names[len(names)-1] != "abort-edit") {
+			var command struct {
+				Name   string
+				Params Item
+			}
+			if err := c.conn.Read(&command); err != nil {
+				return nil, err
+			}
+			names = append(names, command.Name)
+		}
+		if err := c.conn.WriteSuccess([]any{}); err != nil {
+			return nil, err
+		}
+		var resp Item
+		return names, c.conn.ReadResponse(&resp)
+	}
+
+	commands, err := run("switch", []any{[]any{3}, []byte("doc"), true, []byte("svn://example.com/repo/branches/b1/doc"), "infinity", false, true},
+		[]any{"set-path", []any{[]byte(""), 2, false, []any{}, "infinity"}},
+		[]any{"set-path", []any{[]byte("new"), 0, true, []any{[]byte("token")}}},
+		[]any{"delete-path", []any{[]byte("gone")}},
+		[]any{"link-path", []any{[]byte("other"), []byte("svn://example.com/repo/trunk/x"), 1, false, []any{}, "files"}},
+		[]any{"finish-report", []any{}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"target-rev", "open-root", "close-dir", "close-edit"}; !slices.Equal(commands, want) {
+		t.Errorf("switch: got commands %q, want %q", commands, want)
+	}
+	rev := uint(3)
+	want := &Report{
+		Command:        "switch",
+		Rev:            &rev,
+		Target:         "doc",
+		Depth:          "infinity",
+		DstPath:        "branches/b1/doc",
+		IgnoreAncestry: true,
+		TextDeltas:     true,
+		Paths: []ReportedPath{
+			{Path: "", Rev: 2, Depth: "infinity"},
+			{Path: "new", StartEmpty: true, LockToken: "token", Depth: "infinity"},
+			{Path: "gone", Deleted: true},
+			{Path: "other", Rev: 1, Depth: "files", LinkPath: "trunk/x"},
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("switch:\n got %+v\nwant %+v", report, want)
+	}
+
+	// an old client, with "recurse" and no depth:
+	if _, err = run("status", []any{[]byte(""), false, []any{}},
+		[]any{"set-path", []any{[]byte(""), 3, false}},
+		[]any{"finish-report", []any{}},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if report.Rev != nil || report.Depth != "files" || report.TextDeltas {
+		t.Errorf("status: got %+v", report)
+	}
+
+	// errors in the report, or in Update, are returned at the end:
+	var svnErr Error
+	err = c.writeCommand("update", []any{[]any{}, []byte(""), true})
+	if err == nil {
+		err = c.handleAuth()
+	}
+	if err == nil {
+		c.conn.Write([]any{"no-such-command", []any{}})
+		c.conn.Write([]any{"finish-report", []any{}})
+		err = c.handleAuth()
+	}
+	if !errors.As(err, &svnErr) || svnErr.AprErr != 210001 {
+		t.Errorf("update with an unknown command: got %v", err)
+	}
+	if _, err = run("diff", []any{[]any{}, []byte(""), true, false, []byte("svn://example.com/repo"), false},
+		[]any{"finish-report", []any{}},
+	); !errors.As(err, &svnErr) || svnErr.AprErr != 200007 {
+		t.Errorf("failed diff: got %v", err)
+	}
+	if report.TextDeltas || report.Command != "diff" {
+		t.Errorf("diff: got %+v", report)
+	}
+
+	// an aborted report has no response:
+	err = c.writeCommand("update", []any{[]any{}, []byte(""), true})
+	if err == nil {
+		err = c.handleAuth()
+	}
+	if err == nil {
+		err = c.conn.Write([]any{"abort-report", []any{}})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev, err := c.GetLatestRev(); err != nil || rev != 3 {
+		t.Errorf("get-latest-rev after abort-report: %d, %v", rev, err)
+	}
+}
@@ -0,0 +1,339 @@
+package svn
+
+import (
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/cespedes/svn/mergeinfo"
+)
+
+// A ServerSession is the state of a connection to a [Server],
+// which is passed to every method of its [Repository].
+type ServerSession struct {
+	// User is the name of the authenticated user,
+	// or empty for anonymous users.
+	User string
+	// URL is the URL of the session, as sent by the client when
+	// connecting and changed by "reparent".
+	URL string
+	// Root and UUID are the URL of the root and the UUID
+	// of the repository, as returned by [Repository.Open].
+	Root string
+	UUID string
+	// Capabilities are the capabilities announced by the client.
+	Capabilities []string
+	// RAClient and Client identify the software of the client,
+	// such as "SVN/1.14.2 (x86_64-pc-linux-gnu)" and "svn".
+	RAClient string
+	Client   string
+	// Data can be used by the Repository to keep its own state
+	// about the session.
+	Data any
+}
+
+// HasCapability reports whether the client announced a capability.
+func (s *ServerSession) HasCapability(capability string) bool {
+	return slices.Contains(s.Capabilities, capability)
+}
+
+// Path returns the path in the repository (relative to its root,
+// without a leading slash) of a path relative to the URL of the session.
+func (s *ServerSession) Path(p string) string {
+	base, _ := relativeURL(s.Root, s.URL)
+	return strings.Trim(path.Join(base, p), "/")
+}
+
+// A Repository is a repository served by a [Server].
+//
+// The paths passed to its methods are relative to the root of the
+// repository, without a leading slash; the ones returned are absolute
+// (as in [LogEntry] or [Lock]), unless otherwise noted.  The revision
+// arguments are nil for the latest revision.
+//
+// A Repository only needs to implement reading; the other operations
+// (and the corresponding capabilities, which are announced to the clients
+// only if they are implemented) are provided by the optional interfaces
+// [ListRepository], [InheritedPropsRepository], [HistoryRepository],
+// [MergeinfoRepository], [WriteRepository], [LockRepository],
+// [ReplayRepository] and [UpdateRepository].  Without the last two,
+// the changes are computed from the trees of the revisions with
+// [TreeDelta], and the revision properties from Log.
+type Repository interface {
+	// Open is called when a client connects, with the URL of the
+	// session.  It returns the UUID and the URL of the root of the
+	// repository, which must be a prefix of the URL of the session.
+	Open(sess *ServerSession) (ReposInfo, error)
+	// LatestRev returns the latest revision.
+	LatestRev(sess *ServerSession) (uint, error)
+	// Stat returns the status of a path, with a Kind of NodeNone
+	// if it does not exist.
+	Stat(sess *ServerSession, path string, rev *uint) (Stat, error)
+	// CheckPath returns the kind of a path, or NodeNone
+	// if it does not exist.
+	CheckPath(sess *ServerSession, path string, rev *uint) (NodeKind, error)
+	// GetFile returns a file, as [Server.GetFileReader].
+	GetFile(sess *ServerSession, path string, rev *uint, wantProps bool, wantContents bool) (File, io.Reader, error)
+	// GetDir returns a directory and, if wantContents is true, its
+	// entries (whose paths are their names), with the given fields.
+	GetDir(sess *ServerSession, path string, rev *uint, wantProps bool, wantContents bool, fields []string) (Dir, error)
+	// Log returns the revisions between startRev and endRev (in that
+	// order) which changed any of the paths or their descendants.
+	Log(sess *ServerSession, paths []string, startRev uint, endRev uint, changedPaths bool) ([]LogEntry, error)
+}
+
+// A ListRepository is a Repository which implements the "list" command
+// (capability "list").
+type ListRepository interface {
+	Repository
+	// List returns the entries in a path, up to the given depth,
+	// including the path itself, with absolute paths.  If patterns
+	// are given, only the entries whose names match one of them
+	// are returned.
+	List(sess *ServerSession, path string, rev *uint, depth string, fields []string, patterns []string) ([]Dirent, error)
+}
+
+// An InheritedPropsRepository is a Repository which can send
+// the properties inherited by a path from its parents
+// (capability "inherited-props").
+type InheritedPropsRepository interface {
+	Repository
+	GetIProps(sess *ServerSession, path string, rev *uint) (InheritedProps, error)
+}
+
+// A HistoryRepository is a Repository which can follow
+// the history of the nodes (capability "file-revs-reverse").
+// Its methods are as the corresponding functions of [Server].
+type HistoryRepository interface {
+	Repository
+	GetDeletedRev(sess *ServerSession, path string, pegRev uint, endRev uint) (*uint, error)
+	GetLocations(sess *ServerSession, path string, pegRev uint, revs []uint) (map[uint]string, error)
+	GetLocationSegments(sess *ServerSession, path string, pegRev *uint, startRev *uint, endRev *uint) ([]LocationSegment, error)
+	GetFileRevs(sess *ServerSession, path string, startRev *uint, endRev *uint, includeMerged bool) ([]FileRev, error)
+}
+
+// A MergeinfoRepository is a Repository which keeps track
+// of merges (capability "mergeinfo").
+type MergeinfoRepository interface {
+	Repository
+	// GetMergeinfo returns the mergeinfo of some paths,
+	// indexed by their path in the repository.
+	GetMergeinfo(sess *ServerSession, paths []string, rev *uint, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error)
+}
+
+// A WriteRepository is a Repository which accepts commits
+// (capability "commit-revprops").
+type WriteRepository interface {
+	Repository
+	// Commit creates a new revision, as [Server.Commit].
+	// The paths of the Editor are relative to the URL of the session;
+	// the ones in lockTokens are relative to the root.
+	Commit(sess *ServerSession, revProps Props, lockTokens map[string]string, keepLocks bool, edit func(Editor) error) (CommitInfo, error)
+}
+
+// A LockRepository is a Repository which supports locking paths.
+// Its methods are as the corresponding functions of [Server],
+// with sess.User as the owner of the locks.
+type LockRepository interface {
+	Repository
+	Lock(sess *ServerSession, path string, comment string, steal bool, currentRev *uint) (Lock, error)
+	Unlock(sess *ServerSession, path string, token string, breakLock bool) error
+	GetLock(sess *ServerSession, path string) (*Lock, error)
+	GetLocks(sess *ServerSession, path string, depth string) ([]Lock, error)
+}
+
+// A ReplayRepository is a Repository which can replay the changes
+// made in a revision (capability "partial-replay"), including the
+// sources of the copies and the revision properties which are not
+// in the log.
+type ReplayRepository interface {
+	Repository
+	// RevProps returns the properties of a revision.
+	RevProps(sess *ServerSession, rev uint) (Props, error)
+	// Replay sends to e the changes made in a revision under the URL
+	// of the session, with paths relative to it, as [Server.Replay].
+	Replay(sess *ServerSession, rev uint, lowWater uint, sendDeltas bool, e Editor) error
+}
+
+// An UpdateRepository is a Repository which can send the changes
+// needed to update a working copy, as [Server.Update], instead of
+// using [TreeDelta].
+type UpdateRepository interface {
+	Repository
+	Update(sess *ServerSession, report *Report, e Editor) error
+}
+
+// useRepository fills in the functions of s which are nil
+// with the methods of its Repository, for the session sess.
+// It is only called on the copy of a Server which serves
+// a connection (see Server.connection).
+func (s *Server) useRepository(sess *ServerSession) {
+	r := s.Repository
+	s.session = sess
+	paths := func(paths []string) []string {
+		joined := make([]string, len(paths))
+		for i, p := range paths {
+			joined[i] = sess.Path(p)
+		}
+		return joined
+	}
+	if s.Greet == nil {
+		s.Greet = func(version int, capabilities []string, url string, raclient string, client *string) (ReposInfo, error) {
+			return r.Open(sess)
+		}
+	}
+	if s.Reparent == nil {
+		// the URL of the session is changed by Serve.
+		s.Reparent = func(url string) error { return nil }
+	}
+	if s.GetLatestRev == nil {
+		s.GetLatestRev = func() (uint, error) {
+			return r.LatestRev(sess)
+		}
+	}
+	if s.Stat == nil {
+		s.Stat = func(p string, rev *uint) (Stat, error) {
+			return r.Stat(sess, sess.Path(p), rev)
+		}
+	}
+	if s.CheckPath == nil {
+		s.CheckPath = func(p string, rev *uint) (NodeKind, error) {
+			return r.CheckPath(sess, sess.Path(p), rev)
+		}
+	}
+	if s.GetFile == nil && s.GetFileReader == nil {
+		s.GetFileReader = func(p string, rev *uint, wantProps bool, wantContents bool) (File, io.Reader, error) {
+			return r.GetFile(sess, sess.Path(p), rev, wantProps, wantContents)
+		}
+	}
+	if s.GetDir == nil {
+		s.GetDir = func(p string, rev *uint, wantProps bool, wantContents bool, fields []string) (Dir, error) {
+			return r.GetDir(sess, sess.Path(p), rev, wantProps, wantContents, fields)
+		}
+	}
+	if s.Log == nil {
+		s.Log = func(p []string, startRev uint, endRev uint, changedPaths bool) ([]LogEntry, error) {
+			if len(p) == 0 {
+				p = []string{""}
+			}
+			return r.Log(sess, paths(p), startRev, endRev, changedPaths)
+		}
+	}
+	if r, ok := r.(ListRepository); ok && s.List == nil {
+		s.List = func(p string, rev *uint, depth string, fields []string, patterns []string) ([]Dirent, error) {
+			return r.List(sess, sess.Path(p), rev, depth, fields, patterns)
+		}
+	}
+	if r, ok := r.(InheritedPropsRepository); ok && s.GetIProps == nil {
+		s.GetIProps = func(p string, rev *uint) (InheritedProps, error) {
+			return r.GetIProps(sess, sess.Path(p), rev)
+		}
+	}
+	if r, ok := r.(HistoryRepository); ok {
+		if s.GetDeletedRev == nil {
+			s.GetDeletedRev = func(p string, pegRev uint, endRev uint) (*uint, error) {
+				return r.GetDeletedRev(sess, sess.Path(p), pegRev, endRev)
+			}
+		}
+		if s.GetLocations == nil {
+			s.GetLocations = func(p string, pegRev uint, revs []uint) (map[uint]string, error) {
+				return r.GetLocations(sess, sess.Path(p), pegRev, revs)
+			}
+		}
+		if s.GetLocationSegments == nil {
+			s.GetLocationSegments = func(p string, pegRev *uint, startRev *uint, endRev *uint) ([]LocationSegment, error) {
+				return r.GetLocationSegments(sess, sess.Path(p), pegRev, startRev, endRev)
+			}
+		}
+		if s.GetFileRevs == nil {
+			s.GetFileRevs = func(p string, startRev *uint, endRev *uint, includeMerged bool) ([]FileRev, error) {
+				return r.GetFileRevs(sess, sess.Path(p), startRev, endRev, includeMerged)
+			}
+		}
+	}
+	if r, ok := r.(MergeinfoRepository); ok && s.GetMergeinfo == nil {
+		s.GetMergeinfo = func(p []string, rev *uint, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error) {
+			catalog, err := r.GetMergeinfo(sess, paths(p), rev, inherit, includeDescendants)
+			if err != nil {
+				return nil, err
+			}
+			// the paths in the result are relative to the session:
+			base := sess.Path("")
+			result := make(map[string]mergeinfo.Mergeinfo, len(catalog))
+			for p, m := range catalog {
+				p = strings.TrimPrefix(p, "/")
+				if base != "" {
+					p = strings.TrimPrefix(strings.TrimPrefix(p, base), "/")
+				}
+				result[p] = m
+			}
+			return result, nil
+		}
+	}
+	if r, ok := r.(WriteRepository); ok && s.Commit == nil {
+		s.Commit = func(revProps Props, lockTokens map[string]string, keepLocks bool, edit func(Editor) error) (CommitInfo, error) {
+			tokens := make(map[string]string, len(lockTokens))
+			for p, token := range lockTokens {
+				tokens[sess.Path(p)] = token
+			}
+			return r.Commit(sess, revProps, tokens, keepLocks, edit)
+		}
+	}
+	if r, ok := r.(LockRepository); ok {
+		if s.Lock == nil {
+			s.Lock = func(p string, comment string, steal bool, currentRev *uint) (Lock, error) {
+				return r.Lock(sess, sess.Path(p), comment, steal, currentRev)
+			}
+		}
+		if s.Unlock == nil {
+			s.Unlock = func(p string, token string, breakLock bool) error {
+				return r.Unlock(sess, sess.Path(p), token, breakLock)
+			}
+		}
+		if s.GetLock == nil {
+			s.GetLock = func(p string) (*Lock, error) {
+				return r.GetLock(sess, sess.Path(p))
+			}
+		}
+		if s.GetLocks == nil {
+			s.GetLocks = func(p string, depth string) ([]Lock, error) {
+				return r.GetLocks(sess, sess.Path(p), depth)
+			}
+		}
+	}
+	if r, ok := r.(ReplayRepository); ok {
+		if s.RevProps == nil {
+			s.RevProps = func(rev uint) (Props, error) {
+				return r.RevProps(sess, rev)
+			}
+		}
+		if s.Replay == nil {
+			s.Replay = func(rev uint, lowWater uint, sendDeltas bool, e Editor) error {
+				return r.Replay(sess, rev, lowWater, sendDeltas, e)
+			}
+		}
+	}
+	if r, ok := r.(UpdateRepository); ok && s.Update == nil {
+		s.Update = func(report *Report, e Editor) error {
+			return r.Update(sess, report, e)
+		}
+	}
+	// the other repositories send the tree deltas
+	// between their revisions (see TreeDelta).
+	if s.RevProps == nil {
+		s.RevProps = func(rev uint) (Props, error) {
+			return replayRevProps(r, sess, rev)
+		}
+	}
+	if s.Replay == nil {
+		s.Replay = func(rev uint, lowWater uint, sendDeltas bool, e Editor) error {
+			return replayDelta(r, sess, rev, lowWater, sendDeltas, e)
+		}
+	}
+	if s.Update == nil {
+		s.Update = func(report *Report, e Editor) error {
+			return TreeDelta(r, sess, report, e)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package svn
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/user"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// pathRepo is a Repository which records the paths it receives.
+type pathRepo struct {
+	paths []string
+	sess  *ServerSession
+}
+
+func (r *pathRepo) Open(sess *ServerSession) (ReposInfo, error) {
+	r.sess = sess
+	return ReposInfo{UUID: defaultUUID, URL: "svn://example.com/repo"}, nil
+}
+
+func (r *pathRepo) LatestRev(sess *ServerSession) (uint, error) { return 7, nil }
+
+func (r *pathRepo) Stat(sess *ServerSession, path string, rev *uint) (Stat, error) {
+	r.paths = append(r.paths, path)
+	return Stat{Kind: NodeFile, CreatedRev: 7}, nil
+}
+
+func (r *pathRepo) CheckPath(sess *ServerSession, path string, rev *uint) (NodeKind, error) {
+	r.paths = append(r.paths, path)
+	return NodeFile, nil
+}
+
+func (r *pathRepo) GetFile(sess *ServerSession, path string, rev *uint, wantProps bool, wantContents bool) (File, io.Reader, error) {
+	return File{}, nil, nil
+}
+
+func (r *pathRepo) GetDir(sess *ServerSession, path string, rev *uint, wantProps bool, wantContents bool, fields []string) (Dir, error) {
+	return Dir{}, nil
+}
+
+func (r *pathRepo) Log(sess *ServerSession, paths []string, startRev uint, endRev uint, changedPaths bool) ([]LogEntry, error) {
+	r.paths = append(r.paths, paths...)
+	return nil, nil
+}
+
+func (r *pathRepo) RevProps(sess *ServerSession, rev uint) (Props, error) {
+	return Props{PropRevLog: "message"}, nil
+}
+
+func (r *pathRepo) Replay(sess *ServerSession, rev uint, lowWater uint, sendDeltas bool, e Editor) error {
+	return nil
+}
+
+func TestServerRepository(t *testing.T) {
+	r := &pathRepo{}
+	client, server := net.Pipe()
+	go (&Server{Repository: r}).Serve(server, server)
+	c, err := NewClient(client, "svn://example.com/repo/trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if !c.HasCapability("partial-replay") || c.HasCapability("list") || c.HasCapability("commit-revprops") {
+		t.Errorf("capabilities: %q", c.Info.Capabilities)
+	}
+	if rev, err := c.GetLatestRev(); err != nil || rev != 7 {
+		t.Errorf("get-latest-rev: %d, %v", rev, err)
+	}
+	if stat, err := c.Stat("a.txt", nil); err != nil || stat.CreatedRev != 7 {
+		t.Errorf("stat: %+v, %v", stat, err)
+	}
+	branch, err := c.OpenSession("svn://example.com/repo/branches/b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = branch.CheckPath("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = branch.Log(nil, nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"trunk/a.txt", "branches/b1", "branches/b1"}
+	if !slices.Equal(r.paths, want) {
+		t.Errorf("paths: got %q, want %q", r.paths, want)
+	}
+	if r.sess.RAClient == "" || r.sess.Root != "svn://example.com/repo" {
+		t.Errorf("session: %+v", r.sess)
+	}
+}
+
+func TestServerAuthenticate(t *testing.T) {
+	r := &pathRepo{}
+	s := &Server{Repository: r}
+	c := serverClient(t, s, "svn://example.com/repo")
+	if _, err := c.GetLatestRev(); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if r.sess.User != name {
+		t.Errorf("default user: got %q, want %q", r.sess.User, name)
+	}
+	var svnErr Error
+	if _, err := externalAuth("EXTERNAL", name+"x"); !errors.As(err, &svnErr) || svnErr.AprErr != 170001 {
+		t.Errorf("EXTERNAL as another user: %v", err)
+	}
+
+	var mechs []string
+	s.Authenticate = func(mech string, token string) (string, error) {
+		mechs = append(mechs, mech+" "+token)
+		return "alice", nil
+	}
+	c = serverClient(t, s, "svn://example.com/repo")
+	if _, err := c.GetLatestRev(); err != nil || r.sess.User != "alice" || !slices.Equal(mechs, []string{"EXTERNAL "}) {
+		t.Errorf("user: %q, mechanisms %q, %v", r.sess.User, mechs, err)
+	}
+
+	s.Authenticate = func(mech string, token string) (string, error) {
+		return "", errors.New("no access")
+	}
+	client, server := net.Pipe()
+	go s.Serve(server, server)
+	if _, err := NewClient(client, "svn://example.com/repo"); !errors.As(err, &svnErr) || svnErr.AprErr != 170001 || svnErr.Message != "no access" {
+		t.Errorf("rejected client: %v", err)
+	}
+}
+
+func TestServerConnections(t *testing.T) {
+	// a Server serving several connections at the same time:
+	s := NewFSServer(fstest.MapFS{
+		"trunk/a.txt":      {Data: []byte("trunk\n")},
+		"branches/b/a.txt": {Data: []byte("branch\n")},
+	}, "svn://example.com/repo")
+	trunk := serverClient(t, s, "svn://example.com/repo/trunk")
+	branch := serverClient(t, s, "svn://example.com/repo/branches/b")
+	for _, test := range []struct {
+		c    *Client
+		want string
+	}{{trunk, "trunk\n"}, {branch, "branch\n"}, {trunk, "trunk\n"}} {
+		if file, err := test.c.GetFile("a.txt", nil, false, true, false); err != nil || string(file.Contents) != test.want {
+			t.Errorf("get-file in %s: %q, %v", test.c.URL(), file.Contents, err)
+		}
+	}
+	if s.GetFileReader != nil || s.ReposInfo.URL != "" {
+		t.Errorf("Serve modified the Server: %+v", s)
+	}
+}
+
+func TestHTTPHandlerRepository(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(&HTTPHandler{NewServer: func(*http.Request) (*Server, error) {
+		return NewFSServer(fstest.MapFS{"trunk/a.txt": {Data: []byte("trunk\n")}}, ts.URL), nil
+	}})
+	defer ts.Close()
+
+	// a checkout of trunk is anchored at trunk:
+	req, _ := http.NewRequest("REPORT", ts.URL+"/!svn/me", strings.NewReader(`<S:update-report xmlns:S="svn:" send-all="true">`+
+		`<S:src-path>`+ts.URL+`/trunk</S:src-path><S:entry rev="0" start-empty="true"></S:entry></S:update-report>`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `<S:add-file name="a.txt">`) || strings.Contains(string(body), `name="trunk"`) {
+		t.Errorf("update-report of trunk:\n%s", body)
+	}
+}
@@ -23,14 +23,16 @@ func ParseResponse(i Item) (Item, error) {
 	case "success":
 		return resp.Params, nil
 	case "failure":
-		var errResp struct {
-			Err Error
-		}
-		err = Unmarshal(resp.Params, &errResp)
+		// the params are the chain of errors, from the outermost one:
+		var errs []Error
+		err = Unmarshal(resp.Params, &errs)
 		if err != nil {
 			return Item{}, err
 		}
-		return Item{}, errResp.Err
+		if len(errs) == 0 {
+			return Item{}, fmt.Errorf("syntax error: failure without errors")
+		}
+		return Item{}, errs[0]
 	default:
 		return Item{}, fmt.Errorf("syntax error: response must be `success` or `failure`")
 	}
@@ -1,24 +1,222 @@
 package svn
 
 import (
+	"bytes"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
+	"os/user"
+	"slices"
+
+	"github.com/cespedes/svn/mergeinfo"
+	"github.com/cespedes/svn/svndiff"
 )
 
+// defaultUUID is the UUID of the repositories served
+// by a Server without a Greet function.
+const defaultUUID = "c5a7a7b1-3e3e-4c98-a541-f46ece210564"
+
 // A Server defines parameters for running a SVN server.
+//
+// The repository is served either by Repository or by the functions
+// of Server, which can be used to override some of its methods:
+// the functions which are nil are filled in by Serve, for every
+// connection, with the methods of Repository (if it is set) for the
+// [ServerSession] of that connection.  The commands whose function is
+// nil fail as unimplemented, and the capabilities announced to the
+// clients are only the ones provided by the available functions.
+//
+// Serve does not modify the Server, which can serve several
+// connections at the same time.
 type Server struct {
-	ReposInfo    ReposInfo
+	// Repository, if not nil, is the repository served.
+	Repository Repository
+	// ReposInfo is the information about the repository sent to the
+	// clients if Greet is nil.  If its UUID or URL are empty, a fixed
+	// UUID and the URL sent by the client are used.
+	ReposInfo ReposInfo
+	// Trace, if not nil, is called with every Item sent or received
+	// by the server (see [TraceWriter]).
+	Trace func(dir Direction, item Item)
+	// Authenticate, if not nil, returns the user of a connection, given
+	// the mechanism ("ANONYMOUS" or "EXTERNAL") and the token sent by the
+	// client in its "auth-response" (for EXTERNAL, the user it asserts to
+	// be, usually empty), or an error to reject it.  The user is empty for
+	// anonymous access.  If it is nil, anonymous access is allowed and,
+	// as in "svnserve -t", EXTERNAL authenticates the client as the user
+	// running the server, rejecting any other user.
+	Authenticate func(mech string, token string) (string, error)
 	Greet        func(version int, capabilities []string, url string, raclient string, client *string) (ReposInfo, error)
-	GetLatestRev func() (int, error)
-	Stat         func(path string, rev *uint) (Dirent, error)
-	CheckPath    func(path string, rev *uint) (string, error)
-	List         func(path string, rev *uint, depth string, fields []string, pattern []string) ([]Dirent, error)
-	GetFile      func(path string, rev *uint, wantProps bool, wantContents bool) (uint, []PropList, []byte, error)
-	Log          func(paths []string, startRev uint, endRev uint, changedPaths bool) ([]LogEntry, error)
-	Update       func(rev *uint, target string, recurse bool)
-	SetPath      func(path string, rev uint, startEmpty bool)
-	FinishReport func() ([]Item, error)
+	GetLatestRev func() (uint, error)
+	// Reparent changes the URL of the session, to which the paths
+	// in the other functions are relative.  The URL is checked to be
+	// in the repository (that is, under the URL of its root) before calling it.
+	Reparent func(url string) error
+	// Stat returns the status of a path, with a Kind of NodeNone
+	// if it does not exist.
+	Stat      func(path string, rev *uint) (Stat, error)
+	CheckPath func(path string, rev *uint) (NodeKind, error)
+	List      func(path string, rev *uint, depth string, fields []string, pattern []string) ([]Dirent, error)
+	GetFile   func(path string, rev *uint, wantProps bool, wantContents bool) (uint, Props, []byte, error)
+	// GetFileReader is an alternative to GetFile which allows sending
+	// big files without keeping them in memory.  It returns the revision,
+	// size, checksum and properties of the file, and a reader with its
+	// contents (if wantContents is true).  The reader will be closed after
+	// being used if it implements io.Closer.  If the checksum is empty,
+	// it is computed by the server only if the reader implements io.Seeker.
+	GetFileReader func(path string, rev *uint, wantProps bool, wantContents bool) (File, io.Reader, error)
+	GetDir        func(path string, rev *uint, wantProps bool, wantContents bool, fields []string) (Dir, error)
+	GetIProps     func(path string, rev *uint) (InheritedProps, error)
+	// GetDeletedRev returns the revision in which path (as it was in
+	// pegRev) was deleted, between pegRev and endRev, or nil if it was
+	// not deleted.
+	GetDeletedRev func(path string, pegRev uint, endRev uint) (*uint, error)
+	// GetLocations returns the paths that the node in path@pegRev
+	// had in each of the revisions in revs.
+	GetLocations        func(path string, pegRev uint, revs []uint) (map[uint]string, error)
+	GetLocationSegments func(path string, pegRev *uint, startRev *uint, endRev *uint) ([]LocationSegment, error)
+	// GetFileRevs returns the revisions in which a file changed between
+	// startRev and endRev (in reverse order if startRev > endRev),
+	// with the full contents of the file in each of them.
+	GetFileRevs func(path string, startRev *uint, endRev *uint, includeMerged bool) ([]FileRev, error)
+	// GetMergeinfo returns the mergeinfo of some paths, indexed by path,
+	// as described in [Client.GetMergeinfo].  If it is set, the
+	// "mergeinfo" capability is announced to the clients.
+	GetMergeinfo func(paths []string, rev *uint, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error)
+	// Lock locks a path, failing if it is already locked (unless steal
+	// is true) or if it was changed after currentRev (if not nil).
+	Lock func(path string, comment string, steal bool, currentRev *uint) (Lock, error)
+	// Unlock removes the lock on a path, if token matches it
+	// (or always, if breakLock is true).
+	Unlock func(path string, token string, breakLock bool) error
+	// GetLock returns the lock on a path, or nil if it is not locked.
+	GetLock func(path string) (*Lock, error)
+	// GetLocks returns the locks on a path and its children,
+	// up to the given depth ("empty", "files", "immediates" or "infinity").
+	GetLocks func(path string, depth string) ([]Lock, error)
+	// Commit creates a new revision with the given revision properties
+	// (including the log message).  It must call edit with an Editor,
+	// which receives the changes sent by the client; the new revision
+	// is created when its CloseEdit method is called.  After edit returns
+	// (with an error if the edit was aborted), Commit returns
+	// the information about the new revision.
+	//
+	// lockTokens are the tokens of the locks on the changed paths,
+	// indexed by path, and should be released after the commit
+	// unless keepLocks is true.
+	Commit func(revProps Props, lockTokens map[string]string, keepLocks bool, edit func(Editor) error) (CommitInfo, error)
+	Log    func(paths []string, startRev uint, endRev uint, changedPaths bool) ([]LogEntry, error)
+	// Update sends to e the changes needed to bring the working copy
+	// described by report to its target, with paths relative to the URL
+	// of the session (starting with TargetRev and OpenRoot, and without
+	// calling CloseEdit, which is called by Serve).  It answers the
+	// "update", "switch", "status" and "diff" commands.
+	Update func(report *Report, e Editor) error
+	// RevProps returns the properties of a revision.
+	RevProps func(rev uint) (Props, error)
+	// Replay sends to e the changes made in a revision under the URL
+	// of the session, with paths relative to it (starting with OpenRoot
+	// and without calling CloseEdit, which is called by Serve).  Only the
+	// changes to paths whose previous revision is at least lowWater need
+	// to be sent as such; the others are sent as copies.  If sendDeltas
+	// is false, the text deltas are empty.
+	Replay func(rev uint, lowWater uint, sendDeltas bool, e Editor) error
+
+	// session is the state of the connection, in the copy
+	// of the Server which serves it (see connection).
+	session *ServerSession
+	// info is the information about the repository sent to the client.
+	info ReposInfo
+	// greeting is the greeting of the client.
+	greeting *greeting
+	// auth is the "auth-response" of the client.
+	auth *authResponse
+	// resolve, if not nil, returns the Server for a URL
+	// in another repository (see MultiServer).
+	resolve func(url string) (*Server, error)
+}
+
+// baseCapabilities returns the capabilities of every server,
+// whatever functions it has.
+func baseCapabilities() []string {
+	return []string{"edit-pipeline", "svndiff1", "accepts-svndiff2", "absent-entries", "depth"}
+}
+
+// capabilities returns the capabilities announced by the server,
+// which depend on the functions it implements.
+func (s *Server) capabilities() []string {
+	caps := baseCapabilities()
+	if s.Commit != nil {
+		caps = append(caps, "commit-revprops")
+	}
+	if s.Replay != nil {
+		caps = append(caps, "partial-replay")
+	}
+	if s.GetIProps != nil {
+		caps = append(caps, "inherited-props")
+	}
+	if s.GetFileRevs != nil {
+		caps = append(caps, "file-revs-reverse")
+	}
+	if s.List != nil {
+		caps = append(caps, "list")
+	}
+	if s.GetMergeinfo != nil {
+		caps = append(caps, "mergeinfo")
+	}
+	return caps
+}
+
+// connection returns the Server which serves a connection: a copy of s
+// with a new session and, if s has a Repository, its functions which
+// are nil filled in with the methods of the Repository for that session.
+// The state of the connection is kept in the copy, and never in s.
+func (s *Server) connection() *Server {
+	c := *s
+	c.session = &ServerSession{}
+	c.greeting = nil
+	c.auth = nil
+	if c.Repository != nil {
+		c.useRepository(c.session)
+	}
+	return &c
+}
+
+// greet is called when a client connects to url, and sets the session
+// and the information about the repository with the result of Greet
+// or, if it is nil, with ReposInfo.
+func (s *Server) greet(version int, capabilities []string, url string, raclient string, client *string) error {
+	sess := s.session
+	sess.URL = url
+	sess.Capabilities = capabilities
+	sess.RAClient = raclient
+	if client != nil {
+		sess.Client = *client
+	}
+	var info ReposInfo
+	if s.Greet != nil {
+		var err error
+		if info, err = s.Greet(version, capabilities, url, raclient, client); err != nil {
+			return err
+		}
+	} else {
+		info = s.ReposInfo
+		if info.UUID == "" {
+			info.UUID = defaultUUID
+		}
+		if info.URL == "" {
+			info.URL = url
+		}
+	}
+	info.Capabilities = slices.Clone(info.Capabilities)
+	if info.Capabilities == nil {
+		info.Capabilities = make([]string, 0)
+	}
+	s.info = info
+	sess.Root = info.URL
+	sess.UUID = info.UUID
+	return nil
 }
 
 // Serve sends and receives SVN messages against a client,
@@ -27,100 +225,129 @@ type Server struct {
 //
 // Serve returns if there is an error, or after the end of the connection.
 func (s *Server) Serve(r io.Reader, w io.Writer) error {
-	conn := conn{
-		r: r,
-		w: w,
+	conn := &conn{
+		r:     r,
+		w:     w,
+		trace: s.Trace,
 	}
+	s = s.connection()
+	capabilities := s.capabilities()
+	g, err := readGreeting(conn, capabilities)
+	if err != nil {
+		return err
+	}
+	if err = s.start(conn, g, capabilities); err != nil {
+		return err
+	}
+	return serveCommands(conn, s)
+}
 
-	var err error
-	var item Item
+// A greeting is the response of the client to the greeting of the server:
+//
+//	( version:number ( cap:word ... ) url:string
+//	  ? ra-client:string ( ? client:string ) )
+type greeting struct {
+	Version      int
+	Capabilities []string
+	URL          string
+	RAClient     string
+	Client       []string
+}
 
-	err = conn.WriteSuccess([]any{
+// readGreeting sends the greeting of the server, announcing
+// its capabilities, and reads the response of the client.
+func readGreeting(conn *conn, capabilities []string) (*greeting, error) {
+	err := conn.WriteSuccess([]any{
 		SvnVersion,
 		SvnVersion,
 		[]any{},
-		[]any{
-			"edit-pipeline",
-			"svndiff1",
-			"accepts-svndiff2",
-			"absent-entries",
-			"commit-revprops",
-			"depth",
-			"log-revprops",
-			"atomic-revprops",
-			"partial-replay",
-			"inherited-props",
-			"ephemeral-txnprops",
-			"file-revs-reverse",
-			"list",
-		},
+		capabilities,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var g greeting
+	if err = conn.Read(&g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
 
-	var greet struct {
-		Version      int
-		Capabilities []string
-		URL          string
-		RAClient     string
-		Client       []string
+// greetClient calls greet with the greeting of the client,
+// which is kept to greet other servers after a reparent.
+func (s *Server) greetClient(g *greeting, url string) error {
+	s.greeting = g
+	var pclient *string
+	if len(g.Client) > 0 {
+		pclient = &g.Client[0]
 	}
+	return s.greet(g.Version, g.Capabilities, url, g.RAClient, pclient)
+}
 
-	err = conn.Read(&greet)
-	if err != nil {
+// start greets the client and authenticates it, and then sends
+// the information about the repository, including the capabilities
+// of s which were not announced in the greeting.
+func (s *Server) start(conn *conn, g *greeting, announced []string) error {
+	if err := s.greetClient(g, g.URL); err != nil {
+		conn.WriteFailure(err)
 		return err
 	}
-	if s.Greet != nil {
-		var pclient *string
-		if len(greet.Client) > 0 {
-			pclient = &greet.Client[0]
-		}
-		s.ReposInfo, err = s.Greet(greet.Version, greet.Capabilities, greet.URL, greet.RAClient, pclient)
-		if err != nil {
-			conn.WriteFailure(err)
-			return err
-		}
-	} else {
-		s.ReposInfo.UUID = "c5a7a7b1-3e3e-4c98-a541-f46ece210564"
-		s.ReposInfo.URL = greet.URL
-		s.ReposInfo.Capabilities = make([]string, 0)
-	}
 
 	// Sending "auth-request":
-	err = conn.WriteSuccess([]any{
+	err := conn.WriteSuccess([]any{
 		[]any{
 			"ANONYMOUS",
 			"EXTERNAL",
 		},
-		[]byte(s.ReposInfo.UUID),
+		[]byte(s.info.UUID),
 	})
 	if err != nil {
 		return err
 	}
 
 	// Reading "auth-response" from client
-	err = conn.Read(&item)
+	var auth authResponse
+	err = conn.Read(&auth)
 	if err != nil {
 		return err
 	}
-
-	// no matter what "auth-response" the client sent, we always reply success
+	if err = s.authenticate(auth); err != nil {
+		conn.Write([]any{"failure", []any{[]byte(errorMessage(err))}})
+		return err
+	}
 	err = conn.WriteSuccess([]any{})
 	if err != nil {
 		return err
 	}
 
 	// and finally, we send a command response with UUID, URL and capabilities:
-	err = conn.WriteSuccess([]any{
-		[]byte(s.ReposInfo.UUID),
-		[]byte(s.ReposInfo.URL),
-		s.ReposInfo.Capabilities,
+	for _, capability := range s.capabilities() {
+		if !slices.Contains(announced, capability) && !slices.Contains(s.info.Capabilities, capability) {
+			s.info.Capabilities = append(s.info.Capabilities, capability)
+		}
+	}
+	return conn.WriteSuccess([]any{
+		[]byte(s.info.UUID),
+		[]byte(s.info.URL),
+		s.info.Capabilities,
 	})
-	if err != nil {
-		return err
+}
+
+// serveCommands runs the commands of the client with s, and then with
+// the Server of every other repository the client reparents to.
+func serveCommands(conn *conn, s *Server) error {
+	var err error
+	for s != nil && err == nil {
+		s, err = s.commands(conn)
 	}
+	return err
+}
 
+// commands runs the commands of the client until the end of the
+// connection, or until it reparents to another repository, whose
+// Server is returned.
+func (s *Server) commands(conn *conn) (*Server, error) {
+	var err error
 	for {
 		var item Item
 		var command struct {
@@ -129,11 +356,11 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 		}
 		err = conn.Read(&item)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		err = Unmarshal(item, &command)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		neterr := Error{
 			AprErr:  210004,
@@ -154,6 +381,68 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 			// empty auth-request:
 			conn.WriteSuccess([]any{[]any{}, []byte{}})
 			conn.WriteSuccess([]any{rev})
+		case "reparent":
+			// params: ( url:string )
+			if s.Reparent == nil && s.resolve == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				URL string
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			if _, ok := relativeURL(s.info.URL, args.URL); !ok {
+				if s.resolve == nil {
+					conn.WriteFailure(Error{
+						AprErr:  170000, // SVN_ERR_RA_ILLEGAL_URL
+						Message: fmt.Sprintf("URL '%s' is not a child of the session's repository root URL '%s'", args.URL, s.info.URL),
+					})
+					continue
+				}
+				// the rest of the connection is served by the Server
+				// of the other repository, which authenticates the
+				// client again with the same "auth-response".
+				next, err := s.resolve(args.URL)
+				if err == nil {
+					err = next.authenticate(*s.auth)
+				}
+				if err == nil {
+					err = next.greetClient(s.greeting, args.URL)
+				}
+				if err != nil {
+					conn.WriteFailure(err)
+					continue
+				}
+				for _, capability := range next.capabilities() {
+					if !slices.Contains(next.info.Capabilities, capability) {
+						next.info.Capabilities = append(next.info.Capabilities, capability)
+					}
+				}
+				// empty auth-request, and the information about the
+				// other repository (see Client.Reparent):
+				conn.WriteSuccess([]any{[]any{}, []byte{}})
+				conn.WriteSuccess([]any{
+					[]byte(next.info.UUID),
+					[]byte(next.info.URL),
+					next.info.Capabilities,
+				})
+				return next, nil
+			}
+			if s.Reparent == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			if err = s.Reparent(args.URL); err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			s.session.URL = args.URL
+			// empty auth-request:
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{})
 		case "stat":
 			// params: ( path:string [ rev:number ] )
 			if s.Stat == nil {
@@ -174,6 +463,10 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 				continue
 			}
 			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			if entry.Kind == NodeNone {
+				conn.WriteSuccess([]any{[]any{}})
+				continue
+			}
 			conn.WriteSuccess([]any{[]any{[]any{
 				entry.Kind,
 				entry.Size,
@@ -239,9 +532,146 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 			}
 			conn.WriteSuccess([]any{[]any{}, []byte{}})
 			conn.WriteSuccess([]any{kind})
+		case "get-deleted-rev":
+			// params: ( path:string peg-rev:number end-rev:number )
+			if s.GetDeletedRev == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path   string
+				PegRev uint
+				EndRev uint
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			rev, err := s.GetDeletedRev(args.Path, args.PegRev, args.EndRev)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			if rev == nil {
+				// the protocol does not allow to send an invalid revision:
+				conn.WriteFailure(Error{
+					AprErr:  150003, // SVN_ERR_ENTRY_MISSING_REVISION
+					Message: fmt.Sprintf("No deletion found for '%s' between revisions %d and %d", args.Path, args.PegRev, args.EndRev),
+				})
+				continue
+			}
+			conn.WriteSuccess([]any{*rev})
+		case "get-locations":
+			// params: ( path:string peg-rev:number ( rev:number ... ) )
+			if s.GetLocations == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path   string
+				PegRev uint
+				Revs   []uint
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			locations, err := s.GetLocations(args.Path, args.PegRev, args.Revs)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			revs := make([]uint, 0, len(locations))
+			for rev := range locations {
+				revs = append(revs, rev)
+			}
+			slices.Sort(revs)
+			for _, rev := range revs {
+				conn.Write([]any{rev, []byte(locations[rev])})
+			}
+			conn.Write("done")
+			conn.WriteSuccess([]any{})
+		case "get-location-segments":
+			// params: ( path:string [ peg-rev:number ] [ start-rev:number ] [ end-rev:number ] )
+			if s.GetLocationSegments == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path     string
+				PegRev   *uint
+				StartRev *uint
+				EndRev   *uint
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			segments, err := s.GetLocationSegments(args.Path, args.PegRev, args.StartRev, args.EndRev)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			for _, seg := range segments {
+				path := []any{}
+				if seg.Path != "" {
+					path = append(path, []byte(seg.Path))
+				}
+				conn.Write([]any{seg.RangeStart, seg.RangeEnd, path})
+			}
+			conn.Write("done")
+			conn.WriteSuccess([]any{})
+		case "get-file-revs":
+			// params: ( path:string [ start-rev:number ] [ end-rev:number ] ? include-merged-revisions:bool )
+			if s.GetFileRevs == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path          string
+				StartRev      *uint
+				EndRev        *uint
+				IncludeMerged bool
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			revs, err := s.GetFileRevs(args.Path, args.StartRev, args.EndRev, args.IncludeMerged)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			version := 0
+			if slices.Contains(s.greeting.Capabilities, "svndiff1") {
+				version = 1
+			}
+			var prev []byte
+			for i, rev := range revs {
+				conn.Write([]any{
+					[]byte(rev.Path),
+					rev.Rev,
+					rev.RevProps,
+					rev.PropDelta,
+					rev.MergedRevision,
+				})
+				if i == 0 || !bytes.Equal(prev, rev.Contents) {
+					delta := svndiff.NewEncoder(chunkWriter{*conn}, bytes.NewReader(prev), version)
+					delta.Write(rev.Contents)
+					delta.Close()
+				}
+				conn.Write([]byte{})
+				prev = rev.Contents
+			}
+			conn.Write("done")
+			conn.WriteSuccess([]any{})
 		case "get-file":
 			// params: ( path:string [ rev:number ] want-props:bool want-contents:bool ? want-iprops:bool )
-			if s.GetFile == nil {
+			if s.GetFile == nil && s.GetFileReader == nil {
 				replyUnimplemented(conn, command.Name)
 				continue
 			}
@@ -250,24 +680,338 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 				Rev          *uint
 				WantProps    bool
 				WantContents bool
+				WantIProps   bool
 			}
 			if err = Unmarshal(command.Params, &args); err != nil {
 				conn.WriteFailure(neterr)
 				continue
 			}
-			rev, proplist, contents, err := s.GetFile(args.Path, args.Rev, args.WantProps, args.WantContents)
+			file, r, err := s.getFile(args.Path, args.Rev, args.WantProps, args.WantContents)
 			if err != nil {
 				conn.WriteFailure(err)
 				continue
 			}
-			checksum := []byte(fmt.Sprintf("%x", md5.Sum(contents)))
+			iprops, err := s.inheritedProps(args.WantIProps, args.Path, &file.Rev)
+			if err != nil {
+				closeReader(r)
+				conn.WriteFailure(err)
+				continue
+			}
+			checksum := []any{}
+			if file.Checksum != "" {
+				checksum = append(checksum, []byte(file.Checksum))
+			}
 			conn.WriteSuccess([]any{[]any{}, []byte{}})
-			conn.WriteSuccess([]any{[]any{checksum}, rev, proplist})
+			conn.WriteSuccess([]any{checksum, file.Rev, file.Props, iprops})
 			if args.WantContents {
-				conn.Write(contents)
+				err = sendFileContents(*conn, r, file.Size)
+				closeReader(r)
 				conn.Write([]byte{})
+				if err != nil {
+					conn.WriteFailure(err)
+					continue
+				}
 				conn.WriteSuccess([]any{})
 			}
+		case "get-dir":
+			// params: ( path:string [ rev:number ] want-props:bool want-contents:bool ? ( field:dirent-field ... ) ? want-iprops:bool )
+			if s.GetDir == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path         string
+				Rev          *uint
+				WantProps    bool
+				WantContents bool
+				Fields       []string
+				WantIProps   bool
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			dir, err := s.GetDir(args.Path, args.Rev, args.WantProps, args.WantContents, args.Fields)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			iprops, err := s.inheritedProps(args.WantIProps, args.Path, &dir.Rev)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			entries := []any{}
+			for _, d := range dir.Entries {
+				entries = append(entries, []any{
+					[]byte(d.Path),
+					d.Kind,
+					d.Size,
+					d.HasProps,
+					d.CreatedRev,
+					[]any{[]byte(d.CreatedDate)},
+					[]any{[]byte(d.LastAuthor)},
+				})
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{dir.Rev, dir.Props, entries, iprops})
+		case "get-iprops":
+			// params: ( path:string [ rev:number ] )
+			if s.GetIProps == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path string
+				Rev  *uint
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			iprops, err := s.GetIProps(args.Path, args.Rev)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{iprops})
+		case "get-mergeinfo":
+			// params: ( ( path:string ... ) [ rev:number ] inherit:word descendants:bool )
+			if s.GetMergeinfo == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Paths       []string
+				Rev         *uint
+				Inherit     string
+				Descendants bool
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			catalog, err := s.GetMergeinfo(args.Paths, args.Rev, args.Inherit, args.Descendants)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			paths := make([]string, 0, len(catalog))
+			for p := range catalog {
+				paths = append(paths, p)
+			}
+			slices.Sort(paths)
+			entries := make([]any, 0, len(paths))
+			for _, p := range paths {
+				entries = append(entries, []any{[]byte(p), []byte(catalog[p].String())})
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{entries})
+		case "lock":
+			// params: ( path:string [ comment:string ] steal-lock:bool [ current-rev:number ] )
+			if s.Lock == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path       string
+				Comment    []string
+				Steal      bool
+				CurrentRev *uint
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			lock, err := s.Lock(args.Path, firstString(args.Comment), args.Steal, args.CurrentRev)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{lock})
+		case "lock-many":
+			// params: ( [ comment:string ] steal-lock:bool ( ( path:string [ current-rev:number ] ) ... ) )
+			if s.Lock == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Comment []string
+				Steal   bool
+				Targets []struct {
+					Path       string
+					CurrentRev *uint
+				}
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			for _, t := range args.Targets {
+				lock, err := s.Lock(t.Path, firstString(args.Comment), args.Steal, t.CurrentRev)
+				if err != nil {
+					conn.WriteFailure(err)
+					continue
+				}
+				conn.WriteSuccess([]any{lock})
+			}
+			conn.Write("done")
+			conn.WriteSuccess([]any{})
+		case "unlock":
+			// params: ( path:string [ token:string ] break-lock:bool )
+			if s.Unlock == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path      string
+				Token     []string
+				BreakLock bool
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			if err = s.Unlock(args.Path, firstString(args.Token), args.BreakLock); err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{})
+		case "unlock-many":
+			// params: ( break-lock:bool ( ( path:string [ token:string ] ) ... ) )
+			if s.Unlock == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				BreakLock bool
+				Targets   []struct {
+					Path  string
+					Token []string
+				}
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			for _, t := range args.Targets {
+				if err := s.Unlock(t.Path, firstString(t.Token), args.BreakLock); err != nil {
+					conn.WriteFailure(err)
+					continue
+				}
+				conn.WriteSuccess([]any{[]byte(t.Path)})
+			}
+			conn.Write("done")
+			conn.WriteSuccess([]any{})
+		case "get-lock":
+			// params: ( path:string )
+			if s.GetLock == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path string
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			lock, err := s.GetLock(args.Path)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{[]any{lock}})
+		case "get-locks":
+			// params: ( path:string ? [ depth:word ] )
+			if s.GetLocks == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Path  string
+				Depth []string
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			depth := firstString(args.Depth)
+			if depth == "" {
+				depth = "infinity"
+			}
+			locks, err := s.GetLocks(args.Path, depth)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			if locks == nil {
+				locks = []Lock{}
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{locks})
+		case "commit":
+			// params: ( logmsg:string ? ( ( lock-path:string lock-token:string ) ... ) keep-locks:bool ? rev-props:proplist )
+			if s.Commit == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Message    string
+				LockTokens []struct {
+					Path  string
+					Token string
+				}
+				KeepLocks bool
+				RevProps  Props
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			revProps := Props{}
+			for name, value := range args.RevProps {
+				revProps[name] = value
+			}
+			revProps[PropRevLog] = args.Message
+			lockTokens := make(map[string]string)
+			for _, l := range args.LockTokens {
+				lockTokens[l.Path] = l.Token
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{})
+			edited := false
+			info, err := s.Commit(revProps, lockTokens, args.KeepLocks, func(e Editor) error {
+				edited = true
+				return driveEditor(conn, e)
+			})
+			if errors.Is(err, errEditAborted) {
+				continue
+			}
+			if err != nil {
+				conn.WriteFailure(err)
+				if !edited {
+					// the client has sent the whole edit, and waits
+					// for a response before aborting it:
+					if err = drainEditor(conn); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.Write([]any{
+				info.Rev,
+				optString(info.Date),
+				optString(info.Author),
+				optString(info.PostCommitErr),
+			})
 		case "log":
 			// params: ( ( target-path:string ... ) [ start-rev:number ] [ end-rev:number ] changed-paths:bool strict-node:bool ? limit:number ? include-merged-revisions:bool all-revprops | revprops ( revprop:string ... ) )
 			if s.Log == nil {
@@ -276,8 +1020,8 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 			}
 			var args struct {
 				Paths                  []string
-				StartRev               uint
-				EndRev                 uint
+				StartRev               *uint
+				EndRev                 *uint
 				ChangedPaths           bool
 				StrictNode             bool
 				Limit                  int
@@ -289,16 +1033,38 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 				conn.WriteFailure(neterr)
 				continue
 			}
-			logEntries, err := s.Log(args.Paths, args.StartRev, args.EndRev, args.ChangedPaths)
+			// missing revisions mean HEAD:
+			var head uint
+			if (args.StartRev == nil || args.EndRev == nil) && s.GetLatestRev != nil {
+				if head, err = s.GetLatestRev(); err != nil {
+					conn.WriteFailure(err)
+					continue
+				}
+			}
+			if args.StartRev == nil {
+				args.StartRev = &head
+			}
+			if args.EndRev == nil {
+				args.EndRev = &head
+			}
+			logEntries, err := s.Log(args.Paths, *args.StartRev, *args.EndRev, args.ChangedPaths)
 			if err != nil {
 				conn.WriteFailure(err)
 				continue
 			}
+			if args.Limit > 0 && len(logEntries) > args.Limit {
+				logEntries = logEntries[:args.Limit]
+			}
 			conn.WriteSuccess([]any{[]any{}, []byte{}})
 			for _, l := range logEntries {
 				// ( ( ) 7573 ( 3:noc ) ( 27:2024-04-02T13:37:34.350221Z ) ( 43:New open position: 2024-04-phd-visiting-apt ) false false 0 ( ) false )
+				// changed: ( path:string A|D|R|M ( ? copy-path:string copy-rev:number ) ( ? node-kind:string ? text-mods:bool prop-mods:bool ) )
+				changed := make([]any, 0, len(l.Changed))
+				for _, c := range l.Changed {
+					changed = append(changed, []any{[]byte(c.Path), c.Mode, []any{}, []any{}})
+				}
 				conn.Write([]any{
-					l.Changed,
+					changed,
 					l.Rev,
 					[]any{[]byte(l.Author)},
 					[]any{[]byte(l.Date)},
@@ -307,61 +1073,153 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 			}
 			conn.Write("done")
 			conn.WriteSuccess([]any{})
-		case "update":
-			if s.Update == nil {
+		case "rev-proplist":
+			// params: ( rev:number )
+			if s.RevProps == nil {
 				replyUnimplemented(conn, command.Name)
 				continue
 			}
 			var args struct {
-				Rev     *uint
-				Target  string
-				Recurse bool
+				Rev uint
 			}
 			if err = Unmarshal(command.Params, &args); err != nil {
 				conn.WriteFailure(neterr)
 				continue
 			}
-			// empty auth-request:
+			props, err := s.RevProps(args.Rev)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
 			conn.WriteSuccess([]any{[]any{}, []byte{}})
-		case "set-path": // From the Report Command Set
-			if s.SetPath == nil {
+			conn.WriteSuccess([]any{props})
+		case "rev-prop":
+			// params: ( rev:number name:string )
+			if s.RevProps == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
+			}
+			var args struct {
+				Rev  uint
+				Name string
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
+			props, err := s.RevProps(args.Rev)
+			if err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			value := []any{}
+			if v, ok := props[args.Name]; ok {
+				value = append(value, []byte(v))
+			}
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			conn.WriteSuccess([]any{value})
+		case "replay":
+			// params: ( revision:number low-water-mark:number send-deltas:bool )
+			if s.Replay == nil {
 				replyUnimplemented(conn, command.Name)
 				continue
 			}
 			var args struct {
-				Path       string
 				Rev        uint
-				StartEmpty bool
+				LowWater   uint
+				SendDeltas bool
 			}
 			if err = Unmarshal(command.Params, &args); err != nil {
 				conn.WriteFailure(neterr)
 				continue
 			}
-			// no response in set-path
-		case "finish-report": // From the Report Command Set
-			if s.FinishReport == nil {
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			if err = s.replay(conn, args.Rev, args.LowWater, args.SendDeltas); err != nil {
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{})
+		case "replay-range":
+			// params: ( start-rev:number end-rev:number low-water-mark:number send-deltas:bool )
+			if s.Replay == nil || s.RevProps == nil {
 				replyUnimplemented(conn, command.Name)
 				continue
 			}
-			// no response?
+			var args struct {
+				StartRev   uint
+				EndRev     uint
+				LowWater   uint
+				SendDeltas bool
+			}
+			if err = Unmarshal(command.Params, &args); err != nil {
+				conn.WriteFailure(neterr)
+				continue
+			}
 			conn.WriteSuccess([]any{[]any{}, []byte{}})
-			items, err := s.FinishReport()
+			for rev := args.StartRev; rev <= args.EndRev && err == nil; rev++ {
+				var props Props
+				if props, err = s.RevProps(rev); err != nil {
+					break
+				}
+				conn.Write([]any{"revprops", props})
+				err = s.replay(conn, rev, args.LowWater, args.SendDeltas)
+			}
 			if err != nil {
-				conn.Write([]any{"abort-edit", []any{}})
+				conn.WriteFailure(err)
 				continue
 			}
-			for _, i := range items {
-				conn.Write(i)
+			conn.WriteSuccess([]any{})
+		case "update", "switch", "status", "diff":
+			if s.Update == nil {
+				replyUnimplemented(conn, command.Name)
+				continue
 			}
-			conn.Write([]any{"close-edit", []any{}})
-			err = conn.ReadResponse(&item)
+			report, url, err := newReport(command.Name, command.Params)
 			if err != nil {
-				return err
+				conn.WriteFailure(neterr)
+				continue
 			}
-			err = conn.WriteSuccess([]any{})
-			if err != nil {
-				return err
+			if url != "" {
+				p, ok := relativeURL(s.info.URL, url)
+				if !ok {
+					conn.WriteFailure(Error{
+						AprErr:  170000, // SVN_ERR_RA_ILLEGAL_URL
+						Message: fmt.Sprintf("'%s' is not the same repository as '%s'", url, s.info.URL),
+					})
+					continue
+				}
+				report.DstPath = p
 			}
+			// empty auth-request:
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			err = readReport(conn, s.info.URL, report)
+			var svnErr Error
+			switch {
+			case errors.Is(err, errReportAborted):
+				// no response in abort-report
+				continue
+			case errors.As(err, &svnErr):
+				conn.WriteFailure(err)
+				continue
+			case err != nil:
+				return nil, err
+			}
+			// empty auth-request after finish-report:
+			conn.WriteSuccess([]any{[]any{}, []byte{}})
+			e := editorSender{conn: conn}
+			if err = s.Update(report, e); err != nil {
+				e.AbortEdit()
+				conn.WriteFailure(err)
+				continue
+			}
+			if err = e.CloseEdit(); err != nil {
+				if !errors.As(err, &svnErr) {
+					return nil, err
+				}
+				conn.WriteFailure(err)
+				continue
+			}
+			conn.WriteSuccess([]any{})
 		default:
 			conn.WriteFailure(Error{
 				AprErr:  210001,
@@ -373,7 +1231,190 @@ func (s *Server) Serve(r io.Reader, w io.Writer) error {
 	}
 }
 
-func replyUnimplemented(conn conn, cmd string) {
+// replay sends the changes made in a revision as editor commands,
+// closing the edit, or aborting it if Replay fails.
+func (s *Server) replay(conn *conn, rev uint, lowWater uint, sendDeltas bool) error {
+	e := editorSender{conn: conn}
+	if err := s.Replay(rev, lowWater, sendDeltas, e); err != nil {
+		e.AbortEdit()
+		return err
+	}
+	return e.CloseEdit()
+}
+
+// fileChunkSize is the maximum size of every string
+// used to send the contents of a file.
+const fileChunkSize = 16384
+
+// getFile calls GetFileReader, or GetFile if the former is not available,
+// filling in the checksum if possible.
+func (s *Server) getFile(path string, rev *uint, wantProps bool, wantContents bool) (File, io.Reader, error) {
+	if s.GetFileReader == nil {
+		rev, props, contents, err := s.GetFile(path, rev, wantProps, wantContents)
+		if err != nil {
+			return File{}, nil, err
+		}
+		file := File{
+			Rev:   rev,
+			Size:  uint64(len(contents)),
+			Props: props,
+		}
+		if wantContents {
+			file.Checksum = fmt.Sprintf("%x", md5.Sum(contents))
+		}
+		return file, bytes.NewReader(contents), nil
+	}
+	file, r, err := s.GetFileReader(path, rev, wantProps, wantContents)
+	if err != nil {
+		return File{}, nil, err
+	}
+	if wantContents && r == nil {
+		return File{}, nil, fmt.Errorf("no contents for file '%s'", path)
+	}
+	if rs, ok := r.(io.ReadSeeker); ok && wantContents && file.Checksum == "" {
+		file.Checksum, err = md5Reader(rs)
+		if err != nil {
+			closeReader(r)
+			return File{}, nil, err
+		}
+	}
+	return file, r, nil
+}
+
+// sendFileContents sends the contents of a file in chunks,
+// without the final empty string.  If size is not zero,
+// the file must have exactly that size.
+func sendFileContents(conn conn, r io.Reader, size uint64) error {
+	var sent uint64
+	buf := make([]byte, fileChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if werr := conn.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			sent += uint64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if size != 0 && sent != size {
+		return fmt.Errorf("file size mismatch: expected %d bytes, sent %d", size, sent)
+	}
+	return nil
+}
+
+// chunkWriter sends every slice written to it as a string.
+type chunkWriter struct {
+	conn conn
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := w.conn.Write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// closeReader closes r, if it is an io.Closer.
+func closeReader(r io.Reader) {
+	if c, ok := r.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// inheritedProps returns the optional "inherited-props" element
+// of the "get-file" and "get-dir" responses: an empty list if they
+// were not requested or cannot be computed, or a list with the
+// properties inherited by path in revision rev.
+func (s *Server) inheritedProps(want bool, path string, rev *uint) ([]any, error) {
+	if !want || s.GetIProps == nil {
+		return []any{}, nil
+	}
+	iprops, err := s.GetIProps(path, rev)
+	if err != nil {
+		return nil, err
+	}
+	return []any{iprops}, nil
+}
+
+// firstString returns the value of an optional string
+// (a list with zero or one elements).
+// authResponse is the "auth-response" of a client:
+// ( mech:word [ token:string ] ).
+type authResponse struct {
+	Mech  string
+	Token []string
+}
+
+// authenticate sets the user of the session with the result of
+// Authenticate (or the default authentication, if it is nil),
+// and keeps the response to authenticate the client with other
+// servers after a reparent.
+func (s *Server) authenticate(auth authResponse) error {
+	s.auth = &auth
+	authenticate := s.Authenticate
+	if authenticate == nil {
+		authenticate = externalAuth
+	}
+	user, err := authenticate(auth.Mech, firstString(auth.Token))
+	if err != nil {
+		return err
+	}
+	s.session.User = user
+	return nil
+}
+
+// externalAuth is the default authentication of a Server: anonymous,
+// or EXTERNAL as the user running the server (or anonymous, if it
+// cannot be known).
+func externalAuth(mech string, token string) (string, error) {
+	switch mech {
+	case "ANONYMOUS":
+		return "", nil
+	case "EXTERNAL":
+		var name string
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+		if token != "" && token != name {
+			return "", Error{
+				AprErr:  170001, // SVN_ERR_RA_NOT_AUTHORIZED
+				Message: fmt.Sprintf("Cannot authenticate as '%s'", token),
+			}
+		}
+		return name, nil
+	}
+	return "", Error{
+		AprErr:  170001, // SVN_ERR_RA_NOT_AUTHORIZED
+		Message: "Must authenticate with listed mechanism",
+	}
+}
+
+// errorMessage returns the message of an error,
+// without the code if it is an Error.
+func errorMessage(err error) string {
+	if e, ok := err.(Error); ok {
+		return e.Message
+	}
+	return err.Error()
+}
+
+func firstString(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0]
+}
+
+func replyUnimplemented(conn *conn, cmd string) {
 	conn.WriteFailure(Error{
 		AprErr:  210001,
 		Message: fmt.Sprintf("Command '%s' unimplemented", cmd),
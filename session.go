@@ -0,0 +1,176 @@
+package svn
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/cespedes/svn/mergeinfo"
+)
+
+// A Session is a connection to a repository, as returned by [Open].
+// The paths used in its methods are relative to its URL.
+//
+// [*Client] implements Session for the SVN protocol; see its methods
+// for the description of every operation.
+type Session interface {
+	// ReposInfo returns the UUID, root URL and capabilities
+	// of the repository.
+	ReposInfo() ReposInfo
+	URL() string
+	HasCapability(capability string) bool
+	Reparent(url string) error
+	Close() error
+
+	GetLatestRev() (int, error)
+	Stat(path string, rev *int) (Stat, error)
+	CheckPath(path string, rev *int) (NodeKind, error)
+	GetDeletedRev(path string, pegRev int, endRev int) (int, error)
+	List(path string, rev *int, depth string, fields []string) ([]Dirent, error)
+	GetFile(path string, rev *int, wantProps bool, wantContent bool, wantIProps bool) (File, error)
+	GetFileTo(path string, rev *int, w io.Writer) (File, error)
+	GetDir(path string, rev *int, wantProps bool, wantContents bool, fields []string, wantIProps bool) (Dir, error)
+	GetIProps(path string, rev *int) (InheritedProps, error)
+	GetMergeinfo(paths []string, rev *int, inherit string, includeDescendants bool) (map[string]mergeinfo.Mergeinfo, error)
+	Log(paths []string, startRev *int, endRev *int, changedPaths bool) ([]LogEntry, error)
+	GetLocations(path string, pegRev int, revs []int) (map[int]string, error)
+	GetLocationSegments(path string, pegRev *int, startRev *int, endRev *int) ([]LocationSegment, error)
+	GetFileRevs(path string, startRev *int, endRev *int, includeMerged bool, fn func(FileRev) error) error
+
+	Lock(path string, comment string, steal bool, currentRev *int) (Lock, error)
+	Unlock(path string, token string, breakLock bool) error
+	GetLock(path string) (*Lock, error)
+	GetLocks(path string, depth string) ([]Lock, error)
+	LockMany(comment string, steal bool, paths map[string]*int) ([]LockResult, error)
+	UnlockMany(breakLock bool, tokens map[string]string) ([]LockResult, error)
+	Commit(message string, revProps Props, lockTokens map[string]string, keepLocks bool, fn func(Editor) error) (CommitInfo, error)
+	Update(report *Report, e Editor) error
+}
+
+var _ Session = (*Client)(nil)
+
+// A Driver opens sessions for the URLs of a scheme.
+type Driver func(ctx context.Context, address string, opts ...Option) (Session, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]Driver{
+		"file":  svnserveDriver,
+		"http":  davDriver,
+		"https": davDriver,
+	}
+)
+
+// RegisterScheme makes a driver available to open sessions for URLs
+// with the given scheme, replacing the previous one, if any.
+//
+// By default, "file" URLs are opened by running "svnserve -t" locally,
+// and tunnel schemes ("svn+ssh" and any other "svn+NAME") by running
+// the command defined for the tunnel (see [WithConfigDir]), which
+// is expected to invoke "svnserve -t" remotely.  "http" and "https"
+// URLs use the WebDAV-based protocol of mod_dav_svn (see [WithHTTPClient]).
+func RegisterScheme(scheme string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[scheme] = driver
+}
+
+// lookupDriver returns the driver for a scheme, or nil.
+func lookupDriver(scheme string) Driver {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	if driver, ok := drivers[scheme]; ok {
+		return driver
+	}
+	if strings.HasPrefix(scheme, "svn+") {
+		return tunnelDriver
+	}
+	return nil
+}
+
+// Open opens a session for address, using the driver
+// registered for its scheme (see [RegisterScheme]).
+func Open(address string, opts ...Option) (Session, error) {
+	return OpenContext(context.Background(), address, opts...)
+}
+
+// OpenContext is like [Open], but aborts the connection if ctx
+// is done before it is established.
+func OpenContext(ctx context.Context, address string, opts ...Option) (Session, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("svn connect: parsing %q: %w", address, err)
+	}
+	driver := lookupDriver(u.Scheme)
+	if driver == nil {
+		return nil, fmt.Errorf("svn: connect to %q: scheme %q not implemented", address, u.Scheme)
+	}
+	return driver(ctx, address, opts...)
+}
+
+// svnserveDriver opens sessions for "file" URLs,
+// running "svnserve -t" locally.
+func svnserveDriver(ctx context.Context, address string, opts ...Option) (Session, error) {
+	t, err := startTunnel("svnserve", "-t")
+	if err != nil {
+		return nil, err
+	}
+	// Standard "svnserve" does not work if we tell it we want a "file:" scheme:
+	t.scheme = "svn+ssh"
+	return newClient(ctx, t, address, newOptions(opts))
+}
+
+// tunnelDriver opens sessions for "svn+NAME" URLs,
+// running the command of the tunnel NAME.
+func tunnelDriver(ctx context.Context, address string, opts ...Option) (Session, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("svn connect: parsing %q: %w", address, err)
+	}
+	o := newOptions(opts)
+	args, err := tunnelCommand(strings.TrimPrefix(u.Scheme, "svn+"), o.configDir)
+	if err != nil {
+		return nil, fmt.Errorf("svn: connect to %q: %w", address, err)
+	}
+	host := u.Host
+	if u.User != nil {
+		host = u.User.String() + "@" + host
+	}
+	args = append(args, host, "svnserve", "-t")
+	t, err := startTunnel(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(ctx, t, address, o)
+}
+
+// ServerDriver returns a driver which serves every session in-process,
+// running [Server.Serve] over a [net.Pipe] with the server returned
+// by newServer for the URL.  For example, to use a repository
+// implemented in Go for "file" URLs:
+//
+//	svn.RegisterScheme("file", svn.ServerDriver(func(u *url.URL) (*svn.Server, error) {
+//		return repo.NewServer("file:///srv/repo"), nil
+//	}))
+func ServerDriver(newServer func(u *url.URL) (*Server, error)) Driver {
+	return func(ctx context.Context, address string, opts ...Option) (Session, error) {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("svn connect: parsing %q: %w", address, err)
+		}
+		s, err := newServer(u)
+		if err != nil {
+			return nil, fmt.Errorf("svn: connect to %q: %w", address, err)
+		}
+		client, server := net.Pipe()
+		go func() {
+			s.Serve(server, server)
+			server.Close()
+		}()
+		return newClient(ctx, newTunnel(io.NopCloser(client), client), address, newOptions(opts))
+	}
+}
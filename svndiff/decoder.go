@@ -0,0 +1,174 @@
+package svndiff
+
+import (
+	"fmt"
+	"io"
+)
+
+// A Decoder applies a svndiff delta to a source, writing the target.
+// The delta is written to the Decoder in as many calls to Write as
+// needed, split at any point.
+type Decoder struct {
+	w       io.Writer
+	source  io.ReaderAt
+	version int
+	buf     []byte
+	err     error
+}
+
+// NewDecoder returns a Decoder which applies the delta written to it
+// against source, writing the result to w.  Source can be nil if
+// the delta does not use it.
+func NewDecoder(w io.Writer, source io.ReaderAt) *Decoder {
+	return &Decoder{
+		w:       w,
+		source:  source,
+		version: -1,
+	}
+}
+
+// Write decodes as many complete windows as possible from p and
+// the data previously written, and writes the resulting target.
+func (d *Decoder) Write(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	d.buf = append(d.buf, p...)
+	if d.version < 0 {
+		if len(d.buf) < 4 {
+			return len(p), nil
+		}
+		if d.buf[0] != 'S' || d.buf[1] != 'V' || d.buf[2] != 'N' {
+			d.err = ErrCorrupt
+			return 0, d.err
+		}
+		d.version = int(d.buf[3])
+		if d.version > 1 {
+			d.err = fmt.Errorf("svndiff: unsupported version %d", d.version)
+			return 0, d.err
+		}
+		d.buf = d.buf[4:]
+	}
+	for {
+		n, err := d.window()
+		if err != nil {
+			d.err = err
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+		d.buf = d.buf[n:]
+	}
+	return len(p), nil
+}
+
+// Close checks that the delta was complete.
+func (d *Decoder) Close() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.buf) > 0 || d.version < 0 {
+		return fmt.Errorf("svndiff: unexpected end of delta: %w", ErrCorrupt)
+	}
+	return nil
+}
+
+// window decodes and applies the first window in the buffer.
+// It returns the number of bytes used, or 0 if the window is not complete.
+func (d *Decoder) window() (int, error) {
+	var header [5]uint64
+	pos := 0
+	for i := range header {
+		v, n := uvarint(d.buf[pos:])
+		if n < 0 {
+			return 0, ErrCorrupt
+		}
+		if n == 0 {
+			return 0, nil
+		}
+		header[i] = v
+		pos += n
+	}
+	srcOffset, srcLen, tgtLen, insLen, newLen := header[0], header[1], header[2], header[3], header[4]
+	if insLen+newLen > uint64(len(d.buf)-pos) {
+		return 0, nil
+	}
+	ins := d.buf[pos : pos+int(insLen)]
+	data := d.buf[pos+int(insLen) : pos+int(insLen+newLen)]
+	if d.version == 1 {
+		var err error
+		if ins, err = decompress(ins, 5*tgtLen+16); err != nil {
+			return 0, err
+		}
+		if data, err = decompress(data, tgtLen); err != nil {
+			return 0, err
+		}
+	}
+
+	src := make([]byte, srcLen)
+	if srcLen > 0 {
+		if d.source == nil {
+			return 0, ErrCorrupt
+		}
+		n, err := d.source.ReadAt(src, int64(srcOffset))
+		if uint64(n) != srcLen {
+			return 0, fmt.Errorf("svndiff: reading source: %w", err)
+		}
+	}
+
+	tgt := make([]byte, 0, tgtLen)
+	for len(ins) > 0 {
+		op := ins[0] >> 6
+		length := uint64(ins[0] & 0x3f)
+		ins = ins[1:]
+		if length == 0 {
+			v, n := uvarint(ins)
+			if n <= 0 {
+				return 0, ErrCorrupt
+			}
+			length = v
+			ins = ins[n:]
+		}
+		if length > tgtLen-uint64(len(tgt)) {
+			return 0, ErrCorrupt
+		}
+		switch op {
+		case opCopySource, opCopyTarget:
+			offset, n := uvarint(ins)
+			if n <= 0 {
+				return 0, ErrCorrupt
+			}
+			ins = ins[n:]
+			if op == opCopySource {
+				if offset+length > srcLen {
+					return 0, ErrCorrupt
+				}
+				tgt = append(tgt, src[offset:offset+length]...)
+				break
+			}
+			if offset >= uint64(len(tgt)) {
+				return 0, ErrCorrupt
+			}
+			// the ranges can overlap, so copy byte by byte:
+			for i := range length {
+				tgt = append(tgt, tgt[offset+i])
+			}
+		case opNewData:
+			if length > uint64(len(data)) {
+				return 0, ErrCorrupt
+			}
+			tgt = append(tgt, data[:length]...)
+			data = data[length:]
+		default:
+			return 0, ErrCorrupt
+		}
+	}
+	if uint64(len(tgt)) != tgtLen {
+		return 0, ErrCorrupt
+	}
+	if _, err := d.w.Write(tgt); err != nil {
+		return 0, err
+	}
+	return pos + int(insLen+newLen), nil
+}
@@ -0,0 +1,207 @@
+package svndiff
+
+import (
+	"errors"
+	"io"
+)
+
+// blockSize is the size of the blocks of the source that the Encoder
+// tries to find in the target.
+const blockSize = 32
+
+// hashBase is the base of the rolling hash used to find blocks.
+const hashBase = 257
+
+// An Encoder computes a svndiff delta between a source and the data
+// written to it (the target), and writes it to an underlying writer.
+//
+// The target is split in windows of up to 100 KiB; every window is
+// compared against the same range of the source, widened by 50 KiB
+// on each side, so small insertions and deletions produce small deltas,
+// but blocks moved further away may not.
+type Encoder struct {
+	w       io.Writer
+	source  io.ReaderAt
+	version int
+	offset  int64
+	buf     []byte
+	header  bool
+	err     error
+}
+
+// NewEncoder returns an Encoder which writes to w the delta of the
+// target written to it against source, using the given svndiff version
+// (0 or 1).  Source can be nil, if there is no previous version.
+func NewEncoder(w io.Writer, source io.ReaderAt, version int) *Encoder {
+	return &Encoder{
+		w:       w,
+		source:  source,
+		version: version,
+	}
+}
+
+// Write adds p to the target, sending as many windows as possible.
+func (e *Encoder) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	e.buf = append(e.buf, p...)
+	for len(e.buf) >= maxWindowSize {
+		if e.err = e.window(e.buf[:maxWindowSize]); e.err != nil {
+			return 0, e.err
+		}
+		e.buf = e.buf[maxWindowSize:]
+	}
+	return len(p), nil
+}
+
+// Close sends the remaining data.  It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.buf) > 0 {
+		e.err = e.window(e.buf)
+		e.buf = nil
+	} else if !e.header {
+		e.err = e.writeHeader()
+	}
+	if e.err == nil {
+		e.err = errors.New("svndiff: encoder closed")
+		return nil
+	}
+	return e.err
+}
+
+func (e *Encoder) writeHeader() error {
+	e.header = true
+	_, err := e.w.Write([]byte{'S', 'V', 'N', byte(e.version)})
+	return err
+}
+
+// window encodes a window for target, at the current offset.
+// The header is written along with the first window, in the same Write.
+func (e *Encoder) window(target []byte) error {
+	// Subversion requires that the source views never move backwards,
+	// so both ends of the view grow with the offset.
+	var src []byte
+	srcOffset := max(0, e.offset-maxWindowSize/2)
+	if e.source != nil {
+		src = make([]byte, e.offset+int64(len(target))+maxWindowSize/2-srcOffset)
+		n, err := e.source.ReadAt(src, srcOffset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		src = src[:n]
+	}
+	if len(src) == 0 {
+		srcOffset = 0
+	}
+	ins, data := encodeWindow(src, target)
+	if e.version == 1 {
+		ins = compress(ins)
+		data = compress(data)
+	}
+	var out []byte
+	if !e.header {
+		e.header = true
+		out = append(out, 'S', 'V', 'N', byte(e.version))
+	}
+	out = appendUvarint(out, uint64(srcOffset))
+	out = appendUvarint(out, uint64(len(src)))
+	out = appendUvarint(out, uint64(len(target)))
+	out = appendUvarint(out, uint64(len(ins)))
+	out = appendUvarint(out, uint64(len(data)))
+	out = append(out, ins...)
+	out = append(out, data...)
+	e.offset += int64(len(target))
+	_, err := e.w.Write(out)
+	return err
+}
+
+// appendInstruction appends an instruction to ins.
+func appendInstruction(ins []byte, op byte, length int, offset int) []byte {
+	if length < 64 {
+		ins = append(ins, op<<6|byte(length))
+	} else {
+		ins = append(ins, op<<6)
+		ins = appendUvarint(ins, uint64(length))
+	}
+	if op != opNewData {
+		ins = appendUvarint(ins, uint64(offset))
+	}
+	return ins
+}
+
+// encodeWindow returns the instructions and new data needed to build
+// target from src, looking for blocks of src in target.
+func encodeWindow(src []byte, target []byte) ([]byte, []byte) {
+	var ins, data []byte
+	if len(src) < blockSize || len(target) < blockSize {
+		if len(target) > 0 {
+			ins = appendInstruction(ins, opNewData, len(target), 0)
+		}
+		return ins, target
+	}
+
+	// index the blocks in the source:
+	blocks := make(map[uint32]int)
+	for i := 0; i+blockSize <= len(src); i += blockSize {
+		h := hashBlock(src[i : i+blockSize])
+		if _, ok := blocks[h]; !ok {
+			blocks[h] = i
+		}
+	}
+	var pow uint32 = 1
+	for range blockSize - 1 {
+		pow *= hashBase
+	}
+
+	pending := 0 // start of the data not yet encoded
+	pos := 0
+	h := hashBlock(target[:blockSize])
+	for pos+blockSize <= len(target) {
+		if off, ok := blocks[h]; ok && string(src[off:off+blockSize]) == string(target[pos:pos+blockSize]) {
+			// extend the match backwards and forwards:
+			start, srcStart := pos, off
+			for start > pending && srcStart > 0 && target[start-1] == src[srcStart-1] {
+				start--
+				srcStart--
+			}
+			end, srcEnd := pos+blockSize, off+blockSize
+			for end < len(target) && srcEnd < len(src) && target[end] == src[srcEnd] {
+				end++
+				srcEnd++
+			}
+			if start > pending {
+				ins = appendInstruction(ins, opNewData, start-pending, 0)
+				data = append(data, target[pending:start]...)
+			}
+			ins = appendInstruction(ins, opCopySource, end-start, srcStart)
+			pending = end
+			pos = end
+			if pos+blockSize <= len(target) {
+				h = hashBlock(target[pos : pos+blockSize])
+			}
+			continue
+		}
+		if pos+blockSize < len(target) {
+			h = (h-uint32(target[pos])*pow)*hashBase + uint32(target[pos+blockSize])
+		}
+		pos++
+	}
+	if pending < len(target) {
+		ins = appendInstruction(ins, opNewData, len(target)-pending, 0)
+		data = append(data, target[pending:]...)
+	}
+	return ins, data
+}
+
+// hashBlock computes the rolling hash of a block.
+func hashBlock(b []byte) uint32 {
+	var h uint32
+	for _, c := range b {
+		h = h*hashBase + uint32(c)
+	}
+	return h
+}
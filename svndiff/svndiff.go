@@ -0,0 +1,127 @@
+// Package svndiff implements the svndiff binary delta format,
+// used by Subversion to send the differences between two versions
+// of a file.
+//
+// A delta is a header ("SVN" followed by a version byte) and a sequence
+// of windows.  Every window builds a range of the target (the new version)
+// copying bytes from a range of the source (the old version), from the part
+// of the target already built in the same window, or from new data.
+//
+// Versions 0 and 1 are supported.  Version 1 compresses the instructions
+// and the new data of every window using zlib.
+package svndiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Instruction opcodes, stored in the two high bits of every instruction.
+const (
+	opCopySource = 0
+	opCopyTarget = 1
+	opNewData    = 2
+)
+
+// maxWindowSize is the maximum size of the target view of every
+// window generated by the Encoder.
+const maxWindowSize = 100 * 1024
+
+// ErrCorrupt is returned when the delta is not valid svndiff data.
+var ErrCorrupt = errors.New("svndiff: corrupt delta")
+
+// appendUvarint appends the svndiff encoding of an integer to buf:
+// 7 bits per byte, most significant first, with the high bit set
+// in all but the last byte.
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [10]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(v & 0x7f)
+	for v >>= 7; v != 0; v >>= 7 {
+		i--
+		tmp[i] = byte(v&0x7f) | 0x80
+	}
+	return append(buf, tmp[i:]...)
+}
+
+// uvarint decodes an integer from the beginning of buf,
+// returning it and the number of bytes read.  It returns n == 0
+// if buf is too short to hold the whole integer, and n < 0 if
+// the integer is too big.
+func uvarint(buf []byte) (uint64, int) {
+	var v uint64
+	for i, b := range buf {
+		if i >= 10 {
+			return 0, -1
+		}
+		v = v<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// compress encodes a section of a window for svndiff version 1:
+// the original length, followed by the zlib-compressed data if it is
+// shorter than the original, or the original data otherwise.
+func compress(data []byte) []byte {
+	out := appendUvarint(nil, uint64(len(data)))
+	if len(data) < 512 {
+		return append(out, data...)
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	if buf.Len() >= len(data) {
+		return append(out, data...)
+	}
+	return append(out, buf.Bytes()...)
+}
+
+// decompress decodes a section of a window encoded with compress.
+func decompress(data []byte, limit uint64) ([]byte, error) {
+	origLen, n := uvarint(data)
+	if n <= 0 || origLen > limit {
+		return nil, ErrCorrupt
+	}
+	data = data[n:]
+	if uint64(len(data)) == origLen {
+		return data, nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("svndiff: %w", err)
+	}
+	out := make([]byte, origLen)
+	if _, err = io.ReadFull(zr, out); err != nil {
+		return nil, fmt.Errorf("svndiff: %w", err)
+	}
+	return out, nil
+}
+
+// Diff returns the svndiff delta that converts source into target.
+func Diff(source []byte, target []byte, version int) []byte {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, bytes.NewReader(source), version)
+	e.Write(target)
+	e.Close()
+	return buf.Bytes()
+}
+
+// Apply applies the svndiff delta to source, and returns the target.
+func Apply(source []byte, delta []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	d := NewDecoder(&buf, bytes.NewReader(source))
+	if _, err := d.Write(delta); err != nil {
+		return nil, err
+	}
+	if err := d.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,77 @@
+package svndiff
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	big := make([]byte, 300*1024)
+	rnd.Read(big)
+	edited := append(append(append([]byte{}, big[:1000]...), []byte("inserted text")...), big[5000:]...)
+
+	tests := []struct {
+		desc   string
+		source []byte
+		target []byte
+	}{
+		{"empty", nil, nil},
+		{"new file", nil, []byte("hello, world\n")},
+		{"deleted contents", []byte("hello, world\n"), nil},
+		{"same", big, big},
+		{"edited", big, edited},
+		{"text", bytes.Repeat([]byte("line of text\n"), 1000), bytes.Repeat([]byte("line of text!\n"), 1000)},
+	}
+	for _, tt := range tests {
+		for version := range 2 {
+			delta := Diff(tt.source, tt.target, version)
+			got, err := Apply(tt.source, delta)
+			if err != nil {
+				t.Errorf("%s (version %d): %v", tt.desc, version, err)
+				continue
+			}
+			if !bytes.Equal(got, tt.target) {
+				t.Errorf("%s (version %d): target mismatch", tt.desc, version)
+			}
+		}
+	}
+
+	if delta := Diff(big, edited, 0); len(delta) > 1024 {
+		t.Errorf("edited: delta too big (%d bytes)", len(delta))
+	}
+}
+
+func TestDecoderChunks(t *testing.T) {
+	source := bytes.Repeat([]byte("0123456789"), 100)
+	target := append([]byte("prefix "), source[10:]...)
+	delta := Diff(source, target, 1)
+
+	var buf bytes.Buffer
+	d := NewDecoder(&buf, bytes.NewReader(source))
+	for i := range delta {
+		if _, err := d.Write(delta[i : i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), target) {
+		t.Errorf("target mismatch: %q", buf.Bytes())
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	for _, delta := range [][]byte{
+		nil,
+		[]byte("XYZ\x00"),
+		[]byte("SVN\x00\x00\x00\x05\x01\x00\x80"),     // instructions longer than the window
+		[]byte("SVN\x00\x00\x00\x02\x02\x00\x42\x00"), // copy from empty target
+	} {
+		if _, err := Apply(nil, delta); err == nil {
+			t.Errorf("Apply(%q): expected error", delta)
+		}
+	}
+}
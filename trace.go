@@ -0,0 +1,108 @@
+package svn
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Direction tells whether a traced Item was sent or received.
+type Direction int
+
+const (
+	Sent Direction = iota
+	Received
+)
+
+func (d Direction) String() string {
+	if d == Sent {
+		return "->"
+	}
+	return "<-"
+}
+
+// An Option configures a [Client].
+type Option func(*options)
+
+type options struct {
+	trace      func(dir Direction, item Item)
+	configDir  string
+	httpClient *http.Client
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTrace returns an Option which writes every Item sent
+// or received by the client to w, as formatted by [TraceWriter].
+func WithTrace(w io.Writer) Option {
+	return WithTraceFunc(TraceWriter(w))
+}
+
+// WithTraceFunc returns an Option which calls fn
+// with every Item sent or received by the client.
+// If there are several of these options, all the functions are called.
+func WithTraceFunc(fn func(dir Direction, item Item)) Option {
+	return func(o *options) {
+		prev := o.trace
+		if prev == nil {
+			o.trace = fn
+			return
+		}
+		o.trace = func(dir Direction, item Item) {
+			prev(dir, item)
+			fn(dir, item)
+		}
+	}
+}
+
+// TraceWriter returns a trace function which writes every Item to w,
+// in a line with a timestamp and its direction:
+//
+//	10:04:05.312907 -> ( get-latest-rev ( ) )
+//	10:04:05.313450 <- ( success ( 42 ) )
+//
+// Strings are quoted, and only the beginning of long strings is shown.
+// The function can be called from several goroutines.
+func TraceWriter(w io.Writer) func(dir Direction, item Item) {
+	var mu sync.Mutex
+	return func(dir Direction, item Item) {
+		line := time.Now().Format("15:04:05.000000") + " " + dir.String() + " " + formatItem(item) + "\n"
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, line)
+	}
+}
+
+// maxTraceString is the maximum length of the strings shown in traces.
+const maxTraceString = 64
+
+// formatItem returns a readable representation of an Item.
+func formatItem(item Item) string {
+	switch item.Type {
+	case StringType:
+		if len(item.Text) > maxTraceString {
+			return strconv.Quote(item.Text[:maxTraceString]) + fmt.Sprintf("...(%d bytes)", len(item.Text))
+		}
+		return strconv.Quote(item.Text)
+	case ListType:
+		var b strings.Builder
+		b.WriteString("(")
+		for _, elem := range item.List {
+			b.WriteString(" ")
+			b.WriteString(formatItem(elem))
+		}
+		b.WriteString(" )")
+		return b.String()
+	}
+	return item.String()
+}
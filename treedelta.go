@@ -0,0 +1,588 @@
+package svn
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cespedes/svn/svndiff"
+)
+
+// TreeDelta sends to e the changes needed to bring the working copy
+// described by report to its target, reading both trees from r, as
+// [Server.Update].  It can be used to implement [UpdateRepository], and
+// it is used by [Server] for the repositories which do not implement it.
+//
+// The source of every path is the node reported by the client: the path
+// in the session (or under the LinkPath of its nearest reported parent)
+// in the reported revision.  Its target is the node in report.Rev (or
+// the latest revision) at the same path under the target, or under
+// report.DstPath in "switch" and "diff".
+//
+// Only the nodes which differ are sent.  A node is unchanged if it is
+// in the same path and CreatedRev in both trees, or if it is a file in
+// another path with the same CreatedRev, properties and contents.  The
+// nodes which are added or changed get their "svn:entry:" properties,
+// and the contents of the files are sent as svndiff deltas against the
+// ones in the working copy (or without any delta windows, if
+// report.TextDeltas is false), streamed from r.
+//
+// The entries which cannot be read because r returns an authorization
+// error (170001) are sent with AbsentDir or AbsentFile if the client has
+// the "absent-entries" capability, and are skipped otherwise.
+func TreeDelta(r Repository, sess *ServerSession, report *Report, e Editor) error {
+	rev, err := targetRev(r, sess, report.Rev)
+	if err != nil {
+		return err
+	}
+	if err = e.TargetRev(rev); err != nil {
+		return err
+	}
+	d := newTreeDelta(r, sess, report, rev, e)
+	d.entryProps = true
+	return d.drive()
+}
+
+// replayDelta sends to e the changes made in a revision under the URL
+// of the session, as the tree delta between it and the previous one.
+// As the copies are not known, every change is sent as such,
+// which is valid for any lowWater not after rev.
+func replayDelta(r Repository, sess *ServerSession, rev uint, lowWater uint, sendDeltas bool, e Editor) error {
+	if lowWater > rev {
+		return Error{
+			AprErr:  160006, // SVN_ERR_FS_NO_SUCH_REVISION
+			Message: fmt.Sprintf("Low water mark r%d is after revision r%d", lowWater, rev),
+		}
+	}
+	report := &Report{
+		Command:    "replay",
+		Rev:        &rev,
+		Depth:      "infinity",
+		TextDeltas: sendDeltas,
+		Paths:      []ReportedPath{{Depth: "infinity"}},
+	}
+	if rev > 0 {
+		report.Paths[0].Rev = rev - 1
+	} else {
+		report.Paths[0].StartEmpty = true
+	}
+	return newTreeDelta(r, sess, report, rev, e).drive()
+}
+
+// replayRevProps returns the properties of a revision,
+// as found in its log entry.
+func replayRevProps(r Repository, sess *ServerSession, rev uint) (Props, error) {
+	entries, err := r.Log(sess, []string{""}, rev, rev, false)
+	if err != nil {
+		return nil, err
+	}
+	props := Props{}
+	for _, entry := range entries {
+		if entry.Rev != rev {
+			continue
+		}
+		for name, value := range map[string]string{
+			PropRevAuthor: entry.Author,
+			PropRevDate:   entry.Date,
+			PropRevLog:    entry.Message,
+		} {
+			if value != "" {
+				props[name] = value
+			}
+		}
+	}
+	return props, nil
+}
+
+// targetRev returns rev, or the latest revision if it is nil.
+func targetRev(r Repository, sess *ServerSession, rev *uint) (uint, error) {
+	if rev != nil {
+		return *rev, nil
+	}
+	return r.LatestRev(sess)
+}
+
+// A treeDelta computes the changes sent by TreeDelta.
+// The paths in the working copy (and in the Editor) are relative
+// to the anchor of the edit, which is the URL of the session;
+// the ones in the repository are relative to its root.
+type treeDelta struct {
+	r      Repository
+	sess   *ServerSession
+	report *Report
+	rev    uint
+	e      Editor
+	// reported are the paths of the report,
+	// indexed by their path in the working copy.
+	reported map[string]ReportedPath
+	// entryProps is true to send the "svn:entry:" properties.
+	entryProps bool
+	tokens     int
+}
+
+// A deltaNode is a node in one of the trees.
+type deltaNode struct {
+	path   string
+	rev    uint
+	dirent Dirent
+}
+
+// A deltaSource is a node in the working copy.
+type deltaSource struct {
+	deltaNode
+	startEmpty bool
+	depth      string
+}
+
+func newTreeDelta(r Repository, sess *ServerSession, report *Report, rev uint, e Editor) *treeDelta {
+	d := &treeDelta{
+		r:        r,
+		sess:     sess,
+		report:   report,
+		rev:      rev,
+		e:        e,
+		reported: make(map[string]ReportedPath),
+	}
+	for _, reported := range report.Paths {
+		d.reported[strings.Trim(path.Join(report.Target, reported.Path), "/")] = reported
+	}
+	return d
+}
+
+// token returns a new directory or file token.
+func (d *treeDelta) token() string {
+	d.tokens++
+	return strconv.Itoa(d.tokens)
+}
+
+// drive sends the whole edit, except CloseEdit.
+func (d *treeDelta) drive() error {
+	report := d.report
+	top, ok := d.reported[report.Target]
+	if !ok {
+		return Error{
+			AprErr:  165004, // SVN_ERR_REPOS_BAD_REVISION_REPORT
+			Message: "Invalid report for top level of working copy",
+		}
+	}
+	// the target is an entry of the anchor, or the anchor itself.
+	srcPath := d.sess.Path(report.Target)
+	tgtPath := srcPath
+	if report.Command == "switch" || report.Command == "diff" {
+		tgtPath = report.DstPath
+	}
+	src, err := d.reportedSource(srcPath, top)
+	if err != nil {
+		return err
+	}
+	tgt, err := d.stat(tgtPath, d.rev)
+	if err != nil {
+		return err
+	}
+	rootRev := top.Rev
+	if err = d.e.OpenRoot(&rootRev, "d"); err != nil {
+		return err
+	}
+	if report.Target != "" {
+		err = d.entry("d", report.Target, src, tgt, report.Depth)
+	} else if tgt == nil || tgt.dirent.Kind != NodeDir {
+		err = Error{
+			AprErr:  160020, // SVN_ERR_FS_PATH_SYNTAX
+			Message: fmt.Sprintf("Target path '/%s' does not exist", tgtPath),
+		}
+	} else {
+		err = d.dir("d", "", src, tgt, report.Depth)
+	}
+	if err != nil {
+		return err
+	}
+	return d.e.CloseDir("d")
+}
+
+// stat returns a node of a tree, or nil if it does not exist.
+func (d *treeDelta) stat(p string, rev uint) (*deltaNode, error) {
+	stat, err := d.r.Stat(d.sess, p, &rev)
+	if err != nil || stat.Kind == NodeNone {
+		return nil, err
+	}
+	return &deltaNode{path: p, rev: rev, dirent: Dirent{
+		Path:        path.Base("/" + p),
+		Kind:        stat.Kind,
+		Size:        stat.Size,
+		HasProps:    stat.HasProps,
+		CreatedRev:  stat.CreatedRev,
+		CreatedDate: stat.CreatedDate,
+		LastAuthor:  stat.LastAuthor,
+	}}, nil
+}
+
+// reportedSource returns a node of the working copy, as reported
+// by the client, or nil if it is not there.  p is its path in the
+// repository, unless it is switched.
+func (d *treeDelta) reportedSource(p string, reported ReportedPath) (*deltaSource, error) {
+	if reported.Deleted {
+		return nil, nil
+	}
+	if reported.LinkPath != "" {
+		p = reported.LinkPath
+	}
+	node, err := d.stat(p, reported.Rev)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	return &deltaSource{deltaNode: *node, startEmpty: reported.StartEmpty, depth: reported.Depth}, nil
+}
+
+// hasReported reports whether any path under wcPath was reported.
+func (d *treeDelta) hasReported(wcPath string) bool {
+	for p := range d.reported {
+		if p != wcPath && (wcPath == "" || strings.HasPrefix(p, wcPath+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// depthOrder sorts the depths from the shallowest to the deepest.
+var depthOrder = []string{"empty", "files", "immediates", "infinity"}
+
+// unchanged reports whether a node of the working copy is the same as
+// its target, with no need to look at its entries.
+func (d *treeDelta) unchanged(src *deltaSource, tgt *deltaNode, depth string) bool {
+	if src.path != tgt.path || src.dirent.CreatedRev != tgt.dirent.CreatedRev {
+		return false
+	}
+	if tgt.dirent.Kind == NodeFile {
+		return true
+	}
+	// a directory also needs its entries to be complete,
+	// and to be updated to a depth not deeper than the current one.
+	if src.startEmpty {
+		return false
+	}
+	return depth == "unknown" || slices.Index(depthOrder, depth) <= slices.Index(depthOrder, src.depth)
+}
+
+// entry sends the changes of an entry of the directory parentToken,
+// whose source is src (nil if it is not in the working copy) and whose
+// target is tgt (nil if it does not exist).
+func (d *treeDelta) entry(parentToken string, wcPath string, src *deltaSource, tgt *deltaNode, depth string) error {
+	e := d.e
+	if tgt == nil {
+		if src == nil {
+			return nil
+		}
+		return e.DeleteEntry(wcPath, nil, parentToken)
+	}
+	if src != nil && src.dirent.Kind != tgt.dirent.Kind {
+		if err := e.DeleteEntry(wcPath, nil, parentToken); err != nil {
+			return err
+		}
+		src = nil
+	}
+	if src != nil && !d.hasReported(wcPath) && d.unchanged(src, tgt, depth) {
+		return nil
+	}
+	if tgt.dirent.Kind == NodeDir {
+		return d.dir(parentToken, wcPath, src, tgt, depth)
+	}
+	return d.file(parentToken, wcPath, src, tgt)
+}
+
+// absent sends an entry which cannot be read, if err is an authorization
+// error.  It returns err if it is not, or nil.
+func (d *treeDelta) absent(parentToken string, wcPath string, kind NodeKind, err error) error {
+	var svnErr Error
+	if !errors.As(err, &svnErr) || svnErr.AprErr != 170001 { // SVN_ERR_RA_NOT_AUTHORIZED
+		return err
+	}
+	if !d.sess.HasCapability("absent-entries") {
+		return nil
+	}
+	if kind == NodeDir {
+		return d.e.AbsentDir(wcPath, parentToken)
+	}
+	return d.e.AbsentFile(wcPath, parentToken)
+}
+
+// dir sends the changes of a directory (the anchor itself if wcPath is
+// empty, which is already open with the token parentToken).
+func (d *treeDelta) dir(parentToken string, wcPath string, src *deltaSource, tgt *deltaNode, depth string) error {
+	e := d.e
+	tgtDir, err := d.r.GetDir(d.sess, tgt.path, &tgt.rev, true, true, direntFields)
+	if err != nil {
+		return d.absent(parentToken, wcPath, NodeDir, err)
+	}
+	var srcDir Dir
+	if src != nil {
+		if srcDir, err = d.r.GetDir(d.sess, src.path, &src.rev, true, true, direntFields); err != nil {
+			return err
+		}
+	}
+	token := parentToken
+	if wcPath != "" {
+		token = d.token()
+		if src == nil {
+			err = e.AddDir(wcPath, parentToken, token, "", 0)
+		} else {
+			rev := src.rev
+			err = e.OpenDir(wcPath, parentToken, token, &rev)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = d.props(token, e.ChangeDirProp, src, srcDir.Props, tgt, tgtDir.Props); err != nil {
+		return err
+	}
+
+	// the entries of the working copy depend on its depth,
+	// and the ones which are sent on the requested depth.
+	wcDepth := "infinity"
+	if src != nil {
+		wcDepth = src.depth
+		if src.startEmpty {
+			wcDepth = "empty"
+		}
+	}
+	effDepth := depth
+	if depth == "unknown" {
+		effDepth = wcDepth
+	}
+	childDepth := depth
+	if depth != "unknown" && depth != "infinity" {
+		childDepth = "empty"
+	}
+	srcEntries := make(map[string]Dirent)
+	for _, dirent := range srcDir.Entries {
+		if wcDepth == "infinity" || wcDepth == "immediates" || (wcDepth == "files" && dirent.Kind == NodeFile) {
+			srcEntries[dirent.Path] = dirent
+		}
+	}
+	tgtEntries := make(map[string]Dirent)
+	var names []string
+	for _, dirent := range tgtDir.Entries {
+		tgtEntries[dirent.Path] = dirent
+		names = append(names, dirent.Path)
+	}
+	for name := range srcEntries {
+		if _, ok := tgtEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		childPath := path.Join(wcPath, name)
+		var childSrc *deltaSource
+		if reported, ok := d.reported[childPath]; ok {
+			var p string
+			if src != nil {
+				p = path.Join(src.path, name)
+			}
+			if src != nil || reported.LinkPath != "" {
+				if childSrc, err = d.reportedSource(p, reported); err != nil {
+					return err
+				}
+			}
+		} else if dirent, ok := srcEntries[name]; ok {
+			childWCDepth := "infinity"
+			if wcDepth != "infinity" {
+				childWCDepth = "empty"
+			}
+			childSrc = &deltaSource{
+				deltaNode: deltaNode{path: path.Join(src.path, name), rev: src.rev, dirent: dirent},
+				depth:     childWCDepth,
+			}
+		}
+		var childTgt *deltaNode
+		kind := NodeNone
+		if dirent, ok := tgtEntries[name]; ok {
+			childTgt = &deltaNode{path: path.Join(tgt.path, name), rev: tgt.rev, dirent: dirent}
+			kind = dirent.Kind
+		} else if childSrc != nil {
+			kind = childSrc.dirent.Kind
+		}
+		switch {
+		case effDepth == "empty":
+			continue
+		case kind == NodeDir && effDepth == "files":
+			continue
+		}
+		if err = d.entry(token, childPath, childSrc, childTgt, childDepth); err != nil {
+			return err
+		}
+	}
+	if wcPath == "" {
+		return nil
+	}
+	return e.CloseDir(token)
+}
+
+// direntFields are the fields of the entries needed by a treeDelta.
+var direntFields = []string{"kind", "size", "has-props", "created-rev", "time", "last-author"}
+
+// openFile returns a file and a reader of its contents, with their MD5
+// checksum in file.Checksum.  If the repository does not return it,
+// it is computed reading the contents, which are kept in memory
+// unless they can be read again.
+func (d *treeDelta) openFile(node *deltaNode) (File, io.Reader, error) {
+	file, r, err := d.r.GetFile(d.sess, node.path, &node.rev, true, true)
+	if err != nil {
+		return File{}, nil, err
+	}
+	if r == nil {
+		r = bytes.NewReader(file.Contents)
+	}
+	if len(file.Checksum) == 2*md5.Size {
+		return file, r, nil
+	}
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if file.Checksum, err = md5Reader(rs); err != nil {
+			closeReader(r)
+			return File{}, nil, err
+		}
+		return file, r, nil
+	}
+	defer closeReader(r)
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return File{}, nil, err
+	}
+	file.Checksum = fmt.Sprintf("%x", md5.Sum(contents))
+	return file, bytes.NewReader(contents), nil
+}
+
+// readerAt returns r as an io.ReaderAt, as needed for the source
+// of a delta, reading it into memory if it is not one.
+func readerAt(r io.Reader) (io.ReaderAt, error) {
+	if ra, ok := r.(io.ReaderAt); ok {
+		return ra, nil
+	}
+	contents, err := io.ReadAll(r)
+	return bytes.NewReader(contents), err
+}
+
+// file sends the changes of a file.  Its contents are streamed through
+// the svndiff encoder, so only the parts of both texts in the current
+// window are kept in memory (if the repository can read them again).
+func (d *treeDelta) file(parentToken string, wcPath string, src *deltaSource, tgt *deltaNode) error {
+	e := d.e
+	tgtFile, contents, err := d.openFile(tgt)
+	if err != nil {
+		return d.absent(parentToken, wcPath, NodeFile, err)
+	}
+	defer closeReader(contents)
+	var srcFile File
+	var base io.ReaderAt
+	if src != nil {
+		var r io.Reader
+		if srcFile, r, err = d.openFile(&src.deltaNode); err != nil {
+			return err
+		}
+		defer closeReader(r)
+		// the "svn:entry:" properties change with the CreatedRev,
+		// even if the contents and properties do not.
+		if srcFile.Checksum == tgtFile.Checksum && propsEqual(srcFile.Props, tgtFile.Props) &&
+			(!d.entryProps || src.dirent.CreatedRev == tgt.dirent.CreatedRev) {
+			return nil
+		}
+		if base, err = readerAt(r); err != nil {
+			return err
+		}
+	}
+	token := d.token()
+	if src == nil {
+		err = e.AddFile(wcPath, parentToken, token, "", 0)
+	} else {
+		rev := src.rev
+		err = e.OpenFile(wcPath, parentToken, token, &rev)
+	}
+	if err != nil {
+		return err
+	}
+	if err = d.props(token, e.ChangeFileProp, src, srcFile.Props, tgt, tgtFile.Props); err != nil {
+		return err
+	}
+	if src == nil || srcFile.Checksum != tgtFile.Checksum {
+		w, err := e.ApplyTextDelta(token, srcFile.Checksum)
+		if err != nil {
+			return err
+		}
+		if d.report.TextDeltas {
+			enc := svndiff.NewEncoder(w, base, 0)
+			if _, err = io.Copy(enc, contents); err == nil {
+				err = enc.Close()
+			}
+			if err != nil {
+				w.Close()
+				return err
+			}
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+	}
+	return e.CloseFile(token, tgtFile.Checksum)
+}
+
+// props sends the changes in the properties of a node, and its
+// "svn:entry:" properties if it was added or its CreatedRev changed.
+func (d *treeDelta) props(token string, change func(token string, name string, value *string) error, src *deltaSource, srcProps Props, tgt *deltaNode, tgtProps Props) error {
+	if d.entryProps && (src == nil || src.dirent.CreatedRev != tgt.dirent.CreatedRev) {
+		entryProps := Props{
+			"svn:entry:committed-rev":  strconv.FormatUint(uint64(tgt.dirent.CreatedRev), 10),
+			"svn:entry:committed-date": tgt.dirent.CreatedDate,
+			"svn:entry:last-author":    tgt.dirent.LastAuthor,
+			"svn:entry:uuid":           d.sess.UUID,
+		}
+		for _, name := range entryProps.Names() {
+			if value := entryProps[name]; value != "" {
+				if err := change(token, name, &value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	names := tgtProps.Names()
+	for name := range srcProps {
+		if _, ok := tgtProps[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		value, ok := tgtProps[name]
+		if old, had := srcProps[name]; had && ok && old == value {
+			continue
+		}
+		var err error
+		if ok {
+			err = change(token, name, &value)
+		} else {
+			err = change(token, name, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// propsEqual reports whether two sets of properties are the same.
+func propsEqual(a, b Props) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if v, ok := b[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,339 @@
+package svn
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cespedes/svn/svndiff"
+)
+
+// A treeRepo is a Repository with some revisions, each of them
+// with its nodes indexed by path.  The directories named "secret"
+// cannot be read.
+type treeRepo struct {
+	revs []map[string]treeNode
+}
+
+type treeNode struct {
+	dir      bool
+	contents string
+	props    Props
+	created  uint
+}
+
+func (r *treeRepo) node(p string, rev *uint) (treeNode, bool) {
+	n := uint(len(r.revs) - 1)
+	if rev != nil {
+		n = *rev
+	}
+	node, ok := r.revs[n][p]
+	return node, ok
+}
+
+func (r *treeRepo) Open(sess *ServerSession) (ReposInfo, error) {
+	return ReposInfo{UUID: "uuid", URL: "svn://example.com/repo"}, nil
+}
+
+func (r *treeRepo) LatestRev(sess *ServerSession) (uint, error) {
+	return uint(len(r.revs) - 1), nil
+}
+
+func (r *treeRepo) Stat(sess *ServerSession, p string, rev *uint) (Stat, error) {
+	node, ok := r.node(p, rev)
+	if !ok {
+		return Stat{Kind: NodeNone}, nil
+	}
+	stat := Stat{Kind: NodeFile, CreatedRev: node.created, LastAuthor: "alice"}
+	if node.dir {
+		stat.Kind = NodeDir
+	}
+	return stat, nil
+}
+
+func (r *treeRepo) CheckPath(sess *ServerSession, p string, rev *uint) (NodeKind, error) {
+	stat, err := r.Stat(sess, p, rev)
+	return stat.Kind, err
+}
+
+func (r *treeRepo) GetFile(sess *ServerSession, p string, rev *uint, wantProps bool, wantContents bool) (File, io.Reader, error) {
+	node, _ := r.node(p, rev)
+	return File{Props: node.props}, strings.NewReader(node.contents), nil
+}
+
+func (r *treeRepo) GetDir(sess *ServerSession, p string, rev *uint, wantProps bool, wantContents bool, fields []string) (Dir, error) {
+	if path.Base(p) == "secret" {
+		return Dir{}, Error{AprErr: 170001, Message: "Authorization failed"}
+	}
+	node, _ := r.node(p, rev)
+	dir := Dir{Props: node.props}
+	for child, n := range r.revs[*rev] {
+		if child != "" && path.Dir("/"+child) == path.Clean("/"+p) {
+			dirent := Dirent{Path: path.Base(child), Kind: NodeFile, CreatedRev: n.created, LastAuthor: "alice"}
+			if n.dir {
+				dirent.Kind = NodeDir
+			}
+			dir.Entries = append(dir.Entries, dirent)
+		}
+	}
+	return dir, nil
+}
+
+func (r *treeRepo) Log(sess *ServerSession, paths []string, startRev uint, endRev uint, changedPaths bool) ([]LogEntry, error) {
+	return []LogEntry{{Rev: startRev, Author: "alice", Message: fmt.Sprintf("r%d", startRev)}}, nil
+}
+
+// A recordingEditor records the calls of an edit, except the ones with
+// the "svn:entry:" properties (unless entryProps is true, which records
+// the committed-rev), applying the text deltas to base.
+type recordingEditor struct {
+	base       map[string]string
+	paths      map[string]string
+	record     []string
+	entryProps bool
+}
+
+func (e *recordingEditor) add(format string, args ...any) error {
+	e.record = append(e.record, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (e *recordingEditor) TargetRev(rev uint) error { return e.add("target-rev %d", rev) }
+
+func (e *recordingEditor) OpenRoot(rev *uint, rootToken string) error {
+	e.paths = map[string]string{rootToken: ""}
+	return e.add("open-root %d", *rev)
+}
+
+func (e *recordingEditor) DeleteEntry(p string, rev *uint, dirToken string) error {
+	return e.add("delete %s", p)
+}
+
+func (e *recordingEditor) AddDir(p string, parentToken string, childToken string, copyPath string, copyRev uint) error {
+	e.paths[childToken] = p
+	return e.add("add-dir %s", p)
+}
+
+func (e *recordingEditor) OpenDir(p string, parentToken string, childToken string, rev *uint) error {
+	e.paths[childToken] = p
+	return e.add("open-dir %s", p)
+}
+
+func (e *recordingEditor) ChangeDirProp(dirToken string, name string, value *string) error {
+	if strings.HasPrefix(name, "svn:entry:") {
+		if e.entryProps && name == "svn:entry:committed-rev" {
+			return e.add("entry %s rev=%s", e.paths[dirToken], *value)
+		}
+		return nil
+	}
+	if value == nil {
+		return e.add("del-prop %s %s", e.paths[dirToken], name)
+	}
+	return e.add("prop %s %s=%s", e.paths[dirToken], name, *value)
+}
+
+func (e *recordingEditor) CloseDir(dirToken string) error {
+	return e.add("close-dir %s", e.paths[dirToken])
+}
+
+func (e *recordingEditor) AbsentDir(p string, parentToken string) error {
+	return e.add("absent-dir %s", p)
+}
+
+func (e *recordingEditor) AddFile(p string, dirToken string, fileToken string, copyPath string, copyRev uint) error {
+	e.paths[fileToken] = p
+	return e.add("add-file %s", p)
+}
+
+func (e *recordingEditor) OpenFile(p string, dirToken string, fileToken string, rev *uint) error {
+	e.paths[fileToken] = p
+	return e.add("open-file %s", p)
+}
+
+type recordingDelta struct {
+	bytes.Buffer
+	e *recordingEditor
+	p string
+}
+
+func (w *recordingDelta) Close() error {
+	if w.Len() == 0 {
+		return w.e.add("no-delta %s", w.p)
+	}
+	contents, err := svndiff.Apply([]byte(w.e.base[w.p]), w.Bytes())
+	if err != nil {
+		return err
+	}
+	return w.e.add("delta %s %q", w.p, contents)
+}
+
+func (e *recordingEditor) ApplyTextDelta(fileToken string, baseChecksum string) (io.WriteCloser, error) {
+	return &recordingDelta{e: e, p: e.paths[fileToken]}, nil
+}
+
+func (e *recordingEditor) ChangeFileProp(fileToken string, name string, value *string) error {
+	return e.ChangeDirProp(fileToken, name, value)
+}
+
+func (e *recordingEditor) CloseFile(fileToken string, textChecksum string) error {
+	return e.add("close-file %s", e.paths[fileToken])
+}
+
+func (e *recordingEditor) AbsentFile(p string, parentToken string) error {
+	return e.add("absent-file %s", p)
+}
+
+func (e *recordingEditor) CloseEdit() error { return e.add("close-edit") }
+func (e *recordingEditor) AbortEdit() error { return e.add("abort-edit") }
+
+func TestTreeDelta(t *testing.T) {
+	r := &treeRepo{revs: []map[string]treeNode{
+		{"": {dir: true}},
+		{
+			"":          {dir: true, created: 1},
+			"a.txt":     {contents: "one\n", created: 1},
+			"d":         {dir: true, created: 1},
+			"d/b.txt":   {contents: "b\n", created: 1},
+			"d/e":       {dir: true, created: 1},
+			"d/e/c.txt": {contents: "c\n", created: 1},
+		},
+		{
+			"":          {dir: true, created: 2},
+			"a.txt":     {contents: "one\ntwo\n", created: 2},
+			"d":         {dir: true, props: Props{"p": "v"}, created: 2},
+			"d/new.txt": {contents: "new\n", props: Props{PropExecutable: "*"}, created: 2},
+			"d/e":       {dir: true, created: 1},
+			"d/e/c.txt": {contents: "c\n", created: 1},
+			"secret":    {dir: true, created: 2},
+		},
+	}}
+	sess := &ServerSession{
+		URL:          "svn://example.com/repo",
+		Root:         "svn://example.com/repo",
+		UUID:         "uuid",
+		Capabilities: []string{"absent-entries"},
+	}
+	rev2 := uint(2)
+	tests := []struct {
+		name   string
+		url    string
+		report Report
+		want   []string
+	}{{
+		name: "update",
+		report: Report{Command: "update", Rev: &rev2, Depth: "unknown", TextDeltas: true,
+			Paths: []ReportedPath{{Rev: 1, Depth: "infinity"}}},
+		want: []string{
+			"target-rev 2", "open-root 1",
+			"open-file a.txt", `delta a.txt "one\ntwo\n"`, "close-file a.txt",
+			"open-dir d", "prop d p=v", "delete d/b.txt",
+			"add-file d/new.txt", "prop d/new.txt svn:executable=*", `delta d/new.txt "new\n"`, "close-file d/new.txt",
+			"close-dir d",
+			"absent-dir secret",
+			"close-dir ",
+		},
+	}, {
+		name: "checkout of immediates",
+		report: Report{Command: "update", Depth: "immediates", TextDeltas: true,
+			Paths: []ReportedPath{{Rev: 0, StartEmpty: true, Depth: "infinity"}}},
+		want: []string{
+			"target-rev 2", "open-root 0",
+			"add-file a.txt", `delta a.txt "one\ntwo\n"`, "close-file a.txt",
+			"add-dir d", "prop d p=v", "close-dir d",
+			"absent-dir secret",
+			"close-dir ",
+		},
+	}, {
+		name: "status of a mixed working copy",
+		report: Report{Command: "status", Target: "d", Depth: "unknown",
+			Paths: []ReportedPath{
+				{Rev: 2, Depth: "infinity"},
+				{Path: "new.txt", Rev: 1, Deleted: true},
+				{Path: "e", Rev: 0, Depth: "infinity"},
+			}},
+		want: []string{
+			"target-rev 2", "open-root 2",
+			"open-dir d",
+			"add-dir d/e", "add-file d/e/c.txt", "no-delta d/e/c.txt", "close-file d/e/c.txt", "close-dir d/e",
+			"add-file d/new.txt", "prop d/new.txt svn:executable=*", "no-delta d/new.txt", "close-file d/new.txt",
+			"close-dir d",
+			"close-dir ",
+		},
+	}, {
+		name: "switch",
+		url:  "svn://example.com/repo/d",
+		report: Report{Command: "switch", Rev: &rev2, Depth: "infinity", DstPath: "d/e", TextDeltas: true,
+			Paths: []ReportedPath{{Rev: 1, Depth: "infinity"}}},
+		want: []string{
+			"target-rev 2", "open-root 1",
+			"delete b.txt", "add-file c.txt", `delta c.txt "c\n"`, "close-file c.txt", "delete e",
+			"close-dir ",
+		},
+	}}
+	for _, test := range tests {
+		sess.URL = sess.Root
+		if test.url != "" {
+			sess.URL = test.url
+		}
+		e := &recordingEditor{base: map[string]string{"a.txt": "one\n"}}
+		if err := TreeDelta(r, sess, &test.report, e); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !slices.Equal(e.record, test.want) {
+			t.Errorf("%s:\n got %q\nwant %q", test.name, e.record, test.want)
+		}
+	}
+
+	// without "absent-entries", the secrets are skipped:
+	sess.URL, sess.Capabilities = sess.Root, nil
+	e := &recordingEditor{}
+	report := &Report{Command: "update", Target: "secret", Depth: "infinity", Paths: []ReportedPath{{Rev: 1}}}
+	if err := TreeDelta(r, sess, report, e); err != nil || len(e.record) != 3 {
+		t.Errorf("update of secret: %q, %v", e.record, err)
+	}
+
+	// a replay has no target-rev or entry props:
+	e = &recordingEditor{base: map[string]string{"a.txt": "one\n"}}
+	if err := replayDelta(r, sess, 1, 0, false, e); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{
+		"open-root 0", "add-file a.txt", "no-delta a.txt", "close-file a.txt",
+		"add-dir d", "add-file d/b.txt", "no-delta d/b.txt", "close-file d/b.txt",
+		"add-dir d/e", "add-file d/e/c.txt", "no-delta d/e/c.txt", "close-file d/e/c.txt", "close-dir d/e",
+		"close-dir d", "close-dir ",
+	}; !slices.Equal(e.record, want) {
+		t.Errorf("replay:\n got %q\nwant %q", e.record, want)
+	}
+	if err := replayDelta(r, sess, 1, 2, false, e); err == nil {
+		t.Errorf("replay of r1 with low water mark r2: no error")
+	}
+	if props, err := replayRevProps(r, sess, 2); err != nil || props[PropRevLog] != "r2" || props[PropRevAuthor] != "alice" {
+		t.Errorf("revprops: %v, %v", props, err)
+	}
+
+	// a file changed with the same contents and properties
+	// gets the entry properties of its new CreatedRev:
+	r = &treeRepo{revs: []map[string]treeNode{
+		{"": {dir: true}},
+		{"": {dir: true, created: 1}, "a.txt": {contents: "a\n", created: 1}},
+		{"": {dir: true, created: 2}, "a.txt": {contents: "a\n", created: 2}},
+	}}
+	e = &recordingEditor{entryProps: true}
+	report = &Report{Command: "update", Depth: "infinity", Paths: []ReportedPath{{Rev: 1, Depth: "infinity"}}}
+	if err := TreeDelta(r, sess, report, e); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{
+		"target-rev 2", "open-root 1", "entry  rev=2",
+		"open-file a.txt", "entry a.txt rev=2", "close-file a.txt",
+		"close-dir ",
+	}; !slices.Equal(e.record, want) {
+		t.Errorf("update of a touched file:\n got %q\nwant %q", e.record, want)
+	}
+}
@@ -0,0 +1,145 @@
+package svn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// ErrClosed is returned when using a [Client] whose connection
+// has been closed.
+var ErrClosed = errors.New("svn: connection closed")
+
+// A tunnel is a connection to a server, which can be shared
+// by several sessions.
+//
+// If there is an error reading from or writing to the connection,
+// or the context of the command being run is done, the connection is
+// aborted and cannot be used any more: after that, every operation
+// returns the same error.
+type tunnel struct {
+	conn conn
+	cmd  *exec.Cmd
+	// stderr keeps the end of the standard error of cmd.
+	stderr *tailBuffer
+	// parent is the URL the connection is parented to.
+	parent string
+	// scheme, if not empty, replaces the scheme of the URLs
+	// sent to the server.
+	scheme string
+	// ctx is the context of the command being run, if any.
+	ctx context.Context
+
+	r io.ReadCloser
+	w io.WriteCloser
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+// newTunnel returns a tunnel which reads from r and writes to w.
+func newTunnel(r io.ReadCloser, w io.WriteCloser) *tunnel {
+	t := &tunnel{r: r, w: w, stderr: &tailBuffer{}}
+	t.conn.r = tunnelReader{t}
+	t.conn.w = tunnelWriter{t}
+	return t
+}
+
+type tunnelReader struct{ t *tunnel }
+
+func (r tunnelReader) Read(p []byte) (int, error) {
+	return r.t.do(func() (int, error) { return r.t.r.Read(p) })
+}
+
+type tunnelWriter struct{ t *tunnel }
+
+func (w tunnelWriter) Write(p []byte) (int, error) {
+	return w.t.do(func() (int, error) { return w.t.w.Write(p) })
+}
+
+// do runs an I/O operation on the connection, aborting it
+// if the context is done before it finishes.
+func (t *tunnel) do(op func() (int, error)) (int, error) {
+	if err := t.failed(); err != nil {
+		return 0, err
+	}
+	if ctx := t.ctx; ctx != nil {
+		if err := ctx.Err(); err != nil {
+			t.abort(err)
+			return 0, err
+		}
+		stop := context.AfterFunc(ctx, func() {
+			t.abort(ctx.Err())
+		})
+		defer stop()
+	}
+	n, err := op()
+	if err != nil {
+		if ferr := t.failed(); ferr != nil {
+			// the error is a consequence of aborting the connection
+			return n, ferr
+		}
+		if stderr := t.stderr.String(); stderr != "" {
+			t.abort(fmt.Errorf("svn: connection broken: %w: %s", err, stderr))
+		} else {
+			t.abort(fmt.Errorf("svn: connection broken: %w", err))
+		}
+	}
+	return n, err
+}
+
+// failed returns the error which made the connection unusable, if any.
+func (t *tunnel) failed() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// abort marks the connection as unusable, closes it and kills
+// the tunnel process, if any.  Only the first call has any effect.
+func (t *tunnel) abort(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	t.err = err
+	t.w.Close()
+	t.r.Close()
+	if t.cmd != nil && t.cmd.Process != nil {
+		t.cmd.Process.Kill()
+	}
+}
+
+// close closes the connection and waits for the tunnel process
+// to exit.  It can be called more than once.
+func (t *tunnel) close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	aborted := t.err != nil
+	var err error
+	if !aborted {
+		t.err = ErrClosed
+		// closing the input of the tunnel should be enough
+		// to make the server exit:
+		err = t.w.Close()
+		if rerr := t.r.Close(); err == nil {
+			err = rerr
+		}
+	}
+	t.mu.Unlock()
+	if t.cmd != nil {
+		if werr := t.cmd.Wait(); err == nil && !aborted {
+			err = werr
+		}
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package svn
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// defaultSSHTunnel is the command used for "svn+ssh" URLs,
+// unless it is defined in the configuration.
+const defaultSSHTunnel = "$SVN_SSH ssh -q -o ControlMaster=no --"
+
+// systemConfigDir is the directory of the system-wide
+// Subversion configuration.
+const systemConfigDir = "/etc/subversion"
+
+// WithConfigDir returns an Option which reads the user configuration
+// of Subversion (used for the tunnel schemes) from dir, instead of
+// from ~/.subversion.
+func WithConfigDir(dir string) Option {
+	return func(o *options) {
+		o.configDir = dir
+	}
+}
+
+// tunnelCommand returns the command line of the tunnel for URLs with
+// scheme "svn+name", as defined in the "tunnels" section of the
+// Subversion configuration.  As in svn, if its definition begins with
+// "$VARIABLE", the value of that environment variable, if defined,
+// is used instead of the rest of the definition.
+func tunnelCommand(name string, configDir string) ([]string, error) {
+	def := readTunnels(configDir)[name]
+	if def == "" && name == "ssh" {
+		def = defaultSSHTunnel
+	}
+	if def == "" {
+		return nil, Error{
+			AprErr:  125002, // SVN_ERR_BAD_URL
+			Message: fmt.Sprintf("Undefined tunnel scheme '%s'", name),
+		}
+	}
+	if rest, ok := strings.CutPrefix(def, "$"); ok {
+		variable, rest, _ := strings.Cut(rest, " ")
+		if value := os.Getenv(variable); value != "" {
+			def = value
+		} else {
+			def = strings.TrimSpace(rest)
+			if def == "" {
+				return nil, Error{
+					AprErr:  125002, // SVN_ERR_BAD_URL
+					Message: fmt.Sprintf("Tunnel scheme %s requires environment variable %s to be defined", name, variable),
+				}
+			}
+		}
+	}
+	args := splitArgs(def)
+	if len(args) == 0 {
+		return nil, Error{
+			AprErr:  125002, // SVN_ERR_BAD_URL
+			Message: fmt.Sprintf("Undefined tunnel scheme '%s'", name),
+		}
+	}
+	return args, nil
+}
+
+// readTunnels returns the tunnel definitions in the system
+// configuration and in the one of the user (which take precedence),
+// indexed by name.  configDir is the directory of the user
+// configuration, or "" for the default one.
+func readTunnels(configDir string) map[string]string {
+	if configDir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configDir = filepath.Join(home, ".subversion")
+		}
+	}
+	tunnels := map[string]string{}
+	for _, dir := range []string{systemConfigDir, configDir} {
+		if dir == "" {
+			continue
+		}
+		for name, value := range readConfigSection(filepath.Join(dir, "config"), "tunnels") {
+			tunnels[name] = value
+		}
+	}
+	return tunnels
+}
+
+// readConfigSection returns the options in a section of a Subversion
+// configuration file, which has the usual INI format: lines beginning
+// with "#" or ";" are comments, and lines beginning with whitespace
+// continue the value of the previous option.
+// Errors (such as a missing file) result in no options.
+func readConfigSection(file string, section string) map[string]string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	result := map[string]string{}
+	inSection := false
+	var last string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';':
+			continue
+		case trimmed[0] == '[':
+			name, _, _ := strings.Cut(trimmed[1:], "]")
+			inSection = strings.TrimSpace(name) == section
+			last = ""
+		case !inSection:
+			continue
+		case line[0] == ' ' || line[0] == '\t':
+			if last != "" {
+				result[last] += " " + trimmed
+			}
+		default:
+			name, value, ok := strings.Cut(line, "=")
+			if !ok {
+				name, value, ok = strings.Cut(line, ":")
+			}
+			if !ok {
+				continue
+			}
+			last = strings.TrimSpace(name)
+			result[last] = strings.TrimSpace(value)
+		}
+	}
+	return result
+}
+
+// splitArgs splits a command line into arguments, separated by spaces,
+// in which single or double quotes and backslashes can be used
+// to include spaces in the arguments.
+func splitArgs(s string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// maxStderr is the amount of the standard error of a tunnel
+// which is kept to be shown in errors.
+const maxStderr = 4096
+
+// A tailBuffer keeps the last maxStderr bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > maxStderr {
+		b.buf = b.buf[len(b.buf)-maxStderr:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.buf))
+}
@@ -0,0 +1,130 @@
+package svn
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// If SVN_TEST_SERVE is set, the test binary acts as a server
+// in its standard input and output, to be used as a tunnel.
+func TestMain(m *testing.M) {
+	if os.Getenv("SVN_TEST_SERVE") != "" {
+		s := &Server{
+			GetLatestRev: func() (uint, error) { return 7, nil },
+		}
+		s.Serve(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"ssh -q --", []string{"ssh", "-q", "--"}},
+		{"  ssh   -i  key ", []string{"ssh", "-i", "key"}},
+		{`ssh -i "my key" -o 'A B'`, []string{"ssh", "-i", "my key", "-o", "A B"}},
+		{`a\ b "c\"d" ''`, []string{"a b", `c"d`, ""}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitArgs(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("splitArgs(%q): want %q got %q", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestTunnelCommand(t *testing.T) {
+	dir := t.TempDir()
+	config := `
+[auth]
+password-stores =
+
+[tunnels]
+# a comment
+foo = $FOO_TUNNEL fake-tunnel -x
+bar = bar-tunnel
+  --continued
+env = $ENV_ONLY
+`
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FOO_TUNNEL", "")
+	t.Setenv("SVN_SSH", "")
+	t.Setenv("ENV_ONLY", "")
+	tests := []struct {
+		name string
+		env  []string
+		want []string
+		err  bool
+	}{
+		{"foo", nil, []string{"fake-tunnel", "-x"}, false},
+		{"foo", []string{"FOO_TUNNEL", "other 'a b'"}, []string{"other", "a b"}, false},
+		{"bar", nil, []string{"bar-tunnel", "--continued"}, false},
+		{"ssh", nil, []string{"ssh", "-q", "-o", "ControlMaster=no", "--"}, false},
+		{"ssh", []string{"SVN_SSH", "plink -batch"}, []string{"plink", "-batch"}, false},
+		{"env", nil, nil, true},
+		{"undefined", nil, nil, true},
+	}
+	for _, tt := range tests {
+		if tt.env != nil {
+			t.Setenv(tt.env[0], tt.env[1])
+		}
+		got, err := tunnelCommand(tt.name, dir)
+		if tt.err {
+			if e, ok := err.(Error); !ok || e.AprErr != 125002 {
+				t.Errorf("tunnel %s: expected error, got %q, %v", tt.name, got, err)
+			}
+		} else if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("tunnel %s: want %q got %q, %v", tt.name, tt.want, got, err)
+		}
+		if tt.env != nil {
+			t.Setenv(tt.env[0], "")
+		}
+	}
+}
+
+func TestConnectTunnel(t *testing.T) {
+	dir := t.TempDir()
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scripts := map[string]string{
+		"good": "#!/bin/sh\nexec env SVN_TEST_SERVE=1 '" + self + "'\n",
+		"bad":  "#!/bin/sh\necho \"fake: cannot connect to $1\" >&2\nexit 255\n",
+	}
+	config := "[tunnels]\n"
+	for name, script := range scripts {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		config += name + " = " + file + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Connect("svn+good://example.com/repo", WithConfigDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev, err := c.GetLatestRev(); err != nil || rev != 7 {
+		t.Errorf("GetLatestRev: %d, %v", rev, err)
+	}
+	if err = c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+
+	_, err = Connect("svn+bad://example.com/repo", WithConfigDir(dir))
+	if err == nil || !strings.Contains(err.Error(), "fake: cannot connect to example.com") {
+		t.Errorf("Connect with a failing tunnel: got %v", err)
+	}
+}
@@ -8,10 +8,25 @@ type ReposInfo struct {
 	Capabilities []string
 }
 
+// InvalidRevision is returned by some functions
+// when there is no revision to return.
+const InvalidRevision = -1
+
+// NodeKind is the kind of a node (path) in the repository.
+type NodeKind string
+
+// Kinds of nodes.
+const (
+	NodeNone    NodeKind = "none"
+	NodeFile    NodeKind = "file"
+	NodeDir     NodeKind = "dir"
+	NodeUnknown NodeKind = "unknown"
+)
+
 // Stat is the response for a "stat" command
 // (asking for the status of a path in a revision).
 type Stat struct {
-	Kind        string
+	Kind        NodeKind
 	Size        uint64
 	HasProps    bool
 	CreatedRev  uint
@@ -23,7 +38,7 @@ type Stat struct {
 // (asking for list of files).
 type Dirent struct {
 	Path        string
-	Kind        string
+	Kind        NodeKind
 	Size        uint64
 	HasProps    bool
 	CreatedRev  uint
@@ -31,13 +46,34 @@ type Dirent struct {
 	LastAuthor  string
 }
 
-// PropList is one of the responses for the "get-file" command
-// (asking for the contents of a file).
+// PropList is every one of the elements of a "proplist",
+// as sent on the wire.  Most of the time it is better to
+// use [Props] instead.
 type PropList struct {
 	Name  string
 	Value string
 }
 
+// File is the response for the "get-file" command
+// (asking for the contents of a file).
+type File struct {
+	Rev            uint
+	Checksum       string
+	Size           uint64
+	Props          Props
+	InheritedProps InheritedProps
+	Contents       []byte
+}
+
+// Dir is the response for the "get-dir" command
+// (asking for the properties and entries of a directory).
+type Dir struct {
+	Rev            uint
+	Props          Props
+	Entries        []Dirent
+	InheritedProps InheritedProps
+}
+
 // LogEntry is every one of the responses for the "log" command.
 type LogEntry struct {
 	Changed []struct {
@@ -49,3 +85,68 @@ type LogEntry struct {
 	Date    string
 	Message string
 }
+
+// LocationSegment is every one of the responses for the
+// "get-location-segments" command: a range of revisions in
+// which a node had the same path.  Path is empty if the node
+// did not exist in that range.
+type LocationSegment struct {
+	RangeStart uint
+	RangeEnd   uint
+	Path       string
+}
+
+// FileRev is every one of the responses for the "get-file-revs"
+// command: a revision in which a file was changed.
+//
+// On the wire, the contents are sent as a delta against the
+// previous revision; here they are always the full text.
+type FileRev struct {
+	Path           string
+	Rev            uint
+	RevProps       Props
+	PropDelta      PropDelta
+	MergedRevision bool
+	Contents       []byte
+}
+
+// Lock is the description of a lock on a path ("lockdesc").
+// Dates are in the same format as in the rest of the protocol,
+// and Expires is empty if the lock does not expire.
+type Lock struct {
+	Path    string
+	Token   string
+	Owner   string
+	Comment string
+	Created string
+	Expires string
+}
+
+// MarshalItem converts the lock into a "lockdesc".
+func (l Lock) MarshalItem() (Item, error) {
+	return Marshal([]any{
+		[]byte(l.Path),
+		[]byte(l.Token),
+		[]byte(l.Owner),
+		optString(l.Comment),
+		[]byte(l.Created),
+		optString(l.Expires),
+	})
+}
+
+// LockResult is the result of locking or unlocking
+// every path in [Client.LockMany] and [Client.UnlockMany].
+type LockResult struct {
+	Path string
+	Lock Lock
+	Err  error
+}
+
+// CommitInfo is the information sent by the server
+// after a successful commit.
+type CommitInfo struct {
+	Rev           uint
+	Date          string
+	Author        string
+	PostCommitErr string
+}